@@ -347,67 +347,22 @@ function cb_deployed(e){
 		// ========================================================
 		// Monitor the height of the blockchain
 		// ========================================================
-		ibc.monitor_blockheight(function(chain_stats){										//there is a new block, lets refresh everything that has a state
+		ibc.monitor_blockheight(function(chain_stats){										//there is a new block, lets send its stats and kyc events
 			if(chain_stats && chain_stats.height){
-				console.log('hey new block, lets refresh and broadcast to all', chain_stats.height-1);
+				console.log('hey new block, lets broadcast its events to all', chain_stats.height-1);
 				ibc.block_stats(chain_stats.height - 1, cb_blockstats);
-				wss.broadcast({msg: 'reset'});
-				chaincode.query.read(['_marbleindex'], cb_got_index);
-				chaincode.query.read(['_opentrades'], cb_got_trades);
 			}
 			
-			//got the block's stats, lets send the statistics
+			//got the block's stats, lets send the statistics and the events in it
 			function cb_blockstats(e, stats){
 				if(e != null) console.log('blockstats error:', e);
 				else {
 					chain_stats.height = chain_stats.height - 1;							//its 1 higher than actual height
 					stats.height = chain_stats.height;										//copy
 					wss.broadcast({msg: 'chainstats', e: e, chainstats: chain_stats, blockstats: stats});
-				}
-			}
-			
-			//got the marble index, lets get each marble
-			function cb_got_index(e, index){
-				if(e != null) console.log('marble index error:', e);
-				else{
-					try{
-						var json = JSON.parse(index);
-						for(var i in json){
-							console.log('!', i, json[i]);
-							chaincode.query.read([json[i]], cb_got_marble);					//iter over each, read their values
-						}
-					}
-					catch(e){
-						console.log('marbles index msg error:', e);
-					}
-				}
-			}
-			
-			//call back for getting a marble, lets send a message
-			function cb_got_marble(e, marble){
-				if(e != null) console.log('marble error:', e);
-				else {
-					try{
-						wss.broadcast({msg: 'marbles', marble: JSON.parse(marble)});
-					}
-					catch(e){
-						console.log('marble msg error', e);
-					}
-				}
-			}
-			
-			//call back for getting open trades, lets send the trades
-			function cb_got_trades(e, trades){
-				if(e != null) console.log('trade error:', e);
-				else {
-					try{
-						trades = JSON.parse(trades);
-						if(trades && trades.open_trades){
-							wss.broadcast({msg: 'open_trades', open_trades: trades.open_trades});
-						}
-					}
-					catch(e){
-						console.log('trade msg error', e);
+					var events = part2.block_events(stats);
+					for(var i in events){
+						wss.broadcast({msg: 'kyc_event', event: events[i]});				//clients update the record it names instead of re-reading them all
 					}
 				}
 			}
@@ -0,0 +1,110 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+)
+
+func TestReset(t *testing.T) {
+	s := newFixture(t)
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarB})
+	s.mustInvoke(t, "delete", aadhaarB, "customer left")
+	s.mustInvoke(t, "init", "1", "", "30")
+	s.as(func() { s.asBank(bankSBI) }, func() { s.mustInvoke(t, "grantConsent", aadhaarA, bankHDFC, model.PurposeLoan, "30") })
+	requestFromHDFC(t, s)
+	s.mustInvoke(t, "reset", "clean slate")
+
+	for key := range s.MockStub.State {
+		for _, prefix := range []string{storage.KYCKeyPrefix, storage.KYCHistoryPrefix, storage.KYCIndexPrefix, storage.ClosedKYCPrefix, storage.KYCPIIPrefix, storage.BankKeyPrefix, "consent", "sharereq"} {
+			if strings.HasPrefix(key, prefix) {
+				t.Fatalf("reset left %q", key)
+			}
+		}
+	}
+	var resetLog []model.ResetEntry
+	if err := json.Unmarshal(s.MockStub.State[storage.ResetLogKey], &resetLog); err != nil {
+		t.Fatal(err)
+	}
+	if len(resetLog) != 1 || resetLog[0].KYCRemoved != 1 || resetLog[0].BanksRemoved != 2 || resetLog[0].ConsentsRemoved != 1 || resetLog[0].RequestsRemoved != 1 || resetLog[0].Reason != "clean slate" {
+		t.Fatalf("unexpected reset log %+v", resetLog)
+	}
+	if config, _ := storage.GetConfig(s); config != (model.ChaincodeConfig{AllowReset: true}) {
+		t.Fatalf("reset left the settings %+v, expecting the defaults with reset still allowed", config)
+	}
+
+	s.createBank(t, bankSpec{Code: bankSBI}) //the ledger is usable again
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarA})
+
+	_, err := s.invoke("reset", " ")
+	expectError(t, err, "Invalid reason: required")
+}
+
+func TestResetOnlyEnabledAtDeploy(t *testing.T) {
+	s := newBareStub()
+	s.init("1") //deployed without reset
+	s.asRegulator()
+	_, err := s.invoke("init", "1", "true")
+	expectCode(t, err, model.CodeFailedPrecondition)
+	_, err = s.init("1", "true")
+	expectError(t, err, "Reset can only be enabled when the chaincode is first deployed")
+	_, err = s.invoke("reset", "clean slate")
+	expectError(t, err, "Reset is disabled on this network")
+
+	s = newFixture(t) //once turned off it stays off
+	s.mustInvoke(t, "init", "1", "false")
+	_, err = s.invoke("init", "1", "true")
+	expectCode(t, err, model.CodeFailedPrecondition)
+	s.mustInvoke(t, "init", "1", "false")
+}
+
+func TestInitSeedsOnlyMissingState(t *testing.T) {
+	s := newFixture(t)
+	s.mustInvoke(t, "init", "2")
+	if string(s.MockStub.State["kyc"]) != "1" {
+		t.Fatalf("re-running Init overwrote kyc with %q", s.MockStub.State["kyc"])
+	}
+
+	s.MockStub.State["kyc"] = []byte(`1;2`) //corrupted, it is repaired instead of kept
+	s.mustInvoke(t, "init", "2")
+	if string(s.MockStub.State["kyc"]) != "2" {
+		t.Fatalf("Init kept the corrupted kyc value %q", s.MockStub.State["kyc"])
+	}
+	s.MockStub.State[storage.BankIndexKey] = []byte(`not a list`) //rebuilt from the registered banks
+	s.mustInvoke(t, "init", "2")
+	if index, err := storage.GetBankIndex(s); err != nil || len(index) != 2 {
+		t.Fatalf("Init left the bank index as %q, %v", s.MockStub.State[storage.BankIndexKey], err)
+	}
+}
+
+func TestResetDisabled(t *testing.T) {
+	s := newFixture(t)
+	s.mustInvoke(t, "init", "1", "false")
+	_, err := s.invoke("reset", "clean slate")
+	expectError(t, err, "Reset is disabled on this network")
+	if rec := s.record(t, aadhaarA); rec == nil {
+		t.Fatal("refused reset removed a record")
+	}
+}
@@ -0,0 +1,250 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+)
+
+func TestWriteBank(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"new bank", bankSpec{Code: "ICIC0000001", LegalName: "ICICI Bank"}.args(), ""},
+		{"code is normalised", []string{" icic0000001 ", "ICICI Bank", "RBI/3", "kyc@icici.example"}, ""},
+		{"too few arguments", []string{"ICIC0000001", "ICICI Bank", "RBI/3"}, "Expecting 4"},
+		{"empty legal name", []string{"ICIC0000001", " ", "RBI/3", "kyc@icici.example"}, "Invalid legalName: required"},
+		{"same code", bankSpec{Code: bankSBI, LegalName: "Another Bank"}.args(), "Bank " + bankSBI + " is already registered"},
+		{"lower case code", bankSpec{Code: "sbin0000001", LegalName: "Another Bank"}.args(), "is already registered"},
+		{"same legal name", bankSpec{Code: "ICIC0000001", LegalName: "hdfc bank"}.args(), "already registered as " + bankHDFC},
+		{"code with a separator", bankSpec{Code: "ICIC_000001", LegalName: "ICICI Bank"}.args(), "Invalid code: must be an IFSC style code"},
+		{"code with a nul", bankSpec{Code: "ICIC0\x0000001", LegalName: "ICICI Bank"}.args(), "Invalid code: must be an IFSC style code"},
+		{"code too short", bankSpec{Code: "ICIC001", LegalName: "ICICI Bank"}.args(), "Invalid code: must be an IFSC style code"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newFixture(t)
+			_, err := s.invoke("writeBank", c.args...)
+			expectError(t, err, c.want)
+			if c.want != "" {
+				return
+			}
+			bank, err := storage.GetBank(s, "ICIC0000001")
+			if err != nil || bank == nil || bank.Status != model.BankActive || bank.OnboardedAt != s.nowMs() {
+				t.Fatalf("unexpected bank %+v, %v", bank, err)
+			}
+			if event := s.lastEvent(t); event.Name != model.EventBankRegistered || event.Payload.Bank != "ICIC0000001" {
+				t.Fatalf("unexpected event %+v", event)
+			}
+		})
+	}
+}
+
+// WriteBank used to append ";code" to the index as a string while readAll parsed it as JSON,
+// so the second bank broke the list
+func TestBankIndexStaysJSON(t *testing.T) {
+	s := newTestStub(t)
+	codes := []string{"AAAA0000001", "BBBB0000001", "CCCC0000001"}
+	for _, code := range codes {
+		s.createBank(t, bankSpec{Code: code})
+	}
+
+	index, err := storage.GetBankIndex(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != len(codes) {
+		t.Fatalf("index holds %q, expecting %q", index, codes)
+	}
+	s.asAuditor()
+	var banks []model.Bank
+	decodeJSON(t, s.mustQuery(t, "readAll"), &banks)
+	for i, bank := range banks {
+		if bank.Code != codes[i] {
+			t.Fatalf("readAll returned %+v, expecting %q in registration order", banks, codes)
+		}
+	}
+	if len(banks) != len(codes) {
+		t.Fatalf("readAll returned %d banks, expecting %d", len(banks), len(codes))
+	}
+}
+
+func TestInitMigratesBaselineBanks(t *testing.T) {
+	s := newBaselineStub()
+	if _, err := s.init("1"); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	index, err := storage.GetBankIndex(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != 2 || index[0] != bankSBI || index[1] != bankHDFC {
+		t.Fatalf("index holds %q, expecting the banks with codes for names", index)
+	}
+	bank, _ := storage.GetBank(s, bankSBI)
+	if bank == nil || bank.LegalName != "State Bank" || bank.LicenceID != "RBI/1" || bank.Contact != "kyc@sbi.example" || bank.Status != model.BankActive {
+		t.Fatalf("SBI migrated to %+v", bank)
+	}
+	for _, name := range []string{bankSBI, bankHDFC} {
+		if _, ok := s.MockStub.State[name]; ok {
+			t.Fatalf("Init left %s under its bare name", name)
+		}
+	}
+	if _, ok := s.MockStub.State["SBI"]; !ok {
+		t.Fatal("Init removed a bank it could not move")
+	}
+	if _, err := s.init("1"); err != nil { //the index is well formed now and left alone
+		t.Fatalf("Init: %v", err)
+	}
+	if again, _ := storage.GetBankIndex(s); len(again) != 2 {
+		t.Fatalf("second Init changed the index to %q", again)
+	}
+}
+
+// the baseline's index is the "null;a;b" string the old WriteBank left, every route that reads it has to work once Init
+// has rebuilt it
+func TestBaselineBankIndexUpgrade(t *testing.T) {
+	const bankICICI = "ICIC0000001"
+	readAll := func(t *testing.T, s *testStub) []string {
+		t.Helper()
+		var banks []model.Bank
+		s.as(s.asAuditor, func() { decodeJSON(t, s.mustQuery(t, "readAll"), &banks) })
+		codes := []string{}
+		for _, bank := range banks {
+			codes = append(codes, bank.Code)
+		}
+		return codes
+	}
+	cases := []struct {
+		name  string
+		route func(t *testing.T, s *testStub)
+		want  []string
+	}{
+		{"readAll", func(t *testing.T, s *testStub) {}, []string{bankSBI, bankHDFC}},
+		{"writeBank", func(t *testing.T, s *testStub) {
+			s.createBank(t, bankSpec{Code: bankICICI})
+		}, []string{bankSBI, bankHDFC, bankICICI}},
+		{"reset", func(t *testing.T, s *testStub) {
+			s.asRegulator()
+			s.mustInvoke(t, "reset", "clean slate") //through ClearBanks
+			for key := range s.MockStub.State {
+				if strings.HasPrefix(key, storage.BankKeyPrefix) {
+					t.Fatalf("reset left %s", key)
+				}
+			}
+		}, []string{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newBaselineStub()
+			if _, err := s.init("1", "true"); err != nil { //the first deploy of the KYC chaincode can turn reset on
+				t.Fatalf("Init: %v", err)
+			}
+			c.route(t, s)
+			if codes := readAll(t, s); !reflect.DeepEqual(codes, c.want) {
+				t.Fatalf("readAll returned %q, expecting %q", codes, c.want)
+			}
+			index, err := storage.GetBankIndex(s)
+			if err != nil {
+				t.Fatalf("index no longer parses: %v", err)
+			}
+			if len(index) != len(c.want) {
+				t.Fatalf("index holds %q, expecting %q", index, c.want)
+			}
+		})
+	}
+}
+
+func TestUpdateBank(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"new details", []string{bankHDFC, "HDFC Bank Ltd", "RBI/2", "kyc@hdfc.example"}, ""},
+		{"keeps its own name", []string{bankHDFC, "HDFC Bank", "RBI/22", "kyc@hdfc.example"}, ""},
+		{"not registered", []string{"ICIC0000001", "ICICI Bank", "RBI/3", "kyc@icici.example"}, "is not registered"},
+		{"takes another bank's name", []string{bankHDFC, "State Bank", "RBI/2", "kyc@hdfc.example"}, "already registered as " + bankSBI},
+		{"too many arguments", []string{bankHDFC, "HDFC Bank", "RBI/2", "kyc@hdfc.example", "x"}, "Expecting 4"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newFixture(t)
+			before, _ := storage.GetBank(s, bankHDFC)
+			s.advance(days(1))
+			_, err := s.invoke("updateBank", c.args...)
+			expectError(t, err, c.want)
+			if c.want != "" {
+				return
+			}
+			bank, _ := storage.GetBank(s, bankHDFC)
+			if bank.LegalName != c.args[1] || bank.LicenceID != c.args[2] {
+				t.Fatalf("bank was not updated: %+v", bank)
+			}
+			if bank.OnboardedAt != before.OnboardedAt || bank.Status != before.Status {
+				t.Fatalf("update changed onboarding date or status: %+v", bank)
+			}
+			if event := s.lastEvent(t); event.Name != model.EventBankUpdated {
+				t.Fatalf("unexpected event %+v", event)
+			}
+		})
+	}
+}
+
+func TestDeactivateBank(t *testing.T) {
+	s := newFixture(t)
+	s.mustInvoke(t, "deactivateBank", bankHDFC)
+	bank, _ := storage.GetBank(s, bankHDFC)
+	if bank == nil || bank.Status != model.BankInactive {
+		t.Fatalf("bank was not deactivated: %+v", bank)
+	}
+	if event := s.lastEvent(t); event.Name != model.EventBankDeactivated || event.Payload.Bank != bankHDFC {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	_, err := s.invoke("deactivateBank", bankHDFC)
+	expectError(t, err, "is already inactive")
+	_, err = s.invoke("deactivateBank", "ICIC0000001")
+	expectError(t, err, "is not registered")
+	_, err = s.invoke("deactivateBank")
+	expectError(t, err, "Expecting 1")
+}
+
+func TestReadBank(t *testing.T) {
+	s := newFixture(t)
+	s.asCustomer(aadhaarA)
+	var bank model.Bank
+	decodeJSON(t, s.mustQuery(t, "readBank", " sbin0000001"), &bank)
+	if bank.Code != bankSBI || bank.LegalName != "State Bank" {
+		t.Fatalf("unexpected bank %+v", bank)
+	}
+
+	_, err := s.query("readBank", "ICIC0000001")
+	expectError(t, err, "is not registered")
+	_, err = s.query("readBank")
+	expectError(t, err, "Expecting 1: readBank(code)")
+}
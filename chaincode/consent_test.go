@@ -0,0 +1,248 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+)
+
+func TestGrantConsent(t *testing.T) {
+	cases := []struct {
+		name      string
+		caller    func(s *testStub)
+		args      []string
+		grantedBy string
+		want      string
+	}{
+		{"by the customer", customer(aadhaarA), []string{aadhaarA, bankHDFC, model.PurposeLoan, "30"}, access.RoleCustomer, ""},
+		{"by the verifying bank", bank(bankSBI), []string{aadhaarA, " hdfc0000001", "LOAN", "30"}, bankSBI, ""},
+		{"by another bank", bank(bankHDFC), []string{aadhaarA, bankHDFC, model.PurposeLoan, "30"}, "", "only the customer or their onboarding bank"},
+		{"by another customer", customer(aadhaarB), []string{aadhaarA, bankHDFC, model.PurposeLoan, "30"}, "", "only the customer or their onboarding bank"},
+		{"unknown purpose", customer(aadhaarA), []string{aadhaarA, bankHDFC, "marketing", "30"}, "", "Invalid purpose"},
+		{"zero days", customer(aadhaarA), []string{aadhaarA, bankHDFC, model.PurposeLoan, "0"}, "", "Invalid days"},
+		{"too many days", customer(aadhaarA), []string{aadhaarA, bankHDFC, model.PurposeLoan, "3651"}, "", "Invalid days"},
+		{"malformed bank", customer(aadhaarA), []string{aadhaarA, "HDFC_0000001", model.PurposeLoan, "30"}, "", "Invalid bank: must be an IFSC style code"},
+		{"unregistered bank", customer(aadhaarA), []string{aadhaarA, "ICIC0000001", model.PurposeLoan, "30"}, "", "is not an active member bank"},
+		{"no record", customer(aadhaarB), []string{aadhaarB, bankHDFC, model.PurposeLoan, "30"}, "", "No KYC record"},
+		{"too few arguments", customer(aadhaarA), []string{aadhaarA, bankHDFC, model.PurposeLoan}, "", "Expecting 4"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newFixture(t)
+			c.caller(s)
+			_, err := s.invoke("grantConsent", c.args...)
+			expectError(t, err, c.want)
+			if c.want != "" {
+				return
+			}
+			consent, err := storage.GetConsent(s, storage.ConsentKey(s.ref(t, aadhaarA), bankHDFC, model.PurposeLoan))
+			if err != nil || consent == nil {
+				t.Fatalf("consent was not stored: %v", err)
+			}
+			if consent.GrantedBy != c.grantedBy || consent.ExpiresAt != s.nowMs()+30*model.MsPerDay {
+				t.Fatalf("unexpected consent %+v", consent)
+			}
+		})
+	}
+}
+
+func TestGrantConsentRefusals(t *testing.T) {
+	s := newFixture(t)
+	s.as(s.asRegulator, func() { s.mustInvoke(t, "deactivateBank", bankHDFC) })
+	s.asCustomer(aadhaarA)
+	_, err := s.invoke("grantConsent", aadhaarA, bankHDFC, model.PurposeLoan, "30")
+	expectError(t, err, "is not an active member bank")
+
+	s.as(func() { s.asBank(bankSBI) }, func() { s.mustInvoke(t, "suspend", aadhaarA, "under review") })
+	_, err = s.invoke("grantConsent", aadhaarA, bankSBI, model.PurposeLoan, "30")
+	expectError(t, err, "Cannot share a KYC record that is suspended")
+}
+
+func TestConsentGatesReads(t *testing.T) {
+	s := newFixture(t)
+	s.asBank(bankHDFC)
+	_, err := s.query("disclose", aadhaarA, model.PurposeLoan)
+	expectError(t, err, "has no valid consent for loan")
+
+	s.as(func() { s.asCustomer(aadhaarA) }, func() { s.mustInvoke(t, "grantConsent", aadhaarA, bankHDFC, model.PurposeLoan, "30") })
+	var disclosure model.Disclosure //a consent is for a purpose, read hands over what the purpose discloses
+	decodeJSON(t, s.mustQuery(t, "read", aadhaarA), &disclosure)
+	if _, ok := disclosure.Fields["riskCategory"]; !ok || disclosure.Purpose != model.PurposeLoan {
+		t.Fatalf("consented bank did not get the customer's risk category: %+v", disclosure)
+	}
+	if _, ok := disclosure.Fields["evidence"]; ok {
+		t.Fatalf("read disclosed more than the loan profile: %+v", disclosure)
+	}
+	decodeJSON(t, s.mustQuery(t, "disclose", aadhaarA, model.PurposeLoan), &disclosure)
+	if _, ok := disclosure.Fields["riskCategory"]; !ok {
+		t.Fatalf("consented bank did not get the customer's risk category: %+v", disclosure)
+	}
+
+	_, err = s.query("disclose", aadhaarA, model.PurposeInsurance) //the consent is for loans only
+	expectError(t, err, "has no valid consent for insurance")
+	_, err = s.query("read", aadhaarA, model.PurposeInsurance)
+	expectError(t, err, "has no valid consent for insurance")
+
+	s.as(func() { s.asCustomer(aadhaarA) }, func() { s.mustInvoke(t, "grantConsent", aadhaarA, bankHDFC, model.PurposeInsurance, "10") })
+	_, err = s.query("read", aadhaarA) //two consents, read has to be told which
+	expectError(t, err, "holds consents for loan, insurance")
+	decodeJSON(t, s.mustQuery(t, "read", aadhaarA, model.PurposeInsurance), &disclosure)
+	if disclosure.Purpose != model.PurposeInsurance {
+		t.Fatalf("read under the wrong consent: %+v", disclosure)
+	}
+
+	s.advance(days(30))
+	_, err = s.query("disclose", aadhaarA, model.PurposeLoan)
+	expectError(t, err, "has no valid consent for loan")
+	_, err = s.query("read", aadhaarA)
+	expectCode(t, err, model.CodePermissionDenied)
+	expectError(t, err, "did not verify this KYC record and has no valid consent to read it")
+}
+
+func TestRevokeConsent(t *testing.T) {
+	s := newFixture(t)
+	s.asCustomer(aadhaarA)
+	s.mustInvoke(t, "grantConsent", aadhaarA, bankHDFC, model.PurposeLoan, "30")
+	s.advance(days(1))
+	s.mustInvoke(t, "revokeConsent", aadhaarA, bankHDFC, model.PurposeLoan)
+
+	consent, _ := storage.GetConsent(s, storage.ConsentKey(s.ref(t, aadhaarA), bankHDFC, model.PurposeLoan))
+	if consent.RevokedAt != s.nowMs() || consent.RevokedBy != access.RoleCustomer {
+		t.Fatalf("consent was not revoked: %+v", consent)
+	}
+	s.as(func() { s.asBank(bankHDFC) }, func() {
+		_, err := s.query("disclose", aadhaarA, model.PurposeLoan)
+		expectError(t, err, "has no valid consent for loan")
+	})
+
+	_, err := s.invoke("revokeConsent", aadhaarA, bankHDFC, model.PurposeLoan)
+	expectError(t, err, "Consent is already revoked")
+	_, err = s.invoke("revokeConsent", aadhaarA, bankHDFC, model.PurposeInsurance)
+	expectError(t, err, "No consent for "+bankHDFC)
+	_, err = s.invoke("revokeConsent", aadhaarA, bankHDFC)
+	expectError(t, err, "Expecting 3")
+	s.as(func() { s.asBank(bankHDFC) }, func() {
+		_, err = s.invoke("revokeConsent", aadhaarA, bankHDFC, model.PurposeLoan)
+		expectError(t, err, "only the customer or their onboarding bank")
+	})
+}
+
+func TestReadConsents(t *testing.T) {
+	s := newFixture(t)
+	s.asCustomer(aadhaarA)
+	s.mustInvoke(t, "grantConsent", aadhaarA, bankHDFC, model.PurposeLoan, "30")
+	s.mustInvoke(t, "grantConsent", aadhaarA, bankHDFC, model.PurposeLoan, "60") //replaces the first, the index keeps one entry
+	s.mustInvoke(t, "grantConsent", aadhaarA, bankHDFC, model.PurposeInsurance, "30")
+	s.mustInvoke(t, "revokeConsent", aadhaarA, bankHDFC, model.PurposeInsurance)
+
+	var consents []model.Consent
+	decodeJSON(t, s.mustQuery(t, "readConsents", aadhaarA), &consents)
+	if len(consents) != 2 {
+		t.Fatalf("expecting 2 consents, got %+v", consents)
+	}
+	if consents[0].ExpiresAt != s.nowMs()+60*model.MsPerDay || consents[1].RevokedAt == 0 {
+		t.Fatalf("unexpected consents %+v", consents)
+	}
+
+	s.asAuditor()
+	s.mustQuery(t, "readConsents", aadhaarA)
+	s.asBank(bankHDFC)
+	_, err := s.query("readConsents", aadhaarA)
+	expectError(t, err, "only the customer or their onboarding bank")
+}
+
+func TestShareRequests(t *testing.T) {
+	cases := []struct {
+		name    string
+		decider func(s *testStub)
+		approve bool
+		wait    int //days between the request and the decision
+		status  string
+		want    string
+	}{
+		{"customer approves", customer(aadhaarA), true, 0, model.ShareApproved, ""},
+		{"verifying bank approves", bank(bankSBI), true, 0, model.ShareApproved, ""},
+		{"customer rejects", customer(aadhaarA), false, 0, model.ShareRejected, ""},
+		{"requester approves its own", bank(bankHDFC), true, 0, model.SharePending, "only the customer or their onboarding bank"},
+		{"too late", customer(aadhaarA), true, model.ShareRequestDays, model.ShareExpired, "is expired"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newFixture(t)
+			id := requestFromHDFC(t, s)[0]
+			s.advance(days(c.wait))
+			c.decider(s)
+			var err error
+			if c.approve {
+				_, err = s.invoke("approveRequest", id)
+			} else {
+				_, err = s.invoke("rejectRequest", id, "not a customer of ours")
+			}
+			expectError(t, err, c.want)
+
+			s.asRegulator()
+			var requests []model.ShareRequest
+			decodeJSON(t, s.mustQuery(t, "readShareRequests", aadhaarA), &requests)
+			if len(requests) != 1 || requests[0].ID != id || requests[0].Status != c.status {
+				t.Fatalf("unexpected requests %+v, expecting one %s", requests, c.status)
+			}
+			consent, _ := storage.GetConsent(s, storage.ConsentKey(s.ref(t, aadhaarA), bankHDFC, model.PurposeLoan))
+			if (consent != nil) != (c.status == model.ShareApproved) {
+				t.Fatalf("request %s left consent %+v", c.status, consent)
+			}
+		})
+	}
+}
+
+func TestRequestKYC(t *testing.T) {
+	s := newFixture(t)
+	s.createBank(t, bankSpec{Code: "ICIC0000001"})
+	s.asBank(bankHDFC)
+	id := string(s.mustInvoke(t, "requestKYC", aadhaarA, model.PurposeLoan, "30"))
+	if id != s.lastTxID() {
+		t.Fatalf("request id %s is not the transaction id %s", id, s.lastTxID())
+	}
+
+	_, err := s.invoke("requestKYC", aadhaarA, model.PurposeLoan, "30")
+	expectError(t, err, "is already pending")
+	s.mustInvoke(t, "requestKYC", aadhaarA, model.PurposeInsurance, "30")
+	_, err = s.invoke("requestKYC", aadhaarA, "marketing", "30")
+	expectError(t, err, "Invalid purpose")
+	_, err = s.invoke("requestKYC", aadhaarB, model.PurposeLoan, "30")
+	expectError(t, err, "No KYC record")
+	s.as(func() { s.asBank(bankSBI) }, func() {
+		_, err = s.invoke("requestKYC", aadhaarA, model.PurposeLoan, "30")
+		expectError(t, err, "can already read the record")
+	})
+
+	s.asBank("ICIC0000001") //a third bank only sees requests it made or owns
+	var requests []model.ShareRequest
+	decodeJSON(t, s.mustQuery(t, "readShareRequests", aadhaarA), &requests)
+	if len(requests) != 0 {
+		t.Fatalf("third bank sees %+v", requests)
+	}
+	s.asCustomer(aadhaarB)
+	_, err = s.query("readShareRequests", aadhaarA)
+	expectError(t, err, "customers can only see requests for their own KYC")
+}
@@ -0,0 +1,235 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+)
+
+func TestDisclose(t *testing.T) {
+	cases := []struct {
+		name    string
+		caller  string
+		consent string //purpose HDFC holds a consent for, empty for none
+		purpose string
+		fields  []string
+		omitted map[string]string
+		want    string
+	}{
+		{"verifying bank", bankSBI, "", model.PurposeAccountOpening, []string{"documents", "evidence", "institution", "level", "pan", "status", "verifiedAt"},
+			map[string]string{"offline": model.OmittedNotPresent, "riskCategory": model.OmittedByProfile, "nextReviewDue": model.OmittedByProfile}, ""},
+		{"insurance consent", bankHDFC, model.PurposeInsurance, model.PurposeInsurance, []string{"institution", "level", "status", "verifiedAt"},
+			map[string]string{"pan": model.OmittedByProfile, "documents": model.OmittedByProfile, "evidence": model.OmittedByProfile, "riskCategory": model.OmittedByProfile, "nextReviewDue": model.OmittedByProfile}, ""},
+		{"loan consent", bankHDFC, model.PurposeLoan, model.PurposeLoan, []string{"institution", "level", "riskCategory", "status", "verifiedAt"},
+			map[string]string{"pan": model.OmittedNotShared, "documents": model.OmittedNotShared, "evidence": model.OmittedByProfile, "nextReviewDue": model.OmittedByProfile}, ""},
+		{"consent for another purpose", bankHDFC, model.PurposeInsurance, model.PurposeLoan, nil, nil, "has no valid consent for loan"},
+		{"no consent", bankHDFC, "", model.PurposeInsurance, nil, nil, "has no valid consent for insurance"},
+		{"unknown purpose", bankSBI, "", "marketing", nil, nil, "Invalid purpose"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newFixture(t)
+			if c.consent != "" {
+				s.as(func() { s.asCustomer(aadhaarA) }, func() { s.mustInvoke(t, "grantConsent", aadhaarA, bankHDFC, c.consent, "30") })
+			}
+			s.asBank(c.caller)
+			out, err := s.query("disclose", aadhaarA, c.purpose)
+			expectError(t, err, c.want)
+			if c.want != "" {
+				return
+			}
+			var disclosure model.Disclosure
+			decodeJSON(t, out, &disclosure)
+			names := []string{}
+			for name := range disclosure.Fields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, c.fields) {
+				t.Fatalf("disclosed %q, expecting %q", names, c.fields)
+			}
+			for field, reason := range c.omitted {
+				if disclosure.Omitted[field] != reason {
+					t.Fatalf("%s omitted as %q, expecting %q, all omitted %v", field, disclosure.Omitted[field], reason, disclosure.Omitted)
+				}
+			}
+			if disclosure.Bank != c.caller || (c.caller == bankHDFC) != (disclosure.ExpiresAt != 0) {
+				t.Fatalf("unexpected disclosure %+v", disclosure)
+			}
+		})
+	}
+}
+
+func TestDiscloseDetails(t *testing.T) {
+	s := newFixture(t)
+	s.asBank(bankSBI)
+	s.mustInvoke(t, "setDetails", aadhaarA, "Asha Rao", "1990-01-01", "12 MG Road, Bengaluru 560001")
+	var disclosure model.Disclosure
+	decodeJSON(t, s.mustQuery(t, "disclose", aadhaarA, model.PurposeInsurance), &disclosure)
+	if string(disclosure.Fields["name"]) != `"Asha Rao"` || string(disclosure.Fields["dob"]) != `"1990-01-01"` {
+		t.Fatalf("name and date of birth not disclosed for insurance: %+v", disclosure)
+	}
+	if disclosure.Omitted["address"] != model.OmittedByProfile {
+		t.Fatalf("address omitted as %q, expecting %q", disclosure.Omitted["address"], model.OmittedByProfile)
+	}
+
+	s.mustInvoke(t, "grantConsent", aadhaarA, bankHDFC, model.PurposeInsurance, "30")
+	s.mustInvoke(t, "setDetails", aadhaarA, "Asha R Rao") //sealed again for the consent
+	s.as(func() { s.asBank(bankHDFC) }, func() {
+		decodeJSON(t, s.mustQuery(t, "disclose", aadhaarA, model.PurposeInsurance), &disclosure)
+	})
+	if string(disclosure.Fields["name"]) != `"Asha R Rao"` {
+		t.Fatalf("consented bank did not get the changed name: %+v", disclosure)
+	}
+
+	s.mustInvoke(t, "setDetails", aadhaarA, "", "", "14 MG Road, Bengaluru 560001") //the others stay
+	var rec model.KYCRecord
+	decodeJSON(t, s.mustQuery(t, "read", aadhaarA), &rec)
+	if rec.Subject.Name != "Asha R Rao" || rec.Subject.Address != "14 MG Road, Bengaluru 560001" || rec.Subject.PAN == "" {
+		t.Fatalf("unexpected details %+v", rec.Subject)
+	}
+	_, err := s.invoke("setDetails", aadhaarA)
+	expectError(t, err, "pass at least one of name, dob and address")
+	_, err = s.invoke("setDetails", aadhaarA, "", "01-01-1990")
+	expectError(t, err, "Invalid dob")
+	s.asBank(bankHDFC)
+	_, err = s.invoke("setDetails", aadhaarA, "Someone Else")
+	expectError(t, err, "Permission denied: only "+bankSBI)
+}
+
+func TestDiscloseEncrypted(t *testing.T) {
+	s := newEncryptedFixture(t)
+	s.asBank(bankSBI)
+	s.withKeys(map[string][]byte{})
+	var disclosure model.Disclosure
+	decodeJSON(t, s.mustQuery(t, "disclose", aadhaarB, model.PurposeAccountOpening), &disclosure)
+	if disclosure.Omitted["pan"] != model.OmittedEncrypted || disclosure.Omitted["documents"] != model.OmittedEncrypted {
+		t.Fatalf("encrypted details not reported as such: %+v", disclosure.Omitted)
+	}
+
+	s.withKeys(map[string][]byte{storage.TransientKey: keyOne})
+	decodeJSON(t, s.mustQuery(t, "disclose", aadhaarB, model.PurposeAccountOpening), &disclosure)
+	if string(disclosure.Fields["pan"]) != `"ABCPE1234F"` {
+		t.Fatalf("pan not disclosed with the key: %+v", disclosure)
+	}
+
+	s.as(func() { s.asCustomer(aadhaarB) }, func() { s.mustInvoke(t, "grantConsent", aadhaarB, bankHDFC, model.PurposeLoan, "30") })
+	s.asBank(bankHDFC)
+	s.withKeys(nil) //HDFC's own key
+	disclosure = model.Disclosure{}
+	decodeJSON(t, s.mustQuery(t, "disclose", aadhaarB, model.PurposeLoan), &disclosure)
+	if disclosure.Omitted["pan"] != model.OmittedNotShared {
+		t.Fatalf("details the customer's consent has not had sealed yet reported as %q", disclosure.Omitted["pan"])
+	}
+
+	s.as(func() { s.asBank(bankSBI) }, func() {
+		s.withKeys(map[string][]byte{storage.TransientKey: keyOne})
+		s.mustInvoke(t, "shareDetails", aadhaarB)
+	})
+	cases := []struct {
+		name string
+		keys map[string][]byte
+		pan  bool
+		want string
+	}{
+		{"own key", nil, true, ""},
+		{"no key", map[string][]byte{}, false, ""},
+		{"the verifying bank's key", map[string][]byte{storage.TransientKey: keyOne}, false, "is not the key"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s.withKeys(c.keys)
+			defer s.withKeys(nil)
+			out, err := s.query("disclose", aadhaarB, model.PurposeLoan)
+			expectError(t, err, c.want)
+			if c.want != "" {
+				return
+			}
+			disclosure := model.Disclosure{}
+			decodeJSON(t, out, &disclosure)
+			if c.pan != (string(disclosure.Fields["pan"]) == `"ABCPE1234F"`) || (!c.pan && disclosure.Omitted["pan"] != model.OmittedEncrypted) {
+				t.Fatalf("HDFC got %+v", disclosure)
+			}
+		})
+	}
+
+	loanKey := storage.ConsentKey(s.ref(t, aadhaarB), bankHDFC, model.PurposeLoan)
+	insuranceKey := storage.ConsentKey(s.ref(t, aadhaarB), bankHDFC, model.PurposeInsurance)
+	s.as(func() { s.asBank(bankSBI) }, func() { //granted with the key, sealed straight away with only what insurance discloses
+		s.withKeys(map[string][]byte{storage.TransientKey: keyOne})
+		s.mustInvoke(t, "grantConsent", aadhaarB, bankHDFC, model.PurposeInsurance, "10")
+	})
+	var sealed model.EncryptedPII
+	decodeJSON(t, s.MockStub.State[storage.SharedPIIKey(insuranceKey)], &sealed)
+	plaintext, err := storage.OpenPII(sealed, bankKey(bankHDFC), storage.SharedPIIKey(insuranceKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(plaintext), "ABCPE1234F") || strings.Contains(string(plaintext), "K1234567") {
+		t.Fatalf("insurance copy holds details its profile leaves out: %s", plaintext)
+	}
+
+	s.withKeys(map[string][]byte{storage.TransientKey: bankKey(bankHDFC), storage.TransientNewKey: keyTwo})
+	s.mustInvoke(t, "rotateKey") //the copies follow HDFC's key
+	s.withKeys(map[string][]byte{storage.TransientKey: keyTwo})
+	decodeJSON(t, s.mustQuery(t, "disclose", aadhaarB, model.PurposeLoan), &disclosure)
+	if string(disclosure.Fields["pan"]) != `"ABCPE1234F"` {
+		t.Fatalf("copy not readable with HDFC's new key: %+v", disclosure)
+	}
+
+	s.as(func() { s.asCustomer(aadhaarB) }, func() { s.mustInvoke(t, "revokeConsent", aadhaarB, bankHDFC, model.PurposeLoan) })
+	if _, ok := s.MockStub.State[storage.SharedPIIKey(loanKey)]; ok {
+		t.Fatal("revoking the consent left the details sealed for it")
+	}
+	s.advance(days(11))
+	s.as(s.asRegulator, func() { s.mustInvoke(t, "dropExpiredShares") })
+	if _, ok := s.MockStub.State[storage.SharedPIIKey(insuranceKey)]; ok {
+		t.Fatal("expired consent kept the details sealed for it")
+	}
+}
+
+func TestReadProfiles(t *testing.T) {
+	s := newFixture(t)
+	s.asCustomer(aadhaarA)
+	var profiles map[string][]string
+	decodeJSON(t, s.mustQuery(t, "readProfiles"), &profiles)
+	if len(profiles) != len(model.ConsentPurposes) {
+		t.Fatalf("expecting a profile per purpose, got %q", profiles)
+	}
+	allowed := map[string]bool{}
+	for _, field := range model.DisclosableFields {
+		allowed[field] = true
+	}
+	for purpose, fields := range profiles {
+		for _, field := range fields {
+			if !allowed[field] {
+				t.Errorf("profile %s lists %s, which is not disclosable", purpose, field)
+			}
+		}
+	}
+	_, err := s.query("readProfiles", "loan")
+	expectError(t, err, "Expecting 0")
+}
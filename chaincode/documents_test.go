@@ -0,0 +1,128 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+)
+
+var panDigest = strings.Repeat("ab", 32)
+
+func TestAttachDocument(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string //after the aadhar number
+		want string
+	}{
+		{"pan card", []string{model.DocPANCard, panDigest, "Income Tax Department", "", "https://docs.example/pan"}, ""},
+		{"upper case digest", []string{"PASSPORT", strings.ToUpper(panDigest), "MEA", "2030-12-31", "s3://kyc/passport"}, ""},
+		{"unknown type", []string{"selfie", panDigest, "Bank", "", "https://docs.example/x"}, "Invalid type"},
+		{"short digest", []string{model.DocPANCard, "abcd", "Income Tax Department", "", "https://docs.example/pan"}, "Invalid sha256"},
+		{"no issuer", []string{model.DocPANCard, panDigest, " ", "", "https://docs.example/pan"}, "Invalid issuer: required"},
+		{"bad expiry", []string{model.DocPANCard, panDigest, "Income Tax Department", "31/12/2030", "https://docs.example/pan"}, "Invalid expiry"},
+		{"relative uri", []string{model.DocPANCard, panDigest, "Income Tax Department", "", "docs/pan.pdf"}, "Invalid uri"},
+		{"too few arguments", []string{model.DocPANCard, panDigest, "Income Tax Department", ""}, "Expecting 6"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newFixture(t)
+			s.asBank(bankSBI)
+			_, err := s.invoke("attachDocument", append([]string{aadhaarA}, c.args...)...)
+			expectError(t, err, c.want)
+			if c.want != "" {
+				return
+			}
+			rec := s.record(t, aadhaarA)
+			if len(rec.Evidence) != 1 || rec.Evidence[0].SHA256 != panDigest || rec.Evidence[0].AnchoredBy != bankSBI {
+				t.Fatalf("unexpected evidence %+v", rec.Evidence)
+			}
+		})
+	}
+}
+
+func TestAttachDocumentRefusals(t *testing.T) {
+	s := newFixture(t)
+	s.asBank(bankSBI)
+	args := []string{aadhaarA, model.DocPANCard, panDigest, "Income Tax Department", "", "https://docs.example/pan"}
+	s.mustInvoke(t, "attachDocument", args...)
+	_, err := s.invoke("attachDocument", args...)
+	expectError(t, err, "is already attached")
+
+	s.asBank(bankHDFC)
+	_, err = s.invoke("attachDocument", args...)
+	expectError(t, err, "Permission denied: only "+bankSBI)
+
+	s.as(s.asRegulator, func() { s.mustInvoke(t, "revoke", aadhaarA, "fraud") })
+	s.asBank(bankSBI)
+	args[2] = strings.Repeat("cd", 32)
+	_, err = s.invoke("attachDocument", args...)
+	expectError(t, err, "Cannot attach documents to a KYC record that is revoked")
+}
+
+func TestVerifyDocument(t *testing.T) {
+	s := newFixture(t)
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarB})
+	s.asBank(bankSBI)
+	s.mustInvoke(t, "attachDocument", aadhaarA, model.DocPassport, panDigest, "MEA", "2026-06-30", "https://docs.example/a")
+	s.mustInvoke(t, "attachDocument", aadhaarB, model.DocPassport, panDigest, "MEA", "", "https://docs.example/b")
+
+	cases := []struct {
+		name    string
+		advance int
+		args    []string
+		matches int
+		expired bool
+		want    string
+	}{
+		{"anchored twice", 0, []string{panDigest}, 2, false, ""},
+		{"one customer", 0, []string{strings.ToUpper(panDigest), aadhaarA}, 1, false, ""},
+		{"after expiry", 200, []string{panDigest, aadhaarA}, 1, true, ""},
+		{"not anchored", 0, []string{strings.Repeat("ef", 32)}, 0, false, ""},
+		{"customer without it", 0, []string{panDigest, aadhaarC}, 0, false, ""},
+		{"bad digest", 0, []string{"xyz"}, 0, false, "Invalid sha256"},
+		{"no arguments", 0, []string{}, 0, false, "Expecting 1 or 2"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			saved := s.now
+			defer func() { s.now = saved }()
+			s.advance(days(c.advance))
+			out, err := s.query("verifyDocument", c.args...)
+			expectError(t, err, c.want)
+			if c.want != "" {
+				return
+			}
+			var result model.DocumentVerification
+			decodeJSON(t, out, &result)
+			if len(result.Matches) != c.matches || result.Anchored != (c.matches > 0) {
+				t.Fatalf("unexpected result %+v", result)
+			}
+			if c.matches == 1 && result.Matches[0].Expired != c.expired {
+				t.Fatalf("expired is %v, expecting %v", result.Matches[0].Expired, c.expired)
+			}
+		})
+	}
+	if strings.Contains(string(s.mustQuery(t, "verifyDocument", panDigest)), "docs.example") {
+		t.Fatal("verifyDocument gave away the storage uri")
+	}
+}
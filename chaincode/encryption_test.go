@@ -0,0 +1,244 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+)
+
+var (
+	keyOne = bytes.Repeat([]byte{1}, 32)
+	keyTwo = bytes.Repeat([]byte{2}, 32)
+)
+
+// newEncryptedFixture has SBI onboard aadhaarB with its details encrypted under keyOne
+func newEncryptedFixture(t *testing.T) *testStub {
+	s := newFixture(t)
+	s.withKeys(map[string][]byte{storage.TransientKey: keyOne})
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarB, PAN: "ABCPE1234F", Documents: []string{"passport:K1234567"}})
+	s.withKeys(nil)
+	return s
+}
+
+func TestEncryptedPII(t *testing.T) {
+	s := newEncryptedFixture(t)
+	rec := s.record(t, aadhaarB)
+	if rec.PII == nil || !rec.PII.Encrypted {
+		t.Fatalf("digest does not say the details are encrypted: %+v", rec.PII)
+	}
+	var sealed model.EncryptedPII
+	decodeJSON(t, s.MockStub.State[storage.PIIKey(storage.KYCKey(rec.Subject.AadharRef))], &sealed)
+	if sealed.KeyID != storage.KeyID(keyOne) || sealed.Institution != bankSBI || len(sealed.Nonce) != 12 {
+		t.Fatalf("unexpected envelope %+v", sealed)
+	}
+	if bytes.Contains(sealed.Ciphertext, []byte("ABCPE1234F")) {
+		t.Fatal("PAN is in the clear")
+	}
+
+	cases := []struct {
+		name    string
+		keys    map[string][]byte
+		details bool
+		want    string
+	}{
+		{"with the key", map[string][]byte{storage.TransientKey: keyOne}, true, ""},
+		{"without a key", map[string][]byte{}, false, ""},
+		{"with another key", map[string][]byte{storage.TransientKey: keyTwo}, false, "is not the key " + storage.KeyID(keyOne)},
+		{"with a short key", map[string][]byte{storage.TransientKey: keyOne[:16]}, false, "must be a 32 byte AES-256 key"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s.asBank(bankSBI)
+			s.withKeys(c.keys)
+			defer s.withKeys(nil)
+			out, err := s.query("read", aadhaarB)
+			expectError(t, err, c.want)
+			if c.want != "" {
+				return
+			}
+			var rec model.KYCRecord
+			decodeJSON(t, out, &rec)
+			if rec.HasInlinePII() != c.details || (c.details && rec.Subject.PAN != "ABCPE1234F") {
+				t.Fatalf("unexpected details %+v", rec)
+			}
+		})
+	}
+
+	s.metadata = []byte("not json")
+	_, err := s.query("read", aadhaarB)
+	expectError(t, err, "Invalid caller metadata")
+}
+
+func TestRotateKey(t *testing.T) {
+	s := newEncryptedFixture(t)
+	s.asBank(bankSBI)
+	s.withKeys(map[string][]byte{storage.TransientKey: keyOne, storage.TransientNewKey: keyTwo})
+	s.mustInvoke(t, "rotateKey")
+
+	s.withKeys(map[string][]byte{storage.TransientKey: keyOne})
+	_, err := s.query("read", aadhaarB)
+	expectError(t, err, "is not the key "+storage.KeyID(keyTwo))
+	s.withKeys(map[string][]byte{storage.TransientKey: keyTwo})
+	var rec model.KYCRecord
+	decodeJSON(t, s.mustQuery(t, "read", aadhaarB), &rec)
+	if rec.Subject.PAN != "ABCPE1234F" {
+		t.Fatal("details did not survive the rotation")
+	}
+
+	var other model.EncryptedPII //aadhaarA's details are under SBI's usual key and stay there
+	decodeJSON(t, s.MockStub.State[storage.PIIKey(storage.KYCKey(s.ref(t, aadhaarA)))], &other)
+	if other.KeyID != storage.KeyID(bankKey(bankSBI)) {
+		t.Fatal("rotation touched details under another key")
+	}
+
+	cases := []struct {
+		name string
+		keys map[string][]byte
+		args []string
+		want string
+	}{
+		{"no new key", map[string][]byte{storage.TransientKey: keyTwo}, nil, "must carry kycNewKey"},
+		{"same key", map[string][]byte{storage.TransientKey: keyTwo, storage.TransientNewKey: keyTwo}, nil, "must differ from"},
+		{"key as an argument", map[string][]byte{storage.TransientKey: keyTwo, storage.TransientNewKey: keyOne}, []string{"key"}, "Expecting 0"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s.withKeys(c.keys)
+			_, err := s.invoke("rotateKey", c.args...)
+			expectError(t, err, c.want)
+		})
+	}
+}
+
+func TestRotateKeyOnlyOwnEnvelopes(t *testing.T) {
+	s := newEncryptedFixture(t)
+	s.asBank(bankHDFC) //holds keyOne too, but the envelope is SBI's
+	s.withKeys(map[string][]byte{storage.TransientKey: keyOne, storage.TransientNewKey: keyTwo})
+	s.mustInvoke(t, "rotateKey")
+	if !strings.Contains(string(s.MockStub.State[storage.PIIKey(storage.KYCKey(s.ref(t, aadhaarB)))]), storage.KeyID(keyOne)) {
+		t.Fatal("another bank re-encrypted SBI's customer details")
+	}
+}
+
+func TestEncryptedPIIFollowsClosing(t *testing.T) {
+	s := newEncryptedFixture(t)
+	ref := s.ref(t, aadhaarB)
+	s.mustInvoke(t, "delete", aadhaarB, "customer left")
+	s.asBank(bankSBI)
+	s.withKeys(map[string][]byte{storage.TransientKey: keyOne, storage.TransientNewKey: keyTwo})
+	s.mustInvoke(t, "rotateKey")
+
+	var sealed model.EncryptedPII
+	decodeJSON(t, s.MockStub.State[storage.PIIKey(storage.ClosedKYCKey(ref, s.nowMs()))], &sealed)
+	if sealed.KeyID != storage.KeyID(keyTwo) {
+		t.Fatalf("closed record's details are under key %s, expecting %s", sealed.KeyID, storage.KeyID(keyTwo))
+	}
+	plaintext, err := storage.OpenPII(sealed, keyTwo, storage.ClosedKYCKey(ref, s.nowMs()))
+	if err != nil || !bytes.Contains(plaintext, []byte("ABCPE1234F")) {
+		t.Fatalf("closed record's details do not open: %v", err)
+	}
+}
+
+func TestPIIOnlyStoredEncrypted(t *testing.T) {
+	s := newFixture(t)
+	s.asBank(bankSBI)
+	s.withKeys(map[string][]byte{})
+	_, err := s.invoke("init_marble", aadhaarB, "ABCPE1234F", model.LevelFull, bankSBI)
+	e := expectCode(t, err, model.CodeInvalidArgument)
+	if e.Field != storage.TransientKey {
+		t.Fatalf("error names %q, expecting %q", e.Field, storage.TransientKey)
+	}
+	if rec := s.record(t, aadhaarB); rec != nil {
+		t.Fatalf("record stored without its details: %+v", rec)
+	}
+	s.mustInvoke(t, "init_marble", aadhaarB, "", model.LevelOTP, bankSBI) //no details, nothing to encrypt
+}
+
+func TestSealClearPII(t *testing.T) {
+	s := newFixture(t)
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarB, PAN: "ABCPE1234F", Institution: bankHDFC})
+	for _, aadharNum := range []string{aadhaarA, aadhaarB} { //as Init leaves details it moved off older records
+		rec := s.record(t, aadharNum)
+		pii := model.CustomerPII{PAN: "ABCPE1234F"}
+		piiAsBytes, _ := json.Marshal(pii)
+		sum := sha256.Sum256(piiAsBytes)
+		rec.PII.Encrypted = false
+		rec.PII.SHA256 = hex.EncodeToString(sum[:])
+		s.MockStub.State[storage.PIIKey(storage.KYCKey(rec.Subject.AadharRef))] = piiAsBytes
+		s.MockStub.State[storage.KYCKey(rec.Subject.AadharRef)], _ = json.Marshal(rec)
+	}
+	s.asRegulator()
+	s.mustInvoke(t, "delete", aadhaarA, "customer left") //closed details are sealed too
+
+	s.asBank(bankSBI)
+	s.withKeys(map[string][]byte{storage.TransientNewKey: keyOne})
+	s.mustInvoke(t, "rotateKey")
+
+	var closed []model.ClosedKYCRecord
+	s.as(s.asAuditor, func() { decodeJSON(t, s.mustQuery(t, "readClosed", aadhaarA), &closed) })
+	if len(closed) != 1 || !closed[0].Record.PII.Encrypted {
+		t.Fatalf("closed record does not say its details are encrypted: %+v", closed)
+	}
+	var sealed model.EncryptedPII
+	decodeJSON(t, s.MockStub.State[storage.PIIKey(storage.ClosedKYCKey(s.ref(t, aadhaarA), s.nowMs()))], &sealed)
+	if sealed.KeyID != storage.KeyID(keyOne) || sealed.Institution != bankSBI {
+		t.Fatalf("closed record's details are not sealed under SBI's new key: %+v", sealed)
+	}
+	if !strings.Contains(string(s.MockStub.State[storage.PIIKey(storage.KYCKey(s.ref(t, aadhaarB)))]), `"pan":"ABCPE1234F"`) {
+		t.Fatal("SBI sealed HDFC's customer")
+	}
+}
+
+func TestNonceBoundToStateKey(t *testing.T) {
+	s := newEncryptedFixture(t)
+	ref := s.ref(t, aadhaarB)
+	closedAt := s.nowMs()
+	s.mustInvoke(t, "delete", aadhaarB, "customer left")
+	s.advance(days(1))
+	s.withKeys(map[string][]byte{storage.TransientKey: keyOne})
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarB, PAN: "ABCPE1234F"}) //onboarded again, same customer under the live key
+
+	s.asBank(bankSBI)
+	s.withKeys(map[string][]byte{storage.TransientKey: keyOne, storage.TransientNewKey: keyTwo})
+	s.mustInvoke(t, "rotateKey") //one transaction reseals both
+
+	var live, closed model.EncryptedPII
+	decodeJSON(t, s.MockStub.State[storage.PIIKey(storage.KYCKey(ref))], &live)
+	decodeJSON(t, s.MockStub.State[storage.PIIKey(storage.ClosedKYCKey(ref, closedAt))], &closed)
+	if bytes.Equal(live.Nonce, closed.Nonce) {
+		t.Fatal("the live and closed details were sealed with the same nonce")
+	}
+	if live.StateKey != storage.KYCKey(ref) || closed.StateKey != storage.ClosedKYCKey(ref, closedAt) {
+		t.Fatalf("envelopes bound to %q and %q", live.StateKey, closed.StateKey)
+	}
+
+	s.MockStub.State[storage.PIIKey(storage.KYCKey(ref))] = s.MockStub.State[storage.PIIKey(storage.ClosedKYCKey(ref, closedAt))]
+	s.withKeys(map[string][]byte{storage.TransientKey: keyTwo})
+	_, err := s.query("read", aadhaarB)
+	expectError(t, err, "sealed for another record")
+}
@@ -0,0 +1,477 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+// The shim's MockStub keeps state but has no transaction timestamps, certificate attributes,
+// caller metadata or events, testStub adds them. Its range scans start from the first key
+// whatever the start key is, so testStub has its own. Calls go straight to the chaincode with the
+// testStub as the stub, MockInvoke would hand the chaincode the bare MockStub.
+
+const testSecret = "0123456789abcdef0123456789abcdef" //aadhaar pseudonymisation secret every test ledger is set up with
+
+// aadhar numbers with a valid Verhoeff check digit
+const (
+	aadhaarA = "234123412346"
+	aadhaarB = "987654321012"
+	aadhaarC = "500000000006"
+	aadhaarD = "500000000010"
+)
+
+// member banks of the test ledger
+const (
+	bankSBI  = "SBIN0000001"
+	bankHDFC = "HDFC0000001"
+)
+
+var testEpoch = time.Date(2026, time.January, 1, 10, 0, 0, 0, time.UTC) //when every test ledger starts
+
+type testEvent struct {
+	Name    string
+	Payload model.KYCEvent
+}
+
+type testStub struct {
+	*shim.MockStub
+	cc       *SimpleChaincode
+	now      time.Time
+	attrs    map[string]string
+	secret   []byte            //aadhaar pseudonymisation secret passed in the caller metadata, nil for none
+	keys     map[string][]byte //AES keys passed in the caller metadata, nil for the calling bank's own key
+	extra    map[string][]byte //anything else passed in the caller metadata
+	metadata []byte            //raw caller metadata, replaces the secret and keys when set
+	events   []testEvent
+	txCount  int
+}
+
+func (s *testStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return &timestamp.Timestamp{Seconds: s.now.Unix(), Nanos: int32(s.now.Nanosecond())}, nil
+}
+
+func (s *testStub) ReadCertAttribute(name string) ([]byte, error) {
+	value, ok := s.attrs[name]
+	if !ok {
+		return nil, errors.New("attribute " + name + " not found")
+	}
+	return []byte(value), nil
+}
+
+func (s *testStub) GetCallerMetadata() ([]byte, error) {
+	if s.metadata != nil {
+		return s.metadata, nil
+	}
+	keys := s.keys
+	if keys == nil && s.attrs[access.RoleAttr] == access.RoleBank {
+		keys = map[string][]byte{storage.TransientKey: bankKey(s.attrs[access.InstitutionAttr])}
+	}
+	encoded := map[string]string{}
+	for name, key := range keys {
+		encoded[name] = base64.StdEncoding.EncodeToString(key)
+	}
+	for name, value := range s.extra {
+		encoded[name] = base64.StdEncoding.EncodeToString(value)
+	}
+	if s.secret != nil {
+		encoded[storage.TransientSecret] = base64.StdEncoding.EncodeToString(s.secret)
+	}
+	return json.Marshal(encoded)
+}
+
+// RangeQueryState walks the keys from startKey up to but not including endKey in key order, like a peer does
+func (s *testStub) RangeQueryState(startKey, endKey string) (shim.StateRangeQueryIteratorInterface, error) {
+	iter := &rangeIter{}
+	for key := range s.State {
+		if key >= startKey && key < endKey {
+			iter.keys = append(iter.keys, key)
+		}
+	}
+	sort.Strings(iter.keys)
+	for _, key := range iter.keys {
+		iter.values = append(iter.values, s.State[key])
+	}
+	return iter, nil
+}
+
+type rangeIter struct { //the keys and values in range when the scan started
+	keys   []string
+	values [][]byte
+	next   int
+}
+
+func (it *rangeIter) HasNext() bool { return it.next < len(it.keys) }
+
+func (it *rangeIter) Next() (string, []byte, error) {
+	if !it.HasNext() {
+		return "", nil, errors.New("range scan has no more keys")
+	}
+	it.next++
+	return it.keys[it.next-1], it.values[it.next-1], nil
+}
+
+func (it *rangeIter) Close() error { return nil }
+
+func (s *testStub) SetEvent(name string, payload []byte) error {
+	var event model.KYCEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return err
+	}
+	s.events = append(s.events, testEvent{name, event})
+	return nil
+}
+
+// ============================================================================================================================
+// New Bare Stub - an empty ledger the chaincode was not deployed to yet, the caller is a regulator passing the test secret
+// ============================================================================================================================
+func newBareStub() *testStub {
+	cc := new(SimpleChaincode)
+	s := &testStub{MockStub: shim.NewMockStub("ekyc", cc), cc: cc, now: testEpoch, secret: []byte(testSecret)}
+	s.asRegulator()
+	return s
+}
+
+// ============================================================================================================================
+// New Test Stub - a fresh ledger, initialised with the test secret and reset allowed
+// ============================================================================================================================
+func newTestStub(t *testing.T) *testStub {
+	t.Helper()
+	s := newBareStub()
+	if _, err := s.init("1", "true"); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	return s
+}
+
+// ============================================================================================================================
+// New Fixture - a ledger with two member banks and a verified record for aadhaarA, verified by SBI
+// ============================================================================================================================
+func newFixture(t *testing.T) *testStub {
+	t.Helper()
+	s := newTestStub(t)
+	s.createBank(t, bankSpec{Code: bankSBI, LegalName: "State Bank"})
+	s.createBank(t, bankSpec{Code: bankHDFC, LegalName: "HDFC Bank"})
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarA, PAN: "ABCPE1234F", Institution: bankSBI, Documents: []string{"passport:K1234567"}})
+	return s
+}
+
+// ============================================================================================================================
+// New Baseline Stub - a ledger as the marbles chaincode this one replaces left it, the caller is the deployer passing the
+// test secret. init_marble and set_user records are listed in _marbleindex, Write's is not, and a marble is left over.
+// WriteBank stored banks under their bare names and appended them to _allBank after the JSON Init seeded it with.
+// ============================================================================================================================
+func newBaselineStub() *testStub {
+	s := newBareStub()
+	s.attrs = map[string]string{} //the deploy transaction's certificate has no role
+	s.MockTransactionStart("baseline")
+	s.PutState("kyc", []byte("99"))
+	s.PutState("bank", []byte("a"))
+	s.PutState("_opentrades", []byte(`{"open_trades":null}`))
+	s.PutState(aadhaarA, []byte(`{"aadharNum": "`+aadhaarA+`", "timestamp": "1467000000000", "size": 35, "user": "sbin0000001"}`))
+	s.PutState(aadhaarB, []byte(`{"aadharNum":"`+aadhaarB+`","timestamp":0,"size":35,"user":"hdfc0000001"}`))
+	s.PutState("asdf", []byte(`{"aadharNum": "asdf", "timestamp": "blue", "size": 16, "user": "bob"}`))
+	s.PutState("_marbleindex", []byte(`["`+aadhaarA+`","`+aadhaarB+`","asdf"]`))
+	s.PutState(aadhaarC, []byte("SBIN0000001;Monday, 27-Jun-16 04:00:00 UTC"))
+	s.PutState(bankSBI, []byte("State Bank;RBI/1;kyc@sbi.example"))
+	s.PutState(bankHDFC, []byte("HDFC Bank;RBI/2;kyc@hdfc.example"))
+	s.PutState("SBI", []byte("State Bank of India;RBI/9;kyc@sbi.example"))
+	s.PutState("_allBank", []byte("null;"+bankSBI+";"+bankHDFC+";SBI"))
+	s.MockTransactionEnd("baseline")
+	return s
+}
+
+// callers
+func (s *testStub) asRegulator() { s.attrs = map[string]string{access.RoleAttr: access.RoleRegulator} }
+func (s *testStub) asAuditor()   { s.attrs = map[string]string{access.RoleAttr: access.RoleAuditor} }
+func (s *testStub) asBank(code string) {
+	s.attrs = map[string]string{access.RoleAttr: access.RoleBank, access.InstitutionAttr: code}
+}
+func (s *testStub) asCustomer(aadharNum string) {
+	ref, _ := storage.AadhaarRef(s, aadharNum)
+	s.attrs = map[string]string{access.RoleAttr: access.RoleCustomer, access.CustomerRefAttr: ref}
+}
+
+// as runs f as another caller and restores the current one
+func (s *testStub) as(become func(), f func()) {
+	saved := s.attrs
+	become()
+	f()
+	s.attrs = saved
+}
+
+// advance moves the ledger clock forward
+func (s *testStub) advance(d time.Duration) { s.now = s.now.Add(d) }
+
+func days(n int) time.Duration { return time.Duration(n) * 24 * time.Hour }
+
+func (s *testStub) nowMs() int64 { return s.now.UnixNano() / int64(time.Millisecond) }
+
+// bankKey is the AES key a bank passes unless the test picks other keys
+func bankKey(code string) []byte {
+	sum := sha256.Sum256([]byte("key of " + code))
+	return sum[:]
+}
+
+// withKeys passes AES keys in the caller metadata next to the secret, nil goes back to the bank's own key and an
+// empty map passes none
+func (s *testStub) withKeys(keys map[string][]byte) {
+	s.keys = keys
+	s.metadata = nil
+}
+
+// withMetadata passes a value in the caller metadata until it is passed again, nil takes it out
+func (s *testStub) withMetadata(name string, value []byte) {
+	if s.extra == nil {
+		s.extra = map[string][]byte{}
+	}
+	if value == nil {
+		delete(s.extra, name)
+		return
+	}
+	s.extra[name] = value
+}
+
+// ============================================================================================================================
+// Init, Invoke and Query - each invoke runs as its own transaction with a fresh id
+// ============================================================================================================================
+func (s *testStub) init(args ...string) ([]byte, error) {
+	s.txCount++
+	txID := "init" + strconv.Itoa(s.txCount)
+	s.MockTransactionStart(txID)
+	defer s.MockTransactionEnd(txID)
+	return s.cc.Init(s, "init", args)
+}
+
+// invoke runs as a bank's client does: aadhar numbers in the arguments are swapped for the reference tokens worked out
+// with the test secret, the secret itself stays out of the transaction
+func (s *testStub) invoke(function string, args ...string) ([]byte, error) {
+	tokenised := make([]string, len(args))
+	for i, arg := range args {
+		tokenised[i] = aadhaarPattern.ReplaceAllStringFunc(arg, func(aadharNum string) string {
+			if validation.Aadhaar("aadharNum", aadharNum) != nil {
+				return aadharNum
+			}
+			if ref, err := storage.AadhaarRef(s, aadharNum); err == nil {
+				return ref
+			}
+			return aadharNum //no secret, the chaincode refuses the number
+		})
+	}
+	secret := s.secret
+	s.secret = nil
+	defer func() { s.secret = secret }()
+	return s.invokeAsIs(function, tokenised...)
+}
+
+var aadhaarPattern = regexp.MustCompile(`\b[0-9]{12}\b`)
+
+// invokeAsIs passes the arguments and the secret exactly as the test has them
+func (s *testStub) invokeAsIs(function string, args ...string) ([]byte, error) {
+	s.txCount++
+	txID := "tx" + strconv.Itoa(s.txCount)
+	s.MockTransactionStart(txID)
+	defer s.MockTransactionEnd(txID)
+	return s.cc.Invoke(s, function, args)
+}
+
+func (s *testStub) query(function string, args ...string) ([]byte, error) {
+	return s.cc.Query(s, function, args)
+}
+
+func (s *testStub) mustInvoke(t *testing.T, function string, args ...string) []byte {
+	t.Helper()
+	out, err := s.invoke(function, args...)
+	if err != nil {
+		t.Fatalf("invoke %s %q: %v", function, args, err)
+	}
+	return out
+}
+
+func (s *testStub) mustQuery(t *testing.T, function string, args ...string) []byte {
+	t.Helper()
+	out, err := s.query(function, args...)
+	if err != nil {
+		t.Fatalf("query %s %q: %v", function, args, err)
+	}
+	return out
+}
+
+// lastTxID is the id of the most recent invoke
+func (s *testStub) lastTxID() string { return "tx" + strconv.Itoa(s.txCount) }
+
+func (s *testStub) lastEvent(t *testing.T) testEvent {
+	t.Helper()
+	if len(s.events) == 0 {
+		t.Fatal("no event was emitted")
+	}
+	return s.events[len(s.events)-1]
+}
+
+// ============================================================================================================================
+// Builders - register banks and create records with sensible defaults, failing the test if the chaincode refuses
+// ============================================================================================================================
+type bankSpec struct {
+	Code      string
+	LegalName string //defaults to "Bank <code>"
+	LicenceID string
+	Contact   string
+}
+
+func (spec bankSpec) args() []string {
+	if spec.LegalName == "" {
+		spec.LegalName = "Bank " + spec.Code
+	}
+	if spec.LicenceID == "" {
+		spec.LicenceID = "RBI/" + spec.Code
+	}
+	if spec.Contact == "" {
+		spec.Contact = "kyc@" + strings.ToLower(spec.Code) + ".example"
+	}
+	return []string{spec.Code, spec.LegalName, spec.LicenceID, spec.Contact}
+}
+
+func (s *testStub) createBank(t *testing.T, spec bankSpec) {
+	t.Helper()
+	s.as(s.asRegulator, func() { s.mustInvoke(t, "writeBank", spec.args()...) })
+	s.as(func() { s.asBank(spec.Code) }, func() { s.mustInvoke(t, "setShareKey") }) //with its own key, so it can be shared with
+}
+
+type kycSpec struct {
+	Aadhaar     string
+	PAN         string
+	Level       string //defaults to full
+	Institution string //verifying bank, defaults to SBI
+	Documents   []string
+	Status      string //pending for a submitted record, otherwise driven through the lifecycle from verified
+	Risk        string
+}
+
+// createKYC makes the record the way a bank would and returns its reference token
+func (s *testStub) createKYC(t *testing.T, spec kycSpec) string {
+	t.Helper()
+	if spec.Level == "" {
+		spec.Level = model.LevelFull
+	}
+	if spec.Institution == "" {
+		spec.Institution = bankSBI
+	}
+	s.as(func() { s.asBank(spec.Institution) }, func() {
+		if spec.Status == model.StatusPending {
+			s.mustInvoke(t, "submit", append([]string{spec.Aadhaar, spec.PAN, spec.Level}, spec.Documents...)...)
+			return
+		}
+		s.mustInvoke(t, "init_marble", append([]string{spec.Aadhaar, spec.PAN, spec.Level, spec.Institution}, spec.Documents...)...)
+		if spec.Risk != "" {
+			s.mustInvoke(t, "setRisk", spec.Aadhaar, spec.Risk)
+		}
+		if spec.Status == model.StatusSuspended {
+			s.mustInvoke(t, "suspend", spec.Aadhaar, "under investigation")
+		}
+	})
+	switch spec.Status { //regulator only
+	case model.StatusExpired:
+		s.as(s.asRegulator, func() { s.mustInvoke(t, "expire", spec.Aadhaar) })
+	case model.StatusRevoked:
+		s.as(s.asRegulator, func() { s.mustInvoke(t, "revoke", spec.Aadhaar, "fraud") })
+	}
+	return s.ref(t, spec.Aadhaar)
+}
+
+func (s *testStub) ref(t *testing.T, aadharNum string) string {
+	t.Helper()
+	ref, err := storage.AadhaarRef(s, aadharNum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
+
+// record reads a record straight from state, without the caller's access rules
+func (s *testStub) record(t *testing.T, aadharNum string) *model.KYCRecord {
+	t.Helper()
+	rec, err := storage.GetKYCRecord(s, s.ref(t, aadharNum))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+// ============================================================================================================================
+// Assertions
+// ============================================================================================================================
+func expectError(t *testing.T, err error, want string) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil {
+		t.Fatalf("expected an error containing %q, got none", want)
+	}
+	if !strings.Contains(errorMessage(err), want) {
+		t.Fatalf("expected an error containing %q, got %q", want, err.Error())
+	}
+}
+
+// errorMessage is the message of a JSON model.Error as the chaincode returns them, or err's text for anything else
+func errorMessage(err error) string {
+	var e model.Error
+	if json.Unmarshal([]byte(err.Error()), &e) != nil {
+		return err.Error()
+	}
+	return e.Message
+}
+
+// expectCode fails unless err is a JSON model.Error with the given code
+func expectCode(t *testing.T, err error, code string) model.Error {
+	t.Helper()
+	var e model.Error
+	if err == nil || json.Unmarshal([]byte(err.Error()), &e) != nil || e.Code != code {
+		t.Fatalf("expected a %s error, got %v", code, err)
+	}
+	return e
+}
+
+func decodeJSON(t *testing.T, data []byte, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("decoding %s: %v", data, err)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+)
+
+func TestHistory(t *testing.T) {
+	s := newFixture(t)
+	s.asBank(bankSBI)
+	s.advance(days(1))
+	s.mustInvoke(t, "setRisk", aadhaarA, model.RiskLow)
+	s.asRegulator()
+	s.mustInvoke(t, "delete", aadhaarA, "customer left")
+
+	s.asAuditor()
+	var history []model.KYCVersion
+	decodeJSON(t, s.mustQuery(t, "history", aadhaarA), &history)
+	if len(history) != 3 {
+		t.Fatalf("expecting 3 versions, got %+v", history)
+	}
+	actions := []string{history[0].Action, history[1].Action, history[2].Action}
+	if !reflect.DeepEqual(actions, []string{model.HistoryCreated, model.HistoryUpdated, model.HistoryClosed}) {
+		t.Fatalf("unexpected actions %q", actions)
+	}
+	if history[1].Actor != bankSBI || history[1].Timestamp != s.nowMs() {
+		t.Fatalf("unexpected update version %+v", history[1])
+	}
+	if !reflect.DeepEqual(history[1].Changes, []string{"nextReviewDue", "riskCategory"}) {
+		t.Fatalf("unexpected changes %q", history[1].Changes)
+	}
+	if history[2].Record != nil || history[2].Actor != access.RoleRegulator {
+		t.Fatalf("closing version should have no record: %+v", history[2])
+	}
+	for _, version := range history {
+		if version.Record != nil && version.Record.HasInlinePII() {
+			t.Fatalf("version %s holds the customer's details", version.TxID)
+		}
+	}
+
+	_, err := s.query("history")
+	expectError(t, err, "Expecting 1: history(aadharNum)")
+}
+
+func TestMigrationHistoryWithoutCaller(t *testing.T) {
+	s := newFixture(t)
+	ref := s.ref(t, aadhaarA)
+	legacy := *s.record(t, aadhaarA) //details on the record make Init write it again
+	legacy.PII = nil
+	legacy.Subject.PAN = "ABCPE1234F"
+	s.MockStub.State[storage.KYCKey(ref)], _ = json.Marshal(legacy)
+	delete(s.MockStub.State, storage.PIIKey(storage.KYCKey(ref)))
+
+	s.attrs = map[string]string{} //the deploy transaction's certificate has no role
+	if _, err := s.init("1"); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	history, _ := storage.GetKYCHistory(s, ref)
+	if last := history[len(history)-1]; last.TxID != "init"+strconv.Itoa(s.txCount) || last.Actor != "system" {
+		t.Fatalf("expecting the migration to go down as system, got %+v", last)
+	}
+}
+
+func TestDiffKYCRecords(t *testing.T) {
+	rec := model.NewKYCRecord("ref", bankSBI, model.LevelFull, 1000)
+	changed := rec
+	changed.Status = model.StatusSuspended
+	changed.StatusReason = "under review"
+	changed.UpdatedAt = 2000
+
+	cases := []struct {
+		name       string
+		prev, next *model.KYCRecord
+		want       []string
+	}{
+		{"unchanged", &rec, &rec, []string{}},
+		{"updatedAt is not a change", &rec, &changed, []string{"status", "statusReason"}},
+		{"created", nil, &rec, []string{"createdAt", "evidence", "institution", "level", "nextReviewDue", "riskCategory", "schemaVersion", "status", "subject", "verifiedAt"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := model.DiffKYCRecords(c.prev, c.next); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %q, expecting %q", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+)
+
+// newListFixture has aadhaarA and aadhaarB verified by SBI a day apart, aadhaarC by HDFC and aadhaarD pending at SBI
+func newListFixture(t *testing.T) *testStub {
+	s := newFixture(t)
+	s.advance(days(1))
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarB, Level: model.LevelOTP})
+	s.advance(days(1))
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarC, Institution: bankHDFC})
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarD, Status: model.StatusPending})
+	return s
+}
+
+func TestList(t *testing.T) {
+	s := newListFixture(t)
+	verifiedB := strconv.FormatInt(testEpoch.Add(days(1)).UnixNano()/1e6, 10)
+
+	cases := []struct {
+		name   string
+		caller func(s *testStub)
+		args   []string
+		count  int
+		want   string
+	}{
+		{"everything", (*testStub).asAuditor, []string{}, 4, ""},
+		{"by institution", (*testStub).asAuditor, []string{"", "", "institution=" + bankHDFC}, 1, ""},
+		{"by status", (*testStub).asAuditor, []string{"", "", "status=pending"}, 1, ""},
+		{"by level", (*testStub).asAuditor, []string{"", "", "level=otp"}, 1, ""},
+		{"verified from", (*testStub).asAuditor, []string{"", "", "verifiedFrom=" + verifiedB}, 2, ""},
+		{"verified to", (*testStub).asAuditor, []string{"", "", "verifiedTo=" + verifiedB}, 3, ""}, //the pending record has verifiedAt 0
+		{"bank sees its own", bank(bankSBI), []string{}, 3, ""},
+		{"bank filters its own", bank(bankSBI), []string{"", "", "institution=" + bankSBI, "status=verified"}, 2, ""},
+		{"bank lists another's", bank(bankSBI), []string{"", "", "institution=" + bankHDFC}, 0, "banks can only list the records they verified"},
+		{"malformed institution", (*testStub).asAuditor, []string{"", "", "institution=SBIN\x00"}, 0, "Invalid institution"},
+		{"unknown filter", (*testStub).asAuditor, []string{"", "", "city=Pune"}, 0, "Unknown filter city"},
+		{"malformed filter", (*testStub).asAuditor, []string{"", "", "status"}, 0, "must look like name=value"},
+		{"unknown status", (*testStub).asAuditor, []string{"", "", "status=lost"}, 0, "Invalid status"},
+		{"bad timestamp", (*testStub).asAuditor, []string{"", "", "verifiedFrom=yesterday"}, 0, "Invalid verifiedFrom"},
+		{"page size too big", (*testStub).asAuditor, []string{"101"}, 0, "Invalid pageSize"},
+		{"bad bookmark", (*testStub).asAuditor, []string{"10", "!!"}, 0, "Invalid bookmark"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.caller(s)
+			out, err := s.query("list", c.args...)
+			expectError(t, err, c.want)
+			if c.want != "" {
+				return
+			}
+			var page model.KYCPage
+			decodeJSON(t, out, &page)
+			if len(page.Records) != c.count || page.Bookmark != "" {
+				t.Fatalf("got %d records and bookmark %q, expecting %d on one page", len(page.Records), page.Bookmark, c.count)
+			}
+		})
+	}
+}
+
+func TestListPages(t *testing.T) {
+	s := newListFixture(t)
+	s.asRegulator()
+	seen := map[string]bool{}
+	bookmark := ""
+	for pages := 1; ; pages++ {
+		var page model.KYCPage
+		decodeJSON(t, s.mustQuery(t, "list", "3", bookmark), &page)
+		for _, rec := range page.Records {
+			if seen[rec.Subject.AadharRef] {
+				t.Fatalf("page %d repeats %s", pages, rec.Subject.AadharRef)
+			}
+			seen[rec.Subject.AadharRef] = true
+		}
+		if page.Bookmark == "" {
+			break
+		}
+		if pages > 2 {
+			t.Fatal("listing does not end")
+		}
+		bookmark = page.Bookmark
+	}
+	if len(seen) != 4 {
+		t.Fatalf("pages held %d records, expecting 4", len(seen))
+	}
+
+	var page model.KYCPage //a bookmark from one listing does not work in another
+	decodeJSON(t, s.mustQuery(t, "list", "1"), &page)
+	_, err := s.query("list", "1", page.Bookmark, "institution="+bankHDFC)
+	expectError(t, err, "does not belong to this listing")
+}
+
+func TestIndexesFollowRecords(t *testing.T) {
+	s := newFixture(t)
+	ref := s.ref(t, aadhaarA)
+	s.asBank(bankSBI)
+	s.mustInvoke(t, "set_user", aadhaarA, bankHDFC)
+
+	keys, err := storage.RangeKeys(s, storage.KYCIndexPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := s.record(t, aadhaarA)
+	want := map[string]bool{}
+	for _, key := range storage.KYCIndexKeys(rec) {
+		want[key] = true
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("index holds %q, expecting %d keys", keys, len(want))
+	}
+	for _, key := range keys {
+		if !want[key] {
+			t.Fatalf("stale index key %q for %s", key, ref)
+		}
+	}
+}
+
+func TestLowerCaseInstitutionMigrates(t *testing.T) {
+	s := newFixture(t)
+	ref := s.ref(t, aadhaarA)
+	before := s.record(t, aadhaarA)
+	lower := *before //as written before bank codes were upper cased
+	lower.Institution = strings.ToLower(bankSBI)
+	s.MockTransactionStart("legacy")
+	for _, key := range storage.KYCIndexKeys(before) {
+		s.DelState(key)
+	}
+	for _, key := range storage.KYCIndexKeys(&lower) {
+		s.PutState(key, []byte{0x00})
+	}
+	recAsBytes, _ := json.Marshal(lower)
+	s.PutState(storage.KYCKey(ref), recAsBytes)
+	s.MockTransactionEnd("legacy")
+
+	if _, err := s.init("1"); err != nil { //the ledger is already at this schema, the records are not walked
+		t.Fatalf("Init: %v", err)
+	}
+	if rec := s.record(t, aadhaarA); rec.Institution != strings.ToLower(bankSBI) {
+		t.Fatalf("Init walked the records on a ledger at the current schema")
+	}
+
+	info, err := storage.GetVersionInfo(s) //as recorded by a build from before the schema was kept
+	if err != nil {
+		t.Fatal(err)
+	}
+	info.SchemaVersion = 0
+	s.MockStub.State[storage.VersionKey], _ = json.Marshal(info)
+	if _, err := s.init("1"); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if info, _ = storage.GetVersionInfo(s); info.SchemaVersion != model.SchemaVersion {
+		t.Fatalf("Init recorded schema %d", info.SchemaVersion)
+	}
+	if rec := s.record(t, aadhaarA); rec.Institution != bankSBI {
+		t.Fatalf("Init left the institution as %s", rec.Institution)
+	}
+	keys, err := storage.RangeKeys(s, storage.KYCIndexPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := storage.KYCIndexKeys(s.record(t, aadhaarA))
+	if len(keys) != len(want) {
+		t.Fatalf("index holds %q, expecting %q", keys, want)
+	}
+	for _, key := range keys {
+		if strings.Contains(key, strings.ToLower(bankSBI)) {
+			t.Fatalf("stale index key %q", key)
+		}
+	}
+
+	s.asBank(bankSBI) //SBI owns the record again
+	s.mustInvoke(t, "setRisk", aadhaarA, model.RiskLow)
+}
@@ -0,0 +1,244 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package access decides who may call what: the caller's role and identity come from their
+// transaction certificate, the policy tables name the roles each function takes, and the
+// checks here decide which KYC records a caller may read and manage.
+package access
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+)
+
+// certificate attributes the membership service puts in the caller's transaction certificate
+const (
+	RoleAttr        = "role"
+	InstitutionAttr = "institution" //bank code of the caller, required for member banks
+	CustomerRefAttr = "customerRef" //reference token of the caller, required for customers
+)
+
+// roles a caller can hold
+const (
+	RoleRegulator = "regulator"
+	RoleBank      = "bank"
+	RoleAuditor   = "auditor"
+	RoleCustomer  = "customer"
+)
+
+var AllRoles = []string{RoleRegulator, RoleBank, RoleAuditor, RoleCustomer}
+
+// BankLookup reads a bank from the registry, nil if the code is not registered
+type BankLookup func(stub shim.ChaincodeStubInterface, code string) (*model.Bank, error)
+
+// which roles may call each invoke function, anything not listed is denied
+var InvokePolicy = map[string][]string{
+	"init":                {RoleRegulator},
+	"reset":               {RoleRegulator},
+	"delete":              {RoleRegulator},
+	"write":               {RoleBank},
+	"writeBank":           {RoleRegulator},
+	"updateBank":          {RoleRegulator},
+	"deactivateBank":      {RoleRegulator},
+	"init_marble":         {RoleBank},
+	"set_user":            {RoleBank, RoleRegulator},
+	"setDetails":          {RoleBank},
+	"requestKYC":          {RoleBank},
+	"approveRequest":      {RoleCustomer, RoleBank},
+	"rejectRequest":       {RoleCustomer, RoleBank},
+	"grantConsent":        {RoleCustomer, RoleBank},
+	"revokeConsent":       {RoleCustomer, RoleBank},
+	"shareDetails":        {RoleBank},
+	"dropExpiredShares":   {RoleRegulator, RoleBank},
+	"submit":              {RoleBank},
+	"verify":              {RoleBank},
+	"suspend":             {RoleBank, RoleRegulator},
+	"reinstate":           {RoleRegulator},
+	"revoke":              {RoleRegulator},
+	"expire":              {RoleRegulator},
+	"renew":               {RoleBank},
+	"setRisk":             {RoleBank, RoleRegulator},
+	"purge":               {RoleRegulator},
+	"attachDocument":      {RoleBank},
+	"setUIDAICertificate": {RoleRegulator},
+	"onboardOfflineKYC":   {RoleBank},
+	"rotateKey":           {RoleBank},
+	"setShareKey":         {RoleBank},
+}
+
+// which roles may call each query function, anything not listed is denied
+var QueryPolicy = map[string][]string{
+	"read":              AllRoles,
+	"readBank":          AllRoles,
+	"readAll":           AllRoles,
+	"readConsents":      AllRoles,
+	"readShareRequests": AllRoles,
+	"history":           {RoleRegulator, RoleAuditor},
+	"list":              {RoleRegulator, RoleAuditor, RoleBank}, //banks only get their own records
+	"dueForReview":      {RoleRegulator, RoleAuditor, RoleBank},
+	"readClosed":        {RoleRegulator, RoleAuditor},
+	"verifyDocument":    AllRoles,
+	"disclose":          {RoleBank},
+	"readProfiles":      AllRoles,
+	"describe":          AllRoles,
+}
+
+type Caller struct { //who is calling, read from their certificate
+	Role        string `json:"role"`
+	Institution string `json:"institution,omitempty"`
+	CustomerRef string `json:"customerRef,omitempty"`
+}
+
+// ============================================================================================================================
+// Get Caller - read who is calling from their certificate attributes
+// ============================================================================================================================
+func GetCaller(stub shim.ChaincodeStubInterface) (Caller, error) {
+	var caller Caller
+
+	role, err := stub.ReadCertAttribute(RoleAttr)
+	if err != nil {
+		return caller, model.NewError(model.CodePermissionDenied, "Failed to read caller attribute \""+RoleAttr+"\"")
+	}
+	caller.Role = strings.ToLower(strings.TrimSpace(string(role)))
+
+	institution, err := stub.ReadCertAttribute(InstitutionAttr)
+	if err == nil {
+		caller.Institution = model.BankCode(string(institution))
+	}
+	customerRef, err := stub.ReadCertAttribute(CustomerRefAttr)
+	if err == nil {
+		caller.CustomerRef = strings.ToLower(strings.TrimSpace(string(customerRef)))
+	}
+	return caller, nil
+}
+
+// ============================================================================================================================
+// Get Actor - how the caller goes down in history and events, a certificate without a role attribute is no caller at
+// all, as for the migrations Init runs at deploy, and comes out as "system"
+// ============================================================================================================================
+func GetActor(stub shim.ChaincodeStubInterface) string {
+	caller, err := GetCaller(stub)
+	if err != nil {
+		return Caller{}.Actor()
+	}
+	return caller.Actor()
+}
+
+// ============================================================================================================================
+// Authorize - check the caller's role against a policy table before a function runs, bank callers are looked up with
+// getBank and have to be active members
+// ============================================================================================================================
+func Authorize(stub shim.ChaincodeStubInterface, policy map[string][]string, function string, getBank BankLookup) (Caller, error) {
+	caller, err := GetCaller(stub)
+	if err != nil {
+		return caller, err
+	}
+
+	allowed, ok := policy[function]
+	if !ok {
+		return caller, model.PermissionDenied("no access policy for function \"" + function + "\"")
+	}
+	if !hasRole(allowed, caller.Role) {
+		return caller, model.PermissionDenied(fmt.Sprintf("\"%s\" requires role %s, caller has role \"%s\"", function, strings.Join(allowed, " or "), caller.Role))
+	}
+
+	if caller.Role == RoleBank { //member banks must be registered and active
+		if len(caller.Institution) == 0 {
+			return caller, model.PermissionDenied("bank caller has no \"" + InstitutionAttr + "\" attribute")
+		}
+		bank, err := getBank(stub, caller.Institution)
+		if err != nil {
+			return caller, err
+		}
+		if bank == nil || bank.Status != model.BankActive {
+			return caller, model.PermissionDenied(caller.Institution + " is not an active member bank")
+		}
+	}
+	if caller.Role == RoleCustomer && len(caller.CustomerRef) == 0 {
+		return caller, model.PermissionDenied("customer caller has no \"" + CustomerRefAttr + "\" attribute")
+	}
+	return caller, nil
+}
+
+// ============================================================================================================================
+// Actor - how a caller is named in history and events, bank code for banks and the role for everyone else
+// ============================================================================================================================
+func (c Caller) Actor() string {
+	if len(c.Institution) > 0 {
+		return c.Institution
+	}
+	if len(c.Role) > 0 {
+		return c.Role
+	}
+	return "system" //deploy time migrations have no caller
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================================================================================================
+// Act For Customer - the customer themselves or the bank that onboarded them may manage their consents
+// ============================================================================================================================
+func ActForCustomer(caller Caller, ref string, rec *model.KYCRecord) (string, error) {
+	if caller.Role == RoleCustomer && caller.CustomerRef == ref {
+		return RoleCustomer, nil
+	}
+	if caller.Role == RoleBank && rec != nil && caller.Institution == rec.Institution {
+		return caller.Institution, nil
+	}
+	return "", model.PermissionDenied("only the customer or their onboarding bank can manage consents")
+}
+
+// ============================================================================================================================
+// Check Read Access - regulators and auditors read anything, customers their own record, banks the records they
+// verified, read hands other banks what their consent's purpose discloses instead
+// ============================================================================================================================
+func CheckReadAccess(caller Caller, rec *model.KYCRecord) error {
+	switch caller.Role {
+	case RoleRegulator, RoleAuditor:
+		return nil
+	case RoleCustomer:
+		if caller.CustomerRef == rec.Subject.AadharRef {
+			return nil
+		}
+	case RoleBank:
+		if caller.Institution == rec.Institution {
+			return nil
+		}
+		return model.PermissionDenied(caller.Institution + " did not verify this KYC record")
+	}
+	return model.PermissionDenied("caller cannot read this KYC record")
+}
+
+// ============================================================================================================================
+// Can Read PII - only the verifying bank reads the customer's details in full, consented banks get them by purpose
+// ============================================================================================================================
+func CanReadPII(caller Caller, rec *model.KYCRecord) bool {
+	return caller.Role == RoleBank && caller.Institution == rec.Institution
+}
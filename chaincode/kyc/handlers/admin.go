@@ -0,0 +1,185 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+const ChaincodeVersion = "2.0.0" //bump on every release, Init records it on the ledger
+
+// ============================================================================================================================
+// Init - seed any state that is missing, safe to run again on an existing ledger
+// ============================================================================================================================
+func Init(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0        1          2
+	// "99", *"true"*, *"1825"*		<- optional, allow the reset function (test networks only, first deploy only) and the
+	//									   days closed KYC records are retained
+	// the secret aadhar numbers are hashed with comes in the caller metadata, it is required before the first KYC record
+
+	// Initialize the chaincode
+	Aval, _ := strconv.Atoi(args[0]) //checked against the schema
+
+	// Seed the state only if it is not there yet, existing banks, records and trades are kept
+	err := storage.SeedState(stub, "kyc", []byte(strconv.Itoa(Aval))) //making a test var "abc", I find it handy to read/write to it right away to test the network
+	if err != nil {
+		return nil, err
+	}
+
+	err = storage.SeedState(stub, "bank", []byte("a"))
+	if err != nil {
+		return nil, err
+	}
+
+	err = storage.MigrateLegacyBanks(stub) //banks the old writeBank stored and the list it corrupted
+	if err != nil {
+		return nil, err
+	}
+	var emptyList []string
+	bankListAsBytes, _ := json.Marshal(emptyList) //marshal an emtpy array of strings to start the index
+	err = storage.SeedState(stub, storage.BankIndexKey, bankListAsBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	deployed, err := storage.ConfigStored(stub)
+	if err != nil {
+		return nil, err
+	}
+	config, err := storage.GetConfig(stub)
+	if err != nil {
+		return nil, err
+	}
+	if allowReset := argAt(args, 1); len(allowReset) > 0 {
+		enable, _ := strconv.ParseBool(allowReset)
+		if enable && !config.AllowReset && deployed {
+			return nil, model.FailedPrecondition("Reset can only be enabled when the chaincode is first deployed")
+		}
+		config.AllowReset = enable //turning it off is always allowed
+	}
+	if retention := argAt(args, 2); len(retention) > 0 {
+		config.RetentionDays, _ = strconv.Atoi(retention)
+		if config.RetentionDays < 1 {
+			return nil, validation.Invalid("retentionDays", "must be a whole number of days")
+		}
+	}
+	err = storage.PutConfig(stub, config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = storage.RetireStoredSecret(stub) //earlier builds kept the secret itself in state
+	if err != nil {
+		return nil, err
+	}
+	secret, err := storage.CallerAadhaarSecret(stub)
+	if err != nil {
+		return nil, err
+	}
+	if secret != nil {
+		err = storage.SetAadhaarSecret(stub, secret)
+		if err != nil {
+			return nil, err
+		}
+		err = storage.PseudonymiseLegacyRecords(stub, access.GetActor(stub)) //records from before aadhar numbers were hashed
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = storage.ReindexKYCRecords(stub) //records from before the current indexes
+	if err != nil {
+		return nil, err
+	}
+	err = storage.SplitStoredPII(stub, access.GetActor(stub)) //records from before customer details were kept apart
+	if err != nil {
+		return nil, err
+	}
+
+	err = storage.RecordVersion(stub, ChaincodeVersion)
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// ============================================================================================================================
+// Reset - remove every KYC record, bank, consent and share request and restore the default settings, only when enabled
+// at deploy, every reset is logged
+// ============================================================================================================================
+func Reset(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0
+	// "reason for the reset"
+
+	config, err := storage.GetConfig(stub)
+	if err != nil {
+		return nil, err
+	}
+	if !config.AllowReset {
+		return nil, model.FailedPrecondition("Reset is disabled on this network")
+	}
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("- start reset")
+
+	entry := model.ResetEntry{TxID: stub.GetTxID(), Role: caller.Role, Institution: caller.Institution, Reason: args[0], Timestamp: now}
+
+	entry.KYCRemoved, err = storage.ClearKYCRecords(stub) //closed records, histories and details go too
+	if err != nil {
+		return nil, err
+	}
+	entry.BanksRemoved, err = storage.ClearBanks(stub)
+	if err != nil {
+		return nil, err
+	}
+	entry.ConsentsRemoved, err = storage.ClearConsents(stub)
+	if err != nil {
+		return nil, err
+	}
+	entry.RequestsRemoved, err = storage.ClearShareRequests(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = storage.PutConfig(stub, model.ChaincodeConfig{AllowReset: true}) //defaults, reset stays enabled
+	if err != nil {
+		return nil, err
+	}
+	err = storage.AppendResetLog(stub, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("- end reset")
+	return nil, nil
+}
@@ -0,0 +1,200 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+)
+
+// ============================================================================================================================
+// Parse Bank Args - shared by register and update, the schema has checked the code is IFSC style
+// ============================================================================================================================
+func parseBankArgs(args []string) model.Bank {
+	var bank model.Bank
+
+	//   0            1              2             3
+	// "SBIN0000001", "State Bank", "RBI/123", "kyc@sbi.co.in"
+	bank.Code = model.BankCode(args[0])
+	bank.LegalName = strings.TrimSpace(args[1])
+	bank.LicenceID = strings.TrimSpace(args[2])
+	bank.Contact = strings.TrimSpace(args[3])
+	return bank
+}
+
+// ============================================================================================================================
+// Write Bank - register a new bank
+// ============================================================================================================================
+func WriteBank(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("running writeBank()")
+
+	bank := parseBankArgs(args)
+
+	bankIndex, err := storage.GetBankIndex(stub)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range bankIndex { //duplicate detection, by code and by legal name
+		if code == bank.Code {
+			return nil, model.AlreadyExists("Bank " + bank.Code + " is already registered")
+		}
+		existing, err := storage.GetBank(stub, code)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && strings.EqualFold(existing.LegalName, bank.LegalName) {
+			return nil, model.AlreadyExists("A bank named \""+bank.LegalName+"\" is already registered as "+existing.Code).With("code", existing.Code)
+		}
+	}
+
+	bank.OnboardedAt, err = storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	bank.Status = model.BankActive
+	err = storage.PutBank(stub, bank)
+	if err != nil {
+		return nil, err
+	}
+
+	bankIndex = append(bankIndex, bank.Code)
+	jsonAsBytes, _ := json.Marshal(bankIndex)
+	err = stub.PutState(storage.BankIndexKey, jsonAsBytes)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("- end writeBank")
+	return nil, emitEvent(stub, model.EventBankRegistered, "", bank.Code)
+}
+
+// ============================================================================================================================
+// Update Bank - change the details of a registered bank, status and onboarding date are kept
+// ============================================================================================================================
+func UpdateBank(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("- start update bank")
+
+	update := parseBankArgs(args)
+	bank, err := storage.GetBank(stub, update.Code)
+	if err != nil {
+		return nil, err
+	}
+	if bank == nil {
+		return nil, model.NotFound("Bank " + update.Code + " is not registered")
+	}
+
+	bankIndex, err := storage.GetBankIndex(stub)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range bankIndex { //renaming must not collide with another bank
+		if code == bank.Code {
+			continue
+		}
+		other, err := storage.GetBank(stub, code)
+		if err != nil {
+			return nil, err
+		}
+		if other != nil && strings.EqualFold(other.LegalName, update.LegalName) {
+			return nil, model.AlreadyExists("A bank named \""+update.LegalName+"\" is already registered as "+other.Code).With("code", other.Code)
+		}
+	}
+
+	bank.LegalName = update.LegalName
+	bank.LicenceID = update.LicenceID
+	bank.Contact = update.Contact
+	err = storage.PutBank(stub, *bank)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("- end update bank")
+	return nil, emitEvent(stub, model.EventBankUpdated, "", bank.Code)
+}
+
+// ============================================================================================================================
+// Deactivate Bank - mark a bank inactive, the record stays in the registry
+// ============================================================================================================================
+func DeactivateBank(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0
+	// "SBIN0000001"
+
+	code := model.BankCode(args[0])
+	bank, err := storage.GetBank(stub, code)
+	if err != nil {
+		return nil, err
+	}
+	if bank == nil {
+		return nil, model.NotFound("Bank " + code + " is not registered")
+	}
+	if bank.Status == model.BankInactive {
+		return nil, model.Conflict("Bank " + code + " is already inactive")
+	}
+
+	bank.Status = model.BankInactive
+	err = storage.PutBank(stub, *bank)
+	if err != nil {
+		return nil, err
+	}
+	return nil, emitEvent(stub, model.EventBankDeactivated, "", bank.Code)
+}
+
+// ============================================================================================================================
+// Read Bank - read a registered bank's details
+// ============================================================================================================================
+func ReadBank(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	code := model.BankCode(args[0])
+	bank, err := storage.GetBank(stub, code)
+	if err != nil {
+		return nil, err
+	}
+	if bank == nil {
+		return nil, model.NotFound("Bank " + code + " is not registered")
+	}
+	jsonAsBytes, _ := json.Marshal(bank)
+	return jsonAsBytes, nil
+}
+
+// ============================================================================================================================
+// Read All - read every registered bank as a JSON list
+// ============================================================================================================================
+func ReadAll(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	bankIndex, err := storage.GetBankIndex(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	banks := []model.Bank{}
+	for _, code := range bankIndex {
+		bank, err := storage.GetBank(stub, code)
+		if err != nil {
+			return nil, err
+		}
+		if bank != nil {
+			banks = append(banks, *bank)
+		}
+	}
+	jsonAsBytes, _ := json.Marshal(banks)
+	return jsonAsBytes, nil
+}
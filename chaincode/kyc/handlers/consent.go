@@ -0,0 +1,219 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+// ============================================================================================================================
+// Grant Consent - let a bank read a customer's KYC for a purpose, for a number of days. The verifying bank passing kycKey
+// seals the details the purpose discloses for the bank straight away, a customer's consent waits for shareDetails.
+// ============================================================================================================================
+func GrantConsent(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0            1              2                  3
+	// "aadharNum", "HDFC0000001", "account_opening", "30"
+	fmt.Println("- start grant consent")
+
+	ref, err := storage.CustomerRef(stub, "aadharNum", args[0])
+	if err != nil {
+		return nil, err
+	}
+	grantee := model.BankCode(args[1])
+	purpose := strings.ToLower(strings.TrimSpace(args[2]))
+	if !model.IsConsentPurpose(purpose) {
+		return nil, validation.Invalid("purpose", "expecting one of "+strings.Join(model.ConsentPurposes, ", "))
+	}
+	days, err := strconv.Atoi(args[3])
+	if err != nil || days < 1 || days > model.MaxConsentDays {
+		return nil, validation.Invalid("days", "must be a whole number of days between 1 and "+strconv.Itoa(model.MaxConsentDays))
+	}
+
+	rec, err := storage.GetKYCRecord(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, noKYCRecord()
+	}
+	if err = model.RequireStatus(rec, "share", model.StatusVerified); err != nil {
+		return nil, err
+	}
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	grantedBy, err := access.ActForCustomer(caller, ref, rec)
+	if err != nil {
+		return nil, err
+	}
+	if err = requireActiveBank(stub, grantee); err != nil {
+		return nil, err
+	}
+
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	consent := model.Consent{AadharRef: ref, Bank: grantee, Purpose: purpose, GrantedBy: grantedBy, GrantedAt: now}
+	consent.ExpiresAt = now + int64(days)*model.MsPerDay
+	err = storage.PutConsent(stub, consent)
+	if err != nil {
+		return nil, err
+	}
+	if grantedBy == rec.Institution {
+		if _, err = storage.ShareCustomerPII(stub, rec, grantedBy); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Println("- end grant consent")
+	return nil, nil
+}
+
+// ============================================================================================================================
+// Revoke Consent - stop a bank reading a customer's KYC, the consent is kept as a record and the details sealed for the
+// bank are dropped
+// ============================================================================================================================
+func RevokeConsent(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0            1              2
+	// "aadharNum", "HDFC0000001", "account_opening"
+
+	ref, err := storage.CustomerRef(stub, "aadharNum", args[0])
+	if err != nil {
+		return nil, err
+	}
+	rec, err := storage.GetKYCRecord(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	revokedBy, err := access.ActForCustomer(caller, ref, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	key := storage.ConsentKey(ref, model.BankCode(args[1]), strings.ToLower(strings.TrimSpace(args[2])))
+	consent, err := storage.GetConsent(stub, key)
+	if err != nil {
+		return nil, err
+	}
+	if consent == nil {
+		return nil, model.NotFound("No consent for " + model.BankCode(args[1]) + " for " + args[2])
+	}
+	if consent.RevokedAt != 0 {
+		return nil, model.Conflict("Consent is already revoked")
+	}
+
+	consent.RevokedAt, err = storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	consent.RevokedBy = revokedBy
+	jsonAsBytes, _ := json.Marshal(consent)
+	err = stub.PutState(key, jsonAsBytes)
+	if err != nil {
+		return nil, err
+	}
+	return nil, storage.DropSharedPII(stub, key)
+}
+
+// ============================================================================================================================
+// Share Details - the verifying bank seals a customer's details for every bank holding a consent, for consents the
+// customer gave themselves, and drops the copies of consents that no longer stand
+// ============================================================================================================================
+func ShareDetails(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0
+	// "aadharNum"		<- the bank's key goes in the caller metadata as kycKey
+	key, err := storage.TransientAESKey(stub, storage.TransientKey)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, model.InvalidArgument(storage.TransientKey, "Caller metadata must carry "+storage.TransientKey+" to open the details")
+	}
+	rec, err := loadOwnKYCRecord(stub, args[0], "share the details of")
+	if err != nil {
+		return nil, err
+	}
+	shared, err := storage.ShareCustomerPII(stub, rec, rec.Institution)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("- details of " + rec.Subject.AadharRef + " sealed for " + strconv.Itoa(shared) + " consents")
+	return nil, nil
+}
+
+// ============================================================================================================================
+// Drop Expired Shares - remove the details sealed for consents that have run out, run daily
+// ============================================================================================================================
+func DropExpiredShares(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	dropped, err := storage.DropExpiredSharedPII(stub)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("- dropped the details of " + strconv.Itoa(dropped) + " expired consents")
+	return nil, nil
+}
+
+// ============================================================================================================================
+// Read Consents - every consent a customer has given, revoked and expired ones included
+// ============================================================================================================================
+func ReadConsents(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0
+	// "aadharNum"
+
+	ref, err := storage.CustomerRef(stub, "aadharNum", args[0])
+	if err != nil {
+		return nil, err
+	}
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	if caller.Role == access.RoleCustomer || caller.Role == access.RoleBank {
+		rec, err := storage.GetKYCRecord(stub, ref)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = access.ActForCustomer(caller, ref, rec); err != nil {
+			return nil, err
+		}
+	}
+
+	consents, err := storage.GetConsents(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+	jsonAsBytes, _ := json.Marshal(consents)
+	return jsonAsBytes, nil
+}
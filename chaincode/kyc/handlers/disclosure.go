@@ -0,0 +1,180 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+// ============================================================================================================================
+// Disclose - the part of a customer's record the calling bank's consent for a purpose entitles it to
+// ============================================================================================================================
+func Disclose(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0              1
+	// "aadharNum", "insurance"
+	purpose := strings.ToLower(strings.TrimSpace(args[1]))
+	if _, ok := model.DisclosureProfiles[purpose]; !ok {
+		return nil, validation.Invalid("purpose", "expecting one of "+strings.Join(model.ConsentPurposes, ", "))
+	}
+	ref, err := storage.CustomerRef(stub, "aadharNum", args[0])
+	if err != nil {
+		return nil, err
+	}
+	rec, err := storage.GetKYCRecord(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, noKYCRecord()
+	}
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	disclosure, err := discloseKYCRecord(stub, caller, rec, purpose)
+	if err != nil {
+		return nil, err
+	}
+	jsonAsBytes, _ := json.Marshal(disclosure)
+	return jsonAsBytes, nil
+}
+
+// ============================================================================================================================
+// Consented Purpose - the purpose a bank that did not verify a record reads it for, the one given or else the only one
+// its valid consents are for
+// ============================================================================================================================
+func consentedPurpose(stub shim.ChaincodeStubInterface, bank string, ref string, purpose string) (string, error) {
+	if len(purpose) > 0 {
+		purpose = strings.ToLower(purpose)
+		if _, ok := model.DisclosureProfiles[purpose]; !ok {
+			return "", validation.Invalid("purpose", "expecting one of "+strings.Join(model.ConsentPurposes, ", "))
+		}
+		return purpose, nil
+	}
+	consents, err := storage.GetConsents(stub, ref)
+	if err != nil {
+		return "", err
+	}
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return "", err
+	}
+	purposes := []string{}
+	for _, consent := range consents {
+		if consent.Bank == bank && consent.ValidAt(now) {
+			purposes = append(purposes, consent.Purpose)
+		}
+	}
+	switch len(purposes) {
+	case 0:
+		return "", model.PermissionDenied(bank + " did not verify this KYC record and has no valid consent to read it")
+	case 1:
+		return purposes[0], nil
+	}
+	return "", validation.Invalid("purpose", bank+" holds consents for "+strings.Join(purposes, ", ")+", pass the one to read for")
+}
+
+// ============================================================================================================================
+// Disclose KYC Record - project a record through a purpose's profile for the calling bank, other banks than the verifying
+// one need a valid consent for the purpose
+// ============================================================================================================================
+func discloseKYCRecord(stub shim.ChaincodeStubInterface, caller access.Caller, rec *model.KYCRecord, purpose string) (model.Disclosure, error) {
+	profile := model.DisclosureProfiles[purpose]
+	ref := rec.Subject.AadharRef
+	disclosure := model.Disclosure{AadharRef: ref, Purpose: purpose, Bank: caller.Institution}
+	sealedFor := model.OmittedEncrypted //why details that are held are missing
+	if caller.Institution != rec.Institution {
+		consent, err := storage.GetConsent(stub, storage.ConsentKey(ref, caller.Institution, purpose))
+		if err != nil {
+			return disclosure, err
+		}
+		now, err := storage.TxTimestamp(stub)
+		if err != nil {
+			return disclosure, err
+		}
+		if consent == nil || !consent.ValidAt(now) {
+			return disclosure, model.PermissionDenied(caller.Institution + " has no valid consent for " + purpose)
+		}
+		disclosure.ExpiresAt = consent.ExpiresAt
+		if rec.PII != nil && rec.PII.Encrypted { //the bank opens the copy sealed for its consent, not the verifying bank's
+			shared, sealed, err := storage.OpenSharedPII(stub, storage.ConsentKey(ref, caller.Institution, purpose))
+			if err != nil {
+				return disclosure, err
+			}
+			if !sealed {
+				sealedFor = model.OmittedNotShared
+			}
+			if shared != nil {
+				rec.Subject.PAN, rec.Documents = shared.PAN, shared.Documents
+				rec.Subject.Name, rec.Subject.DOB, rec.Subject.Address = shared.Name, shared.DOB, shared.Address
+			}
+		}
+	}
+	if caller.Institution == rec.Institution || rec.PII == nil || !rec.PII.Encrypted {
+		err := storage.MergeCustomerPII(stub, storage.KYCKey(ref), rec, caller.Institution) //details Init left in the clear go to any consented bank
+		if err != nil {
+			return disclosure, err
+		}
+	}
+
+	all := model.ProjectKYCRecord(rec)
+	held := map[string]bool{}
+	if rec.PII != nil {
+		for _, field := range rec.PII.Fields {
+			held[field] = true
+		}
+	}
+	disclosure.Fields = map[string]json.RawMessage{}
+	disclosure.Omitted = map[string]string{}
+	allowed := map[string]bool{}
+	for _, field := range profile {
+		allowed[field] = true
+		if value, ok := all[field]; ok {
+			disclosure.Fields[field] = value
+		} else if held[field] {
+			disclosure.Omitted[field] = sealedFor
+		} else {
+			disclosure.Omitted[field] = model.OmittedNotPresent
+		}
+	}
+	for _, field := range model.DisclosableFields {
+		_, present := all[field]
+		if !allowed[field] && (present || held[field]) {
+			disclosure.Omitted[field] = model.OmittedByProfile
+		}
+	}
+	return disclosure, nil
+}
+
+// ============================================================================================================================
+// Read Profiles - the disclosure profile of every purpose
+// ============================================================================================================================
+func ReadProfiles(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	jsonAsBytes, _ := json.Marshal(model.DisclosureProfiles)
+	return jsonAsBytes, nil
+}
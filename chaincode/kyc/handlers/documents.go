@@ -0,0 +1,141 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+// ============================================================================================================================
+// Attach Document - anchor the digest of a document the verifying bank looked at to a customer's record
+// ============================================================================================================================
+func AttachDocument(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0              1            2            3           4                5
+	// "aadharNum", "pan_card", "sha256 hex", "issuer", *"2030-12-31"*, "https://host/path"		<- expiry may be empty
+	doc := model.DocumentEvidence{Type: strings.ToLower(strings.TrimSpace(args[1])), SHA256: strings.ToLower(strings.TrimSpace(args[2]))}
+	doc.Issuer = strings.TrimSpace(args[3])
+	doc.URI = strings.TrimSpace(args[5])
+	if !model.IsEvidenceType(doc.Type) {
+		return nil, validation.Invalid("type", "expecting one of "+strings.Join(model.EvidenceTypes, ", "))
+	}
+	if err := validation.Digest("sha256", doc.SHA256); err != nil {
+		return nil, err
+	}
+	if expiry := strings.TrimSpace(args[4]); len(expiry) > 0 {
+		date, _ := time.Parse(model.ReviewDateLayout, expiry) //checked against the schema
+		doc.ExpiresAt = date.UnixNano() / int64(time.Millisecond)
+	}
+	if err := validation.StorageURI("uri", doc.URI); err != nil {
+		return nil, err
+	}
+
+	rec, err := loadOwnKYCRecord(stub, args[0], "attach documents to")
+	if err != nil {
+		return nil, err
+	}
+	if rec.Status == model.StatusRevoked {
+		return nil, model.Conflict("Cannot attach documents to a KYC record that is revoked")
+	}
+	for _, existing := range rec.Evidence {
+		if existing.SHA256 == doc.SHA256 {
+			return nil, model.AlreadyExists("Document " + doc.SHA256 + " is already attached to this KYC record")
+		}
+	}
+
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	doc.AnchoredAt = now
+	doc.AnchoredBy = caller.Actor()
+	rec.Evidence = append(rec.Evidence, doc)
+	rec.UpdatedAt = now
+	err = storage.PutKYCRecord(stub, *rec, writer(stub))
+	if err != nil {
+		return nil, err
+	}
+	return nil, emitStatusEvent(stub, model.EventKYCUpdated, rec)
+}
+
+// ============================================================================================================================
+// Verify Document - does a digest match a document anchored to a record, optionally to one customer's record
+// ============================================================================================================================
+func VerifyDocument(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//     0              1
+	// "sha256 hex", *"aadharNum"*
+	digest := strings.ToLower(strings.TrimSpace(args[0]))
+	if err := validation.Digest("sha256", digest); err != nil {
+		return nil, err
+	}
+	var onlyRef string
+	if len(argAt(args, 1)) > 0 {
+		var err error
+		onlyRef, err = storage.CustomerRef(stub, "aadharNum", args[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := storage.IndexedRefs(stub, storage.IndexDocument, digest)
+	if err != nil {
+		return nil, err
+	}
+	result := model.DocumentVerification{Matches: []model.DocumentMatch{}}
+	for _, ref := range refs {
+		if len(onlyRef) > 0 && ref != onlyRef {
+			continue
+		}
+		rec, err := storage.GetKYCRecord(stub, ref)
+		if err != nil {
+			return nil, err
+		}
+		if rec == nil {
+			continue
+		}
+		for _, doc := range rec.Evidence {
+			if doc.SHA256 != digest {
+				continue
+			}
+			match := model.DocumentMatch{Type: doc.Type, Issuer: doc.Issuer, ExpiresAt: doc.ExpiresAt, AnchoredAt: doc.AnchoredAt, AnchoredBy: doc.AnchoredBy, Status: rec.Status}
+			match.Expired = doc.ExpiresAt != 0 && now >= doc.ExpiresAt
+			result.Matches = append(result.Matches, match)
+		}
+	}
+	result.Anchored = len(result.Matches) > 0
+	jsonAsBytes, _ := json.Marshal(result)
+	return jsonAsBytes, nil
+}
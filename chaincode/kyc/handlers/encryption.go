@@ -0,0 +1,138 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+// ============================================================================================================================
+// Set Share Key - register the public half of the share key the calling bank's kycKey stands for, other banks seal
+// customer details to it when the bank is given a consent or a record
+// ============================================================================================================================
+func SetShareKey(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// no arguments, the bank's key is passed as kycKey in the caller metadata
+	key, err := storage.TransientAESKey(stub, storage.TransientKey)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, model.InvalidArgument(storage.TransientKey, "Caller metadata must carry "+storage.TransientKey)
+	}
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	bank, err := storage.GetBank(stub, caller.Institution)
+	if err != nil {
+		return nil, err
+	}
+	if bank == nil {
+		return nil, model.NotFound("Bank " + caller.Institution + " is not registered")
+	}
+	private, err := storage.ShareKey(key)
+	if err != nil {
+		return nil, err
+	}
+	bank.ShareKey = private.PublicKey().Bytes()
+	err = storage.PutBank(stub, *bank)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("- " + bank.Code + " registered share key " + storage.KeyID(bank.ShareKey))
+	return nil, emitEvent(stub, model.EventBankUpdated, "", bank.Code)
+}
+
+// ============================================================================================================================
+// Rotate Key - re-encrypt every customer's details the calling bank encrypted with one key under a new one, a share key
+// registered for the old key moves to the new one with the details sealed to it
+// ============================================================================================================================
+func RotateKey(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	// no arguments, the current key is passed as kycKey and the new one as kycNewKey in the caller metadata, without
+	// kycKey the bank's details Init left in the clear are encrypted under kycNewKey
+	oldKey, err := storage.TransientAESKey(stub, storage.TransientKey)
+	if err != nil {
+		return nil, err
+	}
+	newKey, err := storage.TransientAESKey(stub, storage.TransientNewKey)
+	if err != nil {
+		return nil, err
+	}
+	if newKey == nil {
+		return nil, model.InvalidArgument(storage.TransientNewKey, "Caller metadata must carry "+storage.TransientNewKey)
+	}
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	if oldKey == nil {
+		sealed, err := storage.SealClearPII(stub, caller.Institution, newKey)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println("- " + caller.Institution + " encrypted " + strconv.Itoa(sealed) + " records in the clear under key " + storage.KeyID(newKey))
+		return nil, nil
+	}
+	if storage.KeyID(oldKey) == storage.KeyID(newKey) {
+		return nil, validation.Invalid(storage.TransientNewKey, "must differ from "+storage.TransientKey)
+	}
+
+	rotated, err := storage.RotatePIIKey(stub, caller.Institution, oldKey, newKey)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("- " + caller.Institution + " rotated key " + storage.KeyID(oldKey) + " to " + storage.KeyID(newKey) + " on " + strconv.Itoa(rotated) + " records")
+
+	bank, err := storage.GetBank(stub, caller.Institution)
+	if err != nil {
+		return nil, err
+	}
+	oldShare, err := storage.ShareKey(oldKey)
+	if err != nil {
+		return nil, err
+	}
+	if bank == nil || !bytes.Equal(bank.ShareKey, oldShare.PublicKey().Bytes()) {
+		return nil, nil //the share key registered is not the old key's, it stays
+	}
+	newShare, err := storage.ShareKey(newKey)
+	if err != nil {
+		return nil, err
+	}
+	bank.ShareKey = newShare.PublicKey().Bytes() //the share key follows the key
+	err = storage.PutBank(stub, *bank)
+	if err != nil {
+		return nil, err
+	}
+	moved, err := storage.RotateSharedPII(stub, caller.Institution, oldKey, newKey)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("- " + caller.Institution + " moved " + strconv.Itoa(moved) + " shared details to share key " + storage.KeyID(bank.ShareKey))
+	return nil, nil
+}
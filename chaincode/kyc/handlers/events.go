@@ -0,0 +1,59 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+)
+
+// ============================================================================================================================
+// Emit Event - set the transaction's chaincode event
+// ============================================================================================================================
+func emitEvent(stub shim.ChaincodeStubInterface, eventType string, aadharRef string, bank string) error {
+	return setKYCEvent(stub, model.KYCEvent{Type: eventType, AadharRef: aadharRef, Bank: bank})
+}
+
+// ============================================================================================================================
+// Emit Status Event - set the transaction's chaincode event for a record that changed status
+// ============================================================================================================================
+func emitStatusEvent(stub shim.ChaincodeStubInterface, eventType string, rec *model.KYCRecord) error {
+	return setKYCEvent(stub, model.KYCEvent{Type: eventType, AadharRef: rec.Subject.AadharRef, Bank: rec.Institution, Status: rec.Status})
+}
+
+func setKYCEvent(stub shim.ChaincodeStubInterface, event model.KYCEvent) error {
+	event.Version = model.EventPayloadVersion
+	event.TxID = stub.GetTxID()
+
+	var err error
+	event.Timestamp, err = storage.TxTimestamp(stub)
+	if err != nil {
+		return err
+	}
+	event.Actor = access.GetActor(stub)
+
+	jsonAsBytes, _ := json.Marshal(event)
+	return stub.SetEvent(event.Type, jsonAsBytes)
+}
@@ -0,0 +1,203 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+const aadharNumDoc = "12 digit aadhar number of the customer, with aadhaarSecret in the caller metadata, or their reference token"
+const aadharRefDoc = "reference token of the customer, worked out by the bank's own systems, invokes do not take the aadhar number"
+
+// Invokes are the functions run as transactions
+var Invokes = newRegistry("invocation",
+	Function{Name: "init", Handler: Init, Description: "seed missing state, does not clear anything, the secret aadhar numbers are hashed with goes in the caller metadata of the deploy as aadhaarSecret", Args: []Arg{
+		required("assetHolding", TypeInt, "test value stored under kyc"),
+		optional("allowReset", TypeBool, "allow the reset function, test networks only, can only be turned on at the first deploy"),
+		optional("retentionDays", TypeInt, "days closed KYC records are retained"),
+	}},
+	Function{Name: "reset", Handler: Reset, Description: "wipe all records, banks, consents and share requests, test networks only", Args: []Arg{
+		required("reason", TypeString, "why the ledger is reset, kept in the reset log"),
+	}},
+	Function{Name: "delete", Handler: Delete, Description: "close a KYC record, kept until purged", Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+		required("reason", TypeString, "why the record is closed"),
+	}},
+	Function{Name: "write", Handler: Write, Description: "create a minimum level record or re-verify one the calling bank verified", Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+		required("institution", TypeBank, "code of the calling bank"),
+	}},
+	Function{Name: "writeBank", Handler: WriteBank, Description: "register a new bank", Args: bankArgs},
+	Function{Name: "updateBank", Handler: UpdateBank, Description: "change a registered bank's details", Args: bankArgs},
+	Function{Name: "deactivateBank", Handler: DeactivateBank, Description: "take a bank out of the registry", Args: []Arg{
+		required("code", TypeBank, "IFSC style bank code"),
+	}},
+	Function{Name: "init_marble", Handler: InitMarble, Description: "create a verified KYC record", Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+		optional("pan", TypeString, "PAN of the customer"),
+		required("level", TypeString, "minimum, otp or full, offline records come from onboardOfflineKYC"),
+		required("institution", TypeBank, "code of the calling bank"),
+		repeated("documents", TypeString, "documents looked at, as type:number"),
+	}},
+	Function{Name: "set_user", Handler: SetUser, Description: "hand a record to another member bank, by the bank that verified it or a regulator, encrypted details are sealed for the new bank under kycKey from the caller metadata so only the bank can hand those over", Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+		required("institution", TypeBank, "code of the new verifying bank"),
+	}},
+	Function{Name: "setDetails", Handler: SetDetails, Description: "record a customer's name, date of birth or address, kept encrypted with their other details under kycKey from the caller metadata", Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+		optional("name", TypeString, "full name of the customer"),
+		optional("dob", TypeDate, "date of birth"),
+		optional("address", TypeString, "address of the customer"),
+	}},
+	Function{Name: "requestKYC", Handler: RequestKYC, Description: "ask to reuse another bank's KYC, returns the request id", Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+		required("purpose", TypeString, "account_opening, loan, insurance or investment"),
+		required("days", TypeInt, "how long the consent asked for runs"),
+	}},
+	Function{Name: "approveRequest", Handler: ApproveRequest, Description: "customer or verifying bank agrees to a share request", Args: []Arg{
+		required("requestId", TypeString, "id requestKYC returned"),
+	}},
+	Function{Name: "rejectRequest", Handler: RejectRequest, Description: "customer or verifying bank declines a share request", Args: []Arg{
+		required("requestId", TypeString, "id requestKYC returned"),
+		optional("reason", TypeString, "why it was declined"),
+	}},
+	Function{Name: "grantConsent", Handler: GrantConsent, Description: "let a bank read a customer's KYC for a purpose", Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+		required("bank", TypeBank, "code of the bank given the consent"),
+		required("purpose", TypeString, "account_opening, loan, insurance or investment"),
+		required("days", TypeInt, "how long the consent runs"),
+	}},
+	Function{Name: "revokeConsent", Handler: RevokeConsent, Description: "take a consent back", Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+		required("bank", TypeBank, "code of the bank given the consent"),
+		required("purpose", TypeString, "purpose the consent was given for"),
+	}},
+	Function{Name: "shareDetails", Handler: ShareDetails, Description: "verifying bank seals a customer's details for every bank holding a consent, under kycKey from the caller metadata", Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+	}},
+	Function{Name: "dropExpiredShares", Handler: DropExpiredShares, Description: "remove the details sealed for consents that have run out"},
+	Function{Name: "submit", Handler: Submit, Description: "bank submits a customer for verification", Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+		optional("pan", TypeString, "PAN of the customer"),
+		required("level", TypeString, "minimum, otp or full, offline records come from onboardOfflineKYC"),
+		repeated("documents", TypeString, "documents looked at, as type:number"),
+	}},
+	statusFunction("verify", "verify a pending record"),
+	statusFunction("suspend", "put a record on hold, reason required"),
+	statusFunction("reinstate", "lift a suspension"),
+	statusFunction("revoke", "revoke a record for good, reason required"),
+	statusFunction("expire", "mark a record due for re-verification"),
+	Function{Name: "renew", Handler: Renew, Description: "customer re-verified, due for review again later", Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+		optional("riskCategory", TypeString, "low, medium or high, found during re-verification"),
+	}},
+	Function{Name: "setRisk", Handler: SetRisk, Description: "change a customer's risk category", Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+		required("riskCategory", TypeString, "low, medium or high"),
+	}},
+	Function{Name: "purge", Handler: Purge, Description: "erase closed records past retention", Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+	}},
+	Function{Name: "attachDocument", Handler: AttachDocument, Description: "anchor a document's digest to a record", Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+		required("type", TypeString, "aadhaar_xml, pan_card, passport or address_proof"),
+		required("sha256", TypeString, "hex SHA-256 of the document"),
+		required("issuer", TypeString, "who issued the document"),
+		optional("expiry", TypeDate, "when the document expires"),
+		required("uri", TypeString, "where the bank keeps the document"),
+	}},
+	Function{Name: "setUIDAICertificate", Handler: SetUIDAICertificate, Description: "certificate offline e-KYC files are signed with", Args: []Arg{
+		required("certificate", TypeString, "PEM encoded certificate"),
+	}},
+	Function{Name: "onboardOfflineKYC", Handler: OnboardOfflineKYC, Description: "create a record from a signed offline e-KYC file, the XML goes in the caller metadata as offlineKyc", Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+		required("last4", TypeString, "last 4 digits of the aadhar number, matched against the file's reference id"),
+		optional("uri", TypeString, "where the bank keeps the file"),
+	}},
+	Function{Name: "setShareKey", Handler: SetShareKey, Description: "register the share key kycKey from the caller metadata stands for, other banks seal customer details they share with the bank to it"},
+	Function{Name: "rotateKey", Handler: RotateKey, Description: "re-encrypt a bank's customer details under a new key, the keys go in the caller metadata as kycKey and kycNewKey, kycNewKey alone encrypts details still in the clear, metadata is kept with the transaction so run with confidentiality on"},
+)
+
+// Queries are the functions run as queries, describe is added in init
+var Queries = newRegistry("query",
+	Function{Name: "read", Handler: Read, Description: "read a KYC record in full, banks the records they verified, other banks get what their valid consent discloses, as disclose does", Args: []Arg{
+		required("aadharNum", TypeString, aadharNumDoc),
+		optional("purpose", TypeString, "purpose of the consent to read under, needed when the bank holds several"),
+	}},
+	Function{Name: "readBank", Handler: ReadBank, Description: "read a bank's details", Args: []Arg{
+		required("code", TypeBank, "IFSC style bank code"),
+	}},
+	Function{Name: "readAll", Handler: ReadAll, Description: "read the list of registered banks"},
+	Function{Name: "readConsents", Handler: ReadConsents, Description: "read a customer's consents", Args: []Arg{
+		required("aadharNum", TypeString, aadharNumDoc),
+	}},
+	Function{Name: "readShareRequests", Handler: ReadShareRequests, Description: "read requests for a customer's KYC", Args: []Arg{
+		required("aadharNum", TypeString, aadharNumDoc),
+	}},
+	Function{Name: "history", Handler: History, Description: "read every version of a customer's KYC", Args: []Arg{
+		required("aadharNum", TypeString, aadharNumDoc),
+	}},
+	Function{Name: "list", Handler: List, Description: "page through KYC records, banks only see the records they verified", Args: []Arg{
+		optional("pageSize", TypeInt, "records per page"),
+		optional("bookmark", TypeString, "from the previous page, empty for the first"),
+		repeated("filters", TypeString, "name=value, names are institution, status, level, verifiedFrom and verifiedTo"),
+	}},
+	Function{Name: "dueForReview", Handler: DueForReview, Description: "records due for re-KYC before a date", Args: []Arg{
+		required("institution", TypeBank, "code of the verifying bank"),
+		required("date", TypeDate, "records due before this day"),
+		optional("pageSize", TypeInt, "records per page"),
+		optional("bookmark", TypeString, "from the previous page, empty for the first"),
+	}},
+	Function{Name: "readClosed", Handler: ReadClosed, Description: "read a customer's retained closed records", Args: []Arg{
+		required("aadharNum", TypeString, aadharNumDoc),
+	}},
+	Function{Name: "verifyDocument", Handler: VerifyDocument, Description: "check a digest against the anchored documents", Args: []Arg{
+		required("sha256", TypeString, "hex SHA-256 of the document"),
+		optional("aadharNum", TypeString, "only look at this customer's record"),
+	}},
+	Function{Name: "disclose", Handler: Disclose, Description: "the fields a bank's consent for a purpose entitles it to", Args: []Arg{
+		required("aadharNum", TypeString, aadharNumDoc),
+		required("purpose", TypeString, "account_opening, loan, insurance or investment"),
+	}},
+	Function{Name: "readProfiles", Handler: ReadProfiles, Description: "which fields each purpose discloses"},
+)
+
+var bankArgs = []Arg{
+	required("code", TypeBank, "IFSC style bank code"),
+	required("legalName", TypeString, ""),
+	required("licenceId", TypeString, "RBI licence"),
+	required("contact", TypeString, "KYC contact of the bank"),
+}
+
+func init() {
+	Queries.Add(Function{Name: "describe", Handler: Describe, Description: "list the supported functions and their signatures"}) //describe reads Queries itself
+}
+
+// ============================================================================================================================
+// Status Function - the lifecycle functions share one handler that is told which action it runs
+// ============================================================================================================================
+func statusFunction(action string, description string) Function {
+	return Function{Name: action, Description: description, Args: []Arg{
+		required("aadharNum", TypeString, aadharRefDoc),
+		optional("reason", TypeString, "why the status changes"),
+	}, Handler: func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+		return ChangeStatus(stub, action, args)
+	}}
+}
@@ -0,0 +1,47 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+)
+
+// ============================================================================================================================
+// History - every version of a customer's KYC record, oldest first
+// ============================================================================================================================
+func History(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0
+	// "aadharNum"
+
+	ref, err := storage.CustomerRef(stub, "aadharNum", args[0])
+	if err != nil {
+		return nil, err
+	}
+	history, err := storage.GetKYCHistory(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+	jsonAsBytes, _ := json.Marshal(history)
+	return jsonAsBytes, nil
+}
@@ -0,0 +1,132 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+const DefaultPageSize = 20
+const MaxPageSize = 100
+
+// ============================================================================================================================
+// Parse List Filter - "name=value" arguments, names are institution, status, level, verifiedFrom and verifiedTo
+// ============================================================================================================================
+func parseListFilter(args []string) (storage.ListFilter, error) {
+	var filter storage.ListFilter
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || len(parts[1]) == 0 {
+			return filter, model.InvalidArgument("filters", "Filter \""+arg+"\" must look like name=value")
+		}
+		value := strings.TrimSpace(parts[1])
+		var err error
+		switch parts[0] {
+		case "institution":
+			filter.Institution = model.BankCode(value)
+			if err = validation.BankCode("institution", filter.Institution); err != nil {
+				return filter, err
+			}
+		case "status":
+			filter.Status = strings.ToLower(value)
+			if !model.IsKYCStatus(filter.Status) {
+				return filter, validation.Invalid("status", "expecting one of "+strings.Join(model.KYCStatuses, ", "))
+			}
+		case "level":
+			filter.Level = strings.ToLower(value)
+			if !model.IsVerificationLevel(filter.Level) {
+				return filter, validation.Invalid("level", "expecting one of "+strings.Join(model.VerificationLevels, ", "))
+			}
+		case "verifiedFrom":
+			filter.VerifiedFrom, err = strconv.ParseInt(value, 10, 64)
+		case "verifiedTo":
+			filter.VerifiedTo, err = strconv.ParseInt(value, 10, 64)
+		default:
+			return filter, model.InvalidArgument("filters", "Unknown filter "+parts[0]+", expecting institution, status, level, verifiedFrom or verifiedTo")
+		}
+		if err != nil || filter.VerifiedFrom < 0 || filter.VerifiedTo < 0 {
+			return filter, validation.Invalid(parts[0], "must be a utc timestamp in ms")
+		}
+	}
+	return filter, nil
+}
+
+// ============================================================================================================================
+// List - page through KYC records, banks only see the records they verified
+// ============================================================================================================================
+func List(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//    0            1            2...
+	// *"20"*, *"bookmark"*, *"institution=SBIN0000001"*	<- all optional, pass "" for the first page
+	bookmark := argAt(args, 1)
+	pageSize, err := parsePageSize(argAt(args, 0))
+	if err != nil {
+		return nil, err
+	}
+	var filterArgs []string
+	if len(args) > 2 {
+		filterArgs = args[2:]
+	}
+	filter, err := parseListFilter(filterArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	if caller.Role == access.RoleBank {
+		if filter.Institution != "" && filter.Institution != caller.Institution {
+			return nil, model.PermissionDenied("banks can only list the records they verified")
+		}
+		filter.Institution = caller.Institution
+	}
+
+	start, end := filter.ScanRange()
+	page, err := storage.PageKYCIndex(stub, start, end, pageSize, bookmark, filter.Matches)
+	if err != nil {
+		return nil, err
+	}
+	jsonAsBytes, _ := json.Marshal(page)
+	return jsonAsBytes, nil
+}
+
+// ============================================================================================================================
+// Parse Page Size - optional page size argument, empty for the default
+// ============================================================================================================================
+func parsePageSize(arg string) (int, error) {
+	if len(arg) == 0 {
+		return DefaultPageSize, nil
+	}
+	pageSize, err := strconv.Atoi(arg)
+	if err != nil || pageSize < 1 || pageSize > MaxPageSize {
+		return 0, validation.Invalid("pageSize", "must be a whole number between 1 and "+strconv.Itoa(MaxPageSize))
+	}
+	return pageSize, nil
+}
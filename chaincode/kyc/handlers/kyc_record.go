@@ -0,0 +1,347 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package handlers holds the chaincode functions, each takes the stub and its string arguments
+// and returns what the shim should answer with. The chaincode's Invoke and Query route to them
+// after checking the caller against the access policy.
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+// the NOT_FOUND error for an aadhar number with no KYC record
+func noKYCRecord() error {
+	return model.NotFound("No KYC record for this aadhar number")
+}
+
+// ============================================================================================================================
+// Read - read a KYC record, the verifying bank gets the customer's details and other banks only what their consent discloses
+// ============================================================================================================================
+func Read(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	aadharNum := args[0]
+	ref, err := storage.CustomerRef(stub, "aadharNum", aadharNum)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := storage.GetKYCRecord(stub, ref) //get the record, strictly decoded
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, noKYCRecord()
+	}
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	if caller.Role == access.RoleBank && caller.Institution != rec.Institution { //other banks read what their consent discloses
+		purpose, err := consentedPurpose(stub, caller.Institution, ref, argAt(args, 1))
+		if err != nil {
+			return nil, err
+		}
+		disclosure, err := discloseKYCRecord(stub, caller, rec, purpose)
+		if err != nil {
+			return nil, err
+		}
+		jsonAsBytes, _ := json.Marshal(disclosure)
+		return jsonAsBytes, nil
+	}
+	err = access.CheckReadAccess(caller, rec)
+	if err != nil {
+		return nil, err
+	}
+	if access.CanReadPII(caller, rec) { //the verifying bank gets the customer's details
+		err = storage.MergeCustomerPII(stub, storage.KYCKey(ref), rec, caller.Institution)
+		if err != nil {
+			return nil, err
+		}
+	}
+	jsonAsBytes, _ := json.Marshal(rec)
+	return jsonAsBytes, nil //send it onward
+}
+
+// ============================================================================================================================
+// Write - write variable into chaincode state
+// ============================================================================================================================
+func Write(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var aadharNum, institution string // Entities
+	var err error
+	fmt.Println("running write()")
+
+	//   0            1
+	// "aadharNum", "institution"	<- has to be the calling bank
+
+	aadharNum = args[0]
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	institution = caller.Institution
+	if model.BankCode(args[1]) != institution {
+		return nil, model.PermissionDenied("banks can only write records as themselves, " + institution)
+	}
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := storage.CustomerRef(stub, "aadharNum", aadharNum)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := storage.GetKYCRecord(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if rec == nil { //first verification, start a minimum level record
+		created := model.NewKYCRecord(ref, institution, model.LevelMinimum, now)
+		err = storage.PutKYCRecord(stub, created, writer(stub))
+		if err != nil {
+			return nil, err
+		}
+		return nil, emitEvent(stub, model.EventKYCCreated, ref, institution)
+	}
+
+	if rec.Institution != institution { //another bank's customer is handed over with set_user, not taken
+		return nil, model.PermissionDenied("only " + rec.Institution + " can re-verify this KYC record")
+	}
+	err = model.RequireStatus(rec, "re-verify", model.StatusVerified) //anything else goes through the lifecycle functions
+	if err != nil {
+		return nil, err
+	}
+	rec.UpdatedAt = now //re-verified by this institution
+	rec.VerifiedAt = now
+	err = storage.PutKYCRecord(stub, *rec, writer(stub)) //write the record into the chaincode state
+	if err != nil {
+		return nil, err
+	}
+	return nil, emitEvent(stub, model.EventKYCUpdated, ref, institution)
+}
+
+// ============================================================================================================================
+// Init Marble - create a new marble, store into chaincode state
+// ============================================================================================================================
+func InitMarble(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var err error
+
+	//   0              1             2        3           4...
+	// "aadharNum", "ABCDE1234F", "full", "bankA", *"pan:ABCDE1234F"*
+
+	//input sanitation
+	fmt.Println("- start init marble")
+	aadharNum := args[0]
+	pan := strings.ToUpper(args[1]) //PAN is optional, empty means not supplied
+	level, err := bankLevel(args[2])
+	if err != nil {
+		return nil, err
+	}
+	institution := model.BankCode(args[3])
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	if institution != caller.Institution {
+		return nil, model.PermissionDenied("banks can only onboard customers as themselves, " + caller.Institution)
+	}
+	if len(pan) > 0 {
+		err = validation.PAN("pan", pan)
+		if err != nil {
+			return nil, err
+		}
+	}
+	docs, err := model.ParseDocumentRefs(args[4:])
+	if err != nil {
+		return nil, err
+	}
+	err = validation.DocumentRefs(docs)
+	if err != nil {
+		return nil, err
+	}
+
+	//check if aadhar Number already exists
+	ref, err := storage.CustomerRef(stub, "aadharNum", aadharNum)
+	if err != nil {
+		return nil, err
+	}
+	res, err := storage.GetKYCRecord(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+	if res != nil {
+		fmt.Println("eKYC for this Aadhar number is arleady done: " + ref)
+		return nil, model.AlreadyExists("Aadhar number already exists") //all stop if aadharNum already exists
+	}
+
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	rec := model.NewKYCRecord(ref, institution, level, now)
+	rec.Subject.PAN = pan
+	rec.Documents = docs
+	err = storage.PutKYCRecord(stub, rec, writer(stub)) //store record with the reference token as key, indexes it too
+	if err != nil {
+		return nil, err
+	}
+	err = emitEvent(stub, model.EventKYCCreated, ref, institution)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("-====== end init marble")
+	return nil, nil
+}
+
+// ============================================================================================================================
+// Set User - hand a record over to another member bank, only the bank that verified it or a regulator can. Encrypted
+// details are sealed for the new bank with the handing bank's kycKey, so a regulator can only hand over records without.
+// ============================================================================================================================
+func SetUser(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0            1
+	// "aadharNum", "bankB"
+
+	fmt.Println("- start set user")
+	rec, err := loadOwnKYCRecord(stub, args[0], "reassign")
+	if err != nil {
+		return nil, err
+	}
+	err = model.RequireStatus(rec, "reassign", model.StatusPending, model.StatusVerified, model.StatusSuspended, model.StatusExpired)
+	if err != nil {
+		return nil, err
+	}
+	institution := model.BankCode(args[1])
+	if err = requireActiveBank(stub, institution); err != nil {
+		return nil, err
+	}
+	if rec.PII != nil && rec.PII.Encrypted && institution != rec.Institution {
+		caller, err := access.GetCaller(stub)
+		if err != nil {
+			return nil, err
+		}
+		if caller.Role != access.RoleBank {
+			return nil, model.FailedPrecondition("Customer details are encrypted, only " + rec.Institution + " can hand this KYC record over")
+		}
+		if err = storage.HandOverPII(stub, storage.KYCKey(rec.Subject.AadharRef), institution); err != nil {
+			return nil, err
+		}
+	}
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	rec.Institution = institution //change the verifying institution
+	rec.UpdatedAt = now
+	err = storage.PutKYCRecord(stub, *rec, writer(stub)) //rewrite the record with the reference token as key
+	if err != nil {
+		return nil, err
+	}
+	err = emitEvent(stub, model.EventKYCUpdated, rec.Subject.AadharRef, institution)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("- end set user")
+	return nil, nil
+}
+
+// ============================================================================================================================
+// Set Details - the verifying bank records the customer's name, date of birth or address, they are sealed with the
+// customer's other details under the bank's key
+// ============================================================================================================================
+func SetDetails(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0               1              2              3
+	// "aadharNum", *"Asha Rao"*, *"1990-01-01"*, *"12 MG Road, Bengaluru 560001"*		<- left out ones stay as they are
+	name, dob, address := argAt(args, 1), argAt(args, 2), argAt(args, 3)
+	if len(name) == 0 && len(dob) == 0 && len(address) == 0 {
+		return nil, validation.Invalid("name", "pass at least one of name, dob and address")
+	}
+	rec, err := loadOwnKYCRecord(stub, args[0], "change the details of")
+	if err != nil {
+		return nil, err
+	}
+	err = model.RequireStatus(rec, "change the details of", model.StatusPending, model.StatusVerified, model.StatusSuspended, model.StatusExpired)
+	if err != nil {
+		return nil, err
+	}
+	err = storage.MergeCustomerPII(stub, storage.KYCKey(rec.Subject.AadharRef), rec, rec.Institution) //the details are stored again as a whole
+	if err != nil {
+		return nil, err
+	}
+	if len(name) > 0 {
+		rec.Subject.Name = name
+	}
+	if len(dob) > 0 {
+		rec.Subject.DOB = dob
+	}
+	if len(address) > 0 {
+		rec.Subject.Address = address
+	}
+	rec.UpdatedAt, err = storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = storage.PutKYCRecord(stub, *rec, writer(stub))
+	if err != nil {
+		return nil, err
+	}
+	return nil, emitEvent(stub, model.EventKYCUpdated, rec.Subject.AadharRef, rec.Institution)
+}
+
+// ============================================================================================================================
+// Writer - the caller as storage writes them into a record's history and marks the details sealed with their key
+// ============================================================================================================================
+func writer(stub shim.ChaincodeStubInterface) storage.Writer {
+	caller, _ := access.GetCaller(stub) //checked by Authorize already
+	return storage.Writer{Actor: caller.Actor(), Institution: caller.Institution}
+}
+
+// ============================================================================================================================
+// Require Active Bank - a bank records or consents are handed to has to be a registered, active member
+// ============================================================================================================================
+func requireActiveBank(stub shim.ChaincodeStubInterface, code string) error {
+	bank, err := storage.GetBank(stub, code)
+	if err != nil {
+		return err
+	}
+	if bank == nil || bank.Status != model.BankActive {
+		return model.NotFound(code + " is not an active member bank")
+	}
+	return nil
+}
+
+// ============================================================================================================================
+// Bank Level - a verification level a bank attests to itself, offline records only come from a signed file through
+// onboardOfflineKYC
+// ============================================================================================================================
+func bankLevel(level string) (string, error) {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if level == model.LevelOffline {
+		return "", validation.Invalid("level", "offline records can only be created from a signed file with onboardOfflineKYC")
+	}
+	return level, nil
+}
@@ -0,0 +1,180 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+// ============================================================================================================================
+// Load Own KYC Record - get a record a bank caller is about to change, banks may only change records they verified
+// ============================================================================================================================
+func loadOwnKYCRecord(stub shim.ChaincodeStubInterface, aadharNum string, action string) (*model.KYCRecord, error) {
+	ref, err := storage.CustomerRef(stub, "aadharNum", aadharNum)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := storage.GetKYCRecord(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, noKYCRecord()
+	}
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	if caller.Role == access.RoleBank && caller.Institution != rec.Institution {
+		return nil, model.PermissionDenied("only " + rec.Institution + " can " + action + " this KYC record")
+	}
+	return rec, nil
+}
+
+// ============================================================================================================================
+// Submit - the calling bank submits a new customer, or an expired one for re-verification, the record starts pending
+// ============================================================================================================================
+func Submit(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0              1             2        3...
+	// "aadharNum", "ABCDE1234F", "full", *"pan:ABCDE1234F"*
+	fmt.Println("- start submit")
+
+	pan := strings.ToUpper(args[1]) //PAN is optional, empty means not supplied
+	if len(pan) > 0 {
+		if err := validation.PAN("pan", pan); err != nil {
+			return nil, err
+		}
+	}
+	level, err := bankLevel(args[2])
+	if err != nil {
+		return nil, err
+	}
+	docs, err := model.ParseDocumentRefs(args[3:])
+	if err != nil {
+		return nil, err
+	}
+	err = validation.DocumentRefs(docs)
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := storage.CustomerRef(stub, "aadharNum", args[0])
+	if err != nil {
+		return nil, err
+	}
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := storage.GetKYCRecord(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	eventType := model.EventKYCCreated
+	if rec == nil {
+		created := model.NewKYCRecord(ref, caller.Institution, level, now)
+		rec = &created
+		rec.VerifiedAt = 0 //not verified yet
+	} else {
+		if rec.Status != model.StatusExpired {
+			return nil, model.Conflict(fmt.Sprintf("Illegal transition: cannot submit a KYC record that is %s, submit only applies to new or %s records", rec.Status, model.StatusExpired)).
+				With("status", rec.Status).With("expecting", model.StatusExpired)
+		}
+		if rec.Institution != caller.Institution {
+			return nil, model.PermissionDenied("only " + rec.Institution + " can resubmit this KYC record")
+		}
+		err = storage.MergeCustomerPII(stub, storage.KYCKey(ref), rec, caller.Institution) //the name, date of birth and address are kept
+		if err != nil {
+			return nil, err
+		}
+		rec.Level = level
+		rec.UpdatedAt = now
+		rec.StatusReason = ""
+		eventType = model.EventKYCStatusChanged
+	}
+	rec.Subject.PAN = pan
+	rec.Documents = docs
+	rec.Status = model.StatusPending
+	err = storage.PutKYCRecord(stub, *rec, writer(stub))
+	if err != nil {
+		return nil, err
+	}
+	err = emitStatusEvent(stub, eventType, rec)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("- end submit")
+	return nil, nil
+}
+
+// ============================================================================================================================
+// Change Status - run one of kycTransitions on a customer's record, banks only on records they submitted
+// ============================================================================================================================
+func ChangeStatus(stub shim.ChaincodeStubInterface, action string, args []string) ([]byte, error) {
+	//   0              1
+	// "aadharNum", *"reason"*		<- reason is required to suspend or revoke
+	reason := argAt(args, 1)
+	if model.KYCTransitions[action].ReasonRequired && len(reason) == 0 {
+		return nil, validation.Invalid("reason", "required to "+action+" a KYC record")
+	}
+
+	rec, err := loadOwnKYCRecord(stub, args[0], action)
+	if err != nil {
+		return nil, err
+	}
+	err = model.CheckTransition(action, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	rec.Status = model.KYCTransitions[action].To
+	rec.StatusReason = reason
+	rec.UpdatedAt = now
+	if action == "verify" {
+		rec.VerifiedAt = now
+	}
+	err = storage.PutKYCRecord(stub, *rec, writer(stub))
+	if err != nil {
+		return nil, err
+	}
+
+	eventType := model.EventKYCStatusChanged
+	if rec.Status == model.StatusRevoked {
+		eventType = model.EventKYCRevoked
+	}
+	return nil, emitStatusEvent(stub, eventType, rec)
+}
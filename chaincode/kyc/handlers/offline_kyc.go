@@ -0,0 +1,139 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/xmldsig"
+)
+
+// ============================================================================================================================
+// Set UIDAI Certificate - store the certificate offline e-KYC files must be signed with, replaces any earlier one
+// ============================================================================================================================
+func SetUIDAICertificate(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0
+	// "-----BEGIN CERTIFICATE-----..."
+	if _, err := validation.UIDAICertificate([]byte(args[0])); err != nil {
+		return nil, err
+	}
+	fmt.Println("- UIDAI certificate replaced")
+	return nil, storage.PutUIDAICertificate(stub, []byte(args[0]))
+}
+
+// ============================================================================================================================
+// Onboard Offline KYC - create a record for the calling bank from a signed offline e-KYC file, only its digest and
+// what was verified are kept on the record, the customer's name, date of birth and address go with their details
+// ============================================================================================================================
+func OnboardOfflineKYC(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0           1           2
+	// "aadharRef", "0006", *"https://host/path"*		<- optional, where the bank keeps the file
+	// the XML itself comes in the caller metadata as offlineKyc. The file names the last 4 digits of the aadhar number,
+	// the bank's systems work the token out from the same number, the chaincode cannot tie the two together
+	ref, err := storage.CustomerRef(stub, "aadharNum", args[0])
+	if err != nil {
+		return nil, err
+	}
+	last4 := argAt(args, 1)
+	if len(last4) != 4 || strings.Trim(last4, "0123456789") != "" {
+		return nil, validation.Invalid("last4", "must be the last 4 digits of the aadhar number")
+	}
+	xmlBytes, err := storage.CallerOfflineKYC(stub)
+	if err != nil {
+		return nil, err
+	}
+	if xmlBytes == nil {
+		return nil, model.InvalidArgument(storage.TransientOfflineKYC, "Pass the signed offline e-KYC file as "+storage.TransientOfflineKYC+" in the caller metadata")
+	}
+	uri := argAt(args, 2)
+	if len(uri) > 0 {
+		if err = validation.StorageURI("uri", uri); err != nil {
+			return nil, err
+		}
+	}
+
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := storage.GetUIDAICertificate(stub, now)
+	if err != nil {
+		return nil, err
+	}
+	root, err := xmldsig.Verify(xmlBytes, cert)
+	if err != nil {
+		return nil, model.InvalidArgument(storage.TransientOfflineKYC, err.Error()) //malformed, unsigned or signed by someone else
+	}
+	off, err := validation.OfflineKYC(root, last4)
+	if err != nil {
+		return nil, err
+	}
+	details, err := validation.OfflineDetails(root)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := storage.GetKYCRecord(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, model.AlreadyExists("Aadhar number already exists")
+	}
+	digest := sha256.Sum256(xmlBytes)
+	sum := hex.EncodeToString(digest[:])
+	anchored, err := storage.OfflineKYCAnchored(stub, sum)
+	if err != nil {
+		return nil, err
+	}
+	used, err := storage.IndexedRefs(stub, storage.IndexDocument, sum) //files anchored before offlinekyc_ kept only the index
+	if err != nil {
+		return nil, err
+	}
+	if anchored || len(used) > 0 { //a file onboards one customer once, it cannot be replayed for another with the same last 4 digits
+		return nil, model.AlreadyExists("This offline e-KYC file is already anchored to a KYC record")
+	}
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	rec := model.NewKYCRecord(ref, caller.Institution, model.LevelOffline, now)
+	rec.Offline = &off
+	rec.Subject.Name, rec.Subject.DOB, rec.Subject.Address = details.Name, details.DOB, details.Address //sealed under kycKey like any other details
+	rec.Evidence = append(rec.Evidence, model.DocumentEvidence{Type: model.DocAadhaarXML, SHA256: sum, Issuer: "UIDAI", URI: uri, AnchoredAt: now, AnchoredBy: caller.Actor()})
+	err = storage.PutKYCRecord(stub, rec, writer(stub))
+	if err != nil {
+		return nil, err
+	}
+	err = storage.AnchorOfflineKYC(stub, sum, now)
+	if err != nil {
+		return nil, err
+	}
+	return nil, emitStatusEvent(stub, model.EventKYCCreated, &rec)
+}
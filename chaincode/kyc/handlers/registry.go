@@ -0,0 +1,363 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+// Every chaincode function is registered here with the arguments it takes. Call checks the
+// arguments against that schema before the handler runs, so handlers can index args
+// without counting them, and describe hands the same schemas to clients. Clients may pass
+// the arguments positionally, or as a single JSON object keyed by argument name, e.g.
+// {"aadharNum": "...", "bank": "HDFC0000001", "purpose": "loan", "days": 30}. A lone
+// argument starting with { is taken as that object, except for functions whose only
+// argument is a string, which could start with a brace itself. Putting --json before the
+// object forces it to be taken as named arguments for any function.
+
+// NamedArgs may come before a JSON object of named arguments, it forces them to be taken as named
+const NamedArgs = "--json"
+
+// Handler runs a chaincode function, args have already been checked against its schema
+type Handler func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error)
+
+// argument types
+const (
+	TypeString = "string"
+	TypeInt    = "int"  //whole number, may be negative
+	TypeBool   = "bool" //true or false
+	TypeDate   = "date" //yyyy-mm-dd
+	TypeBank   = "bank" //IFSC style bank code, any case
+)
+
+type Arg struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required"`           //has to be passed and not be empty, optional ones may be passed as ""
+	Repeated    bool   `json:"repeated,omitempty"` //last argument only, takes every argument left
+	Description string `json:"description,omitempty"`
+}
+
+type Function struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Args        []Arg    `json:"args"`
+	Signature   string   `json:"signature"`
+	Roles       []string `json:"roles,omitempty"` //from the access policy, filled in by describe
+	Handler     Handler  `json:"-"`
+}
+
+type Registry struct {
+	Kind      string //invocation or query, used in messages
+	Functions map[string]Function
+}
+
+func required(name string, argType string, description string) Arg {
+	return Arg{Name: name, Type: argType, Required: true, Description: description}
+}
+
+func optional(name string, argType string, description string) Arg {
+	return Arg{Name: name, Type: argType, Description: description}
+}
+
+func repeated(name string, argType string, description string) Arg {
+	return Arg{Name: name, Type: argType, Repeated: true, Description: description}
+}
+
+func newRegistry(kind string, functions ...Function) Registry {
+	registry := Registry{Kind: kind, Functions: map[string]Function{}}
+	for _, fn := range functions {
+		registry.Add(fn)
+	}
+	return registry
+}
+
+// ============================================================================================================================
+// Add - register a function, replaces any function of the same name
+// ============================================================================================================================
+func (r Registry) Add(fn Function) {
+	if fn.Args == nil {
+		fn.Args = []Arg{}
+	}
+	fn.Signature = signature(fn)
+	r.Functions[fn.Name] = fn
+}
+
+// ============================================================================================================================
+// Call - check the arguments against the function's schema and run it
+// ============================================================================================================================
+func (r Registry) Call(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	fn, ok := r.Functions[function]
+	if !ok {
+		return nil, model.InvalidArgument("function", "Received unknown function "+r.Kind+" \""+function+"\"")
+	}
+	if fn.IsNamed(args) {
+		var err error
+		args, err = fn.Positional(args[len(args)-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := fn.Check(args); err != nil {
+		return nil, err
+	}
+	return fn.Handler(stub, args)
+}
+
+// ============================================================================================================================
+// Is Named - true when the arguments are a JSON object rather than positional strings, either NamedArgs and the object
+// or the object alone when the function's first argument cannot start with a brace
+// ============================================================================================================================
+func (fn Function) IsNamed(args []string) bool {
+	if len(args) == 2 && args[0] == NamedArgs {
+		return true
+	}
+	if len(args) != 1 || !strings.HasPrefix(strings.TrimSpace(args[0]), "{") {
+		return false
+	}
+	return len(fn.Args) != 1 || fn.Args[0].Type != TypeString //a lone string argument may be free text starting with {
+}
+
+// ============================================================================================================================
+// Positional - turn a JSON object of named arguments into the positional arguments the handler takes
+// ============================================================================================================================
+func (fn Function) Positional(object string) ([]string, error) {
+	//  {"aadharNum": "...", "bank": "HDFC0000001", "purpose": "loan", "days": 30}  ->  ["...", "HDFC0000001", "loan", "30"]
+	named := map[string]json.RawMessage{}
+	decoder := json.NewDecoder(strings.NewReader(object))
+	decoder.UseNumber()
+	if err := decoder.Decode(&named); err != nil {
+		return nil, model.InvalidArgument("args", "Arguments for "+fn.Name+" are not a JSON object: "+err.Error())
+	}
+	for name := range named {
+		if fn.arg(name) < 0 {
+			return nil, validation.Invalid(name, "not an argument of "+fn.Signature)
+		}
+	}
+
+	args := []string{}
+	given := 0 //arguments up to the last one passed, optional ones left out after it are dropped
+	for _, arg := range fn.Args {
+		raw, ok := named[arg.Name]
+		if !ok || string(raw) == "null" {
+			if arg.Required {
+				return nil, validation.Invalid(arg.Name, "required")
+			}
+			args = append(args, "") //left out
+			continue
+		}
+		if arg.Repeated {
+			var values []json.RawMessage
+			if err := json.Unmarshal(raw, &values); err != nil {
+				values = []json.RawMessage{raw} //a lone value is a list of one
+			}
+			for _, value := range values {
+				str, err := arg.fromJSON(value)
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, str)
+			}
+			given = len(args)
+			continue
+		}
+		str, err := arg.fromJSON(raw)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, str)
+		given = len(args)
+	}
+	return args[:given], nil
+}
+
+// index of the named argument, -1 when the function does not take it
+func (fn Function) arg(name string) int {
+	for i, arg := range fn.Args {
+		if arg.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// one named argument as the string the handler would have been passed positionally
+func (a Arg) fromJSON(raw json.RawMessage) (string, error) {
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return "", validation.Invalid(a.Name, err.Error())
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", validation.Invalid(a.Name, "must be a "+a.Type+", not a JSON object or list")
+}
+
+// ============================================================================================================================
+// Check - the number of arguments, that required ones are not empty and that each parses as its type
+// ============================================================================================================================
+func (fn Function) Check(args []string) error {
+	least, most := fn.arity()
+	if len(args) < least || (most >= 0 && len(args) > most) {
+		return model.InvalidArgument("args", "Incorrect number of arguments for "+fn.Name+". Expecting "+expecting(least, most)+": "+fn.Signature).
+			With("expecting", expecting(least, most)).With("signature", fn.Signature)
+	}
+	for i, value := range args {
+		arg := fn.Args[len(fn.Args)-1]
+		if i < len(fn.Args) {
+			arg = fn.Args[i]
+		}
+		if err := arg.Check(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ============================================================================================================================
+// Check - one argument against its schema
+// ============================================================================================================================
+func (a Arg) Check(value string) error {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		if a.Required {
+			return validation.Invalid(a.Name, "required")
+		}
+		return nil //left out
+	}
+	var err error
+	switch a.Type {
+	case TypeInt:
+		if _, err = strconv.Atoi(value); err != nil {
+			return validation.Invalid(a.Name, "must be a whole number")
+		}
+	case TypeBool:
+		if _, err = strconv.ParseBool(value); err != nil {
+			return validation.Invalid(a.Name, "must be true or false")
+		}
+	case TypeDate:
+		if _, err = time.Parse(model.ReviewDateLayout, value); err != nil {
+			return validation.Invalid(a.Name, "must look like "+model.ReviewDateLayout)
+		}
+	case TypeBank:
+		return validation.BankCode(a.Name, model.BankCode(value))
+	}
+	return nil
+}
+
+// argument i with spaces trimmed, empty when it was left out
+func argAt(args []string, i int) string {
+	if i < len(args) {
+		return strings.TrimSpace(args[i])
+	}
+	return ""
+}
+
+// least and most arguments a function takes, most is -1 when the last argument is repeated
+func (fn Function) arity() (int, int) {
+	least := 0
+	for i, arg := range fn.Args {
+		if arg.Required {
+			least = i + 1
+		}
+	}
+	if len(fn.Args) > 0 && fn.Args[len(fn.Args)-1].Repeated {
+		return least, -1
+	}
+	return least, len(fn.Args)
+}
+
+func expecting(least int, most int) string {
+	switch {
+	case most < 0:
+		return "at least " + strconv.Itoa(least)
+	case least == most:
+		return strconv.Itoa(least)
+	case least+1 == most:
+		return strconv.Itoa(least) + " or " + strconv.Itoa(most)
+	}
+	return strconv.Itoa(least) + " to " + strconv.Itoa(most)
+}
+
+// signature of a function as clients would write it, grantConsent(aadharNum, bank, purpose, days)
+func signature(fn Function) string {
+	names := []string{}
+	for _, arg := range fn.Args {
+		name := arg.Name
+		if arg.Repeated {
+			name += "..."
+		} else if !arg.Required {
+			name = "[" + name + "]"
+		}
+		names = append(names, name)
+	}
+	return fn.Name + "(" + strings.Join(names, ", ") + ")"
+}
+
+// ============================================================================================================================
+// Describe - every function a registry holds with its arguments and the roles allowed to call it, sorted by name
+// ============================================================================================================================
+func (r Registry) Describe(policy map[string][]string) []Function {
+	functions := []Function{}
+	for _, fn := range r.Functions {
+		fn.Roles = policy[fn.Name]
+		functions = append(functions, fn)
+	}
+	sort.Sort(byName(functions))
+	return functions
+}
+
+type byName []Function //sort.Slice needs Go 1.8, the peers build chaincode with 1.7
+
+func (f byName) Len() int           { return len(f) }
+func (f byName) Less(i, j int) bool { return f[i].Name < f[j].Name }
+func (f byName) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+
+type Description struct {
+	Version string     `json:"version"`
+	Invoke  []Function `json:"invoke"`
+	Query   []Function `json:"query"`
+}
+
+// ============================================================================================================================
+// Describe - list the supported invoke and query functions and their signatures
+// ============================================================================================================================
+func Describe(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	description := Description{Version: ChaincodeVersion, Invoke: Invokes.Describe(access.InvokePolicy), Query: Queries.Describe(access.QueryPolicy)}
+	jsonAsBytes, _ := json.Marshal(description)
+	return jsonAsBytes, nil
+}
@@ -0,0 +1,158 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+)
+
+// ============================================================================================================================
+// Delete - close a customer's KYC record, it is kept for the retention period and then can be purged
+// ============================================================================================================================
+func Delete(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0              1
+	// "aadharNum", "reason for closing"
+
+	ref, err := storage.CustomerRef(stub, "aadharNum", args[0])
+	if err != nil {
+		return nil, err
+	}
+	rec, err := storage.GetKYCRecord(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, noKYCRecord()
+	}
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	config, err := storage.GetConfig(stub)
+	if err != nil {
+		return nil, err
+	}
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	closed := model.ClosedKYCRecord{Record: *rec, TxID: stub.GetTxID(), Reason: strings.TrimSpace(args[1]), ClosedBy: caller.Actor(), ClosedAt: now}
+	closed.PurgeAfter = now + int64(config.Retention())*model.MsPerDay
+	err = storage.CloseKYCRecord(stub, closed) //out of the live records and their indexes, the details follow it
+	if err != nil {
+		return nil, err
+	}
+	return nil, emitEvent(stub, model.EventKYCClosed, ref, rec.Institution)
+}
+
+// ============================================================================================================================
+// Read Closed - a customer's closed records that are still being retained
+// ============================================================================================================================
+func ReadClosed(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0
+	// "aadharNum"
+	ref, err := storage.CustomerRef(stub, "aadharNum", args[0])
+	if err != nil {
+		return nil, err
+	}
+	_, closed, err := storage.GetClosedKYCRecords(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+	jsonAsBytes, _ := json.Marshal(closed)
+	return jsonAsBytes, nil
+}
+
+// ============================================================================================================================
+// Purge - erase a customer's closed records whose retention period is over, with the history that led up to them,
+// records still retained are left for later and the purge only fails when none of them can go yet
+// ============================================================================================================================
+func Purge(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0
+	// "aadharNum"
+	ref, err := storage.CustomerRef(stub, "aadharNum", args[0])
+	if err != nil {
+		return nil, err
+	}
+	keys, closed, err := storage.GetClosedKYCRecords(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(closed) == 0 {
+		return nil, model.NotFound("No closed KYC record for this aadhar number")
+	}
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	purged := map[string]bool{} //closing transactions of the purged records
+	var retainedUntil int64
+	for i, c := range closed {
+		if now < c.PurgeAfter { //retention may have been shortened since, a later record can be due before this one
+			if retainedUntil == 0 || c.PurgeAfter < retainedUntil {
+				retainedUntil = c.PurgeAfter
+			}
+			continue
+		}
+		if err = storage.PurgeClosedKYCRecord(stub, keys[i]); err != nil {
+			return nil, err
+		}
+		purged[c.TxID] = true
+	}
+	if len(purged) == 0 {
+		until := time.Unix(0, retainedUntil*int64(time.Millisecond)).UTC().Format(model.ReviewDateLayout)
+		return nil, model.FailedPrecondition("KYC record is retained until "+until+" and cannot be purged yet").With("retainedUntil", until)
+	}
+
+	history, err := storage.GetKYCHistory(stub, ref) //drop the versions that led up to the purged records
+	if err != nil {
+		return nil, err
+	}
+	kept, record := []model.KYCVersion{}, []model.KYCVersion{}
+	for _, version := range history {
+		record = append(record, version)
+		if version.Action != model.HistoryClosed {
+			continue
+		}
+		if !purged[version.TxID] { //a retained record keeps its history
+			kept = append(kept, record...)
+		}
+		record = []model.KYCVersion{}
+	}
+	kept = append(kept, record...) //the live record, if the customer came back
+	err = storage.PutKYCHistory(stub, ref, kept)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("- purged " + strconv.Itoa(len(purged)) + " closed KYC records of " + ref)
+	return nil, emitEvent(stub, model.EventKYCPurged, ref, "")
+}
@@ -0,0 +1,143 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+func parseRiskCategory(arg string) (string, error) {
+	risk := strings.ToLower(strings.TrimSpace(arg))
+	if !model.IsRiskCategory(risk) {
+		return "", validation.Invalid("riskCategory", "expecting one of "+strings.Join(model.RiskCategories, ", "))
+	}
+	return risk, nil
+}
+
+// ============================================================================================================================
+// Set Risk - change a customer's risk category, the review date moves with it
+// ============================================================================================================================
+func SetRisk(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0            1
+	// "aadharNum", "medium"
+	risk, err := parseRiskCategory(args[1])
+	if err != nil {
+		return nil, err
+	}
+	rec, err := loadOwnKYCRecord(stub, args[0], "assess")
+	if err != nil {
+		return nil, err
+	}
+	if rec.Status == model.StatusRevoked {
+		return nil, model.Conflict("Cannot assess a KYC record that is revoked")
+	}
+
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	rec.RiskCategory = risk
+	rec.UpdatedAt = now
+	err = storage.PutKYCRecord(stub, *rec, writer(stub))
+	if err != nil {
+		return nil, err
+	}
+	return nil, emitStatusEvent(stub, model.EventKYCUpdated, rec)
+}
+
+// ============================================================================================================================
+// Renew - the verifying bank re-verified the customer, the record is verified again from now
+// ============================================================================================================================
+func Renew(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0              1
+	// "aadharNum", *"low"*		<- optional, the risk category found during re-verification
+	rec, err := loadOwnKYCRecord(stub, args[0], "renew")
+	if err != nil {
+		return nil, err
+	}
+	if len(argAt(args, 1)) > 0 {
+		rec.RiskCategory, err = parseRiskCategory(args[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = model.CheckTransition("renew", rec)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	rec.Status = model.KYCTransitions["renew"].To
+	rec.StatusReason = ""
+	rec.UpdatedAt = now
+	rec.VerifiedAt = now
+	err = storage.PutKYCRecord(stub, *rec, writer(stub))
+	if err != nil {
+		return nil, err
+	}
+	return nil, emitStatusEvent(stub, model.EventKYCUpdated, rec)
+}
+
+// ============================================================================================================================
+// Due For Review - an institution's records whose review falls before a date, earliest first
+// ============================================================================================================================
+func DueForReview(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//       0             1            2          3
+	// "SBIN0000001", "2026-04-01", *"20"*, *"bookmark"*
+	institution := model.BankCode(args[0])
+	date, _ := time.Parse(model.ReviewDateLayout, strings.TrimSpace(args[1])) //checked against the schema
+	before := date.UnixNano() / int64(time.Millisecond)
+	bookmark := argAt(args, 3)
+	pageSize, err := parsePageSize(argAt(args, 2))
+	if err != nil {
+		return nil, err
+	}
+
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	if caller.Role == access.RoleBank && caller.Institution != institution {
+		return nil, model.PermissionDenied("banks can only see reviews of the records they verified")
+	}
+
+	prefix := storage.IndexPrefix(storage.IndexReview, institution)
+	end := prefix + storage.TimeValue(before) //keys for exactly this time sort after it and are left out
+	page, err := storage.PageKYCIndex(stub, prefix, end, pageSize, bookmark, func(rec *model.KYCRecord) bool {
+		return rec.NextReviewDue < before && rec.Status != model.StatusPending && rec.Status != model.StatusRevoked
+	})
+	if err != nil {
+		return nil, err
+	}
+	jsonAsBytes, _ := json.Marshal(page)
+	return jsonAsBytes, nil
+}
@@ -0,0 +1,253 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+// ============================================================================================================================
+// Request KYC - the calling bank asks to reuse a customer's KYC verified by another bank
+// ============================================================================================================================
+func RequestKYC(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0            1                  2
+	// "aadharNum", "account_opening", "30"
+	fmt.Println("- start request kyc")
+
+	ref, err := storage.CustomerRef(stub, "aadharNum", args[0])
+	if err != nil {
+		return nil, err
+	}
+	purpose := strings.ToLower(strings.TrimSpace(args[1]))
+	if !model.IsConsentPurpose(purpose) {
+		return nil, validation.Invalid("purpose", "expecting one of "+strings.Join(model.ConsentPurposes, ", "))
+	}
+	days, err := strconv.Atoi(args[2])
+	if err != nil || days < 1 || days > model.MaxConsentDays {
+		return nil, validation.Invalid("days", "must be a whole number of days between 1 and "+strconv.Itoa(model.MaxConsentDays))
+	}
+
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := storage.GetKYCRecord(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, noKYCRecord()
+	}
+	if err = model.RequireStatus(rec, "share", model.StatusVerified); err != nil {
+		return nil, err
+	}
+	if rec.Institution == caller.Institution {
+		return nil, model.AlreadyExists(caller.Institution + " verified this customer and can already read the record")
+	}
+
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	requestIndex, err := storage.GetShareRequestIndex(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range requestIndex { //only one open request per bank and purpose
+		other, err := storage.GetShareRequest(stub, id)
+		if err != nil {
+			return nil, err
+		}
+		if other != nil && other.Requester == caller.Institution && other.Purpose == purpose && other.StatusAt(now) == model.SharePending {
+			return nil, model.AlreadyExists("Request "+other.ID+" for "+purpose+" is already pending").With("requestId", other.ID)
+		}
+	}
+
+	req := model.ShareRequest{ID: stub.GetTxID(), AadharRef: ref, Requester: caller.Institution, Owner: rec.Institution, Purpose: purpose, Days: days}
+	req.Status = model.SharePending
+	req.CreatedAt = now
+	req.ExpiresAt = now + int64(model.ShareRequestDays)*model.MsPerDay
+	err = storage.PutShareRequest(stub, req)
+	if err != nil {
+		return nil, err
+	}
+
+	requestIndex = append(requestIndex, req.ID)
+	err = storage.PutShareRequestIndex(stub, ref, requestIndex)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("- end request kyc " + req.ID)
+	return []byte(req.ID), nil
+}
+
+// ============================================================================================================================
+// Decide Share Request - load a pending request and check the caller may decide on it
+// ============================================================================================================================
+func decideShareRequest(stub shim.ChaincodeStubInterface, id string) (*model.ShareRequest, string, int64, error) {
+	req, err := storage.GetShareRequest(stub, id)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	if req == nil {
+		return nil, "", 0, model.NotFound("No share request " + id)
+	}
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	if status := req.StatusAt(now); status != model.SharePending {
+		return nil, "", 0, model.Conflict("Share request "+id+" is "+status).With("status", status)
+	}
+
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	rec, err := storage.GetKYCRecord(stub, req.AadharRef)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	decidedBy, err := access.ActForCustomer(caller, req.AadharRef, rec)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	return req, decidedBy, now, nil
+}
+
+// ============================================================================================================================
+// Approve Request - the customer or verifying bank agrees, the requester gets a consent to read the record, see
+// GrantConsent for when the details are sealed for it
+// ============================================================================================================================
+func ApproveRequest(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0
+	// "request id"
+
+	req, decidedBy, now, err := decideShareRequest(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	rec, err := storage.GetKYCRecord(stub, req.AadharRef)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, noKYCRecord()
+	}
+	if err = model.RequireStatus(rec, "share", model.StatusVerified); err != nil { //may have changed since the request was made
+		return nil, err
+	}
+	req.Status = model.ShareApproved
+	req.DecidedBy = decidedBy
+	req.DecidedAt = now
+	err = storage.PutShareRequest(stub, *req)
+	if err != nil {
+		return nil, err
+	}
+
+	consent := model.Consent{AadharRef: req.AadharRef, Bank: req.Requester, Purpose: req.Purpose, GrantedBy: decidedBy, GrantedAt: now}
+	consent.ExpiresAt = now + int64(req.Days)*model.MsPerDay
+	err = storage.PutConsent(stub, consent)
+	if err != nil {
+		return nil, err
+	}
+	if decidedBy == rec.Institution { //approved with the verifying bank's key, the requester gets the details now
+		_, err = storage.ShareCustomerPII(stub, rec, decidedBy)
+	}
+	return nil, err
+}
+
+// ============================================================================================================================
+// Reject Request - the customer or verifying bank declines
+// ============================================================================================================================
+func RejectRequest(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0              1
+	// "request id", *"reason"*
+
+	req, decidedBy, now, err := decideShareRequest(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	req.Status = model.ShareRejected
+	req.DecidedBy = decidedBy
+	req.DecidedAt = now
+	req.Reason = argAt(args, 1)
+	err = storage.PutShareRequest(stub, *req)
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// ============================================================================================================================
+// Read Share Requests - requests for a customer's KYC, banks only see the ones they made or have to decide on
+// ============================================================================================================================
+func ReadShareRequests(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//   0
+	// "aadharNum"
+
+	ref, err := storage.CustomerRef(stub, "aadharNum", args[0])
+	if err != nil {
+		return nil, err
+	}
+	caller, err := access.GetCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	if caller.Role == access.RoleCustomer && caller.CustomerRef != ref {
+		return nil, model.PermissionDenied("customers can only see requests for their own KYC")
+	}
+	now, err := storage.TxTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	requestIndex, err := storage.GetShareRequestIndex(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := []model.ShareRequest{}
+	for _, id := range requestIndex {
+		req, err := storage.GetShareRequest(stub, id)
+		if err != nil {
+			return nil, err
+		}
+		if req == nil {
+			continue
+		}
+		if caller.Role == access.RoleBank && caller.Institution != req.Requester && caller.Institution != req.Owner {
+			continue
+		}
+		req.Status = req.StatusAt(now)
+		requests = append(requests, *req)
+	}
+	jsonAsBytes, _ := json.Marshal(requests)
+	return jsonAsBytes, nil
+}
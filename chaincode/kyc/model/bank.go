@@ -0,0 +1,47 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"strings"
+)
+
+// bank statuses
+const (
+	BankActive   = "active"
+	BankInactive = "inactive"
+)
+
+type Bank struct {
+	Code        string `json:"code"` //IFSC or other registration code, used as the id
+	LegalName   string `json:"legalName"`
+	LicenceID   string `json:"licenceId"` //licence id issued by the regulator
+	Contact     string `json:"contact"`
+	Status      string `json:"status"`
+	OnboardedAt int64  `json:"onboardedAt"`        //utc timestamp in ms
+	ShareKey    []byte `json:"shareKey,omitempty"` //X25519 public key other banks seal customer details to, see setShareKey
+}
+
+// ============================================================================================================================
+// Bank Code - normalise a bank code, KYC records and caller attributes use the same form
+// ============================================================================================================================
+func BankCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
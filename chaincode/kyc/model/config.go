@@ -0,0 +1,51 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+type ChaincodeConfig struct {
+	AllowReset    bool `json:"allowReset"`              //off unless turned on at deploy, only meant for test networks
+	RetentionDays int  `json:"retentionDays,omitempty"` //how long closed KYC records are kept, 0 for DefaultRetentionDays
+}
+
+func (c ChaincodeConfig) Retention() int {
+	if c.RetentionDays > 0 {
+		return c.RetentionDays
+	}
+	return DefaultRetentionDays
+}
+
+type VersionInfo struct {
+	Version         string `json:"version"`
+	PreviousVersion string `json:"previousVersion,omitempty"`
+	InstalledAt     int64  `json:"installedAt"`             //utc timestamp in ms
+	SchemaVersion   int    `json:"schemaVersion,omitempty"` //KYC schema the indexes were last rebuilt for, 0 before it was recorded
+}
+
+type ResetEntry struct {
+	TxID            string `json:"txId"`
+	Role            string `json:"role"`
+	Institution     string `json:"institution,omitempty"`
+	Reason          string `json:"reason"`
+	Timestamp       int64  `json:"timestamp"`       //utc timestamp in ms
+	KYCRemoved      int    `json:"kycRemoved"`      //number of KYC records deleted
+	BanksRemoved    int    `json:"banksRemoved"`    //number of banks deleted
+	ConsentsRemoved int    `json:"consentsRemoved"` //number of consents deleted
+	RequestsRemoved int    `json:"requestsRemoved"` //number of share requests deleted
+}
@@ -0,0 +1,56 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+const MaxConsentDays = 3650 //longest a single consent can run for
+
+const MsPerDay = int64(24 * 60 * 60 * 1000)
+
+// purposes a customer can consent to
+const (
+	PurposeAccountOpening = "account_opening"
+	PurposeLoan           = "loan"
+	PurposeInsurance      = "insurance"
+	PurposeInvestment     = "investment"
+)
+
+var ConsentPurposes = []string{PurposeAccountOpening, PurposeLoan, PurposeInsurance, PurposeInvestment}
+
+type Consent struct {
+	AadharRef string `json:"aadharRef"`
+	Bank      string `json:"bank"` //bank code the customer's KYC is shared with
+	Purpose   string `json:"purpose"`
+	GrantedBy string `json:"grantedBy"` //"customer" or the code of the onboarding bank
+	GrantedAt int64  `json:"grantedAt"` //utc timestamp in ms
+	ExpiresAt int64  `json:"expiresAt"` //utc timestamp in ms
+	RevokedBy string `json:"revokedBy,omitempty"`
+	RevokedAt int64  `json:"revokedAt,omitempty"` //utc timestamp in ms, 0 while the consent stands
+}
+
+// ============================================================================================================================
+// Valid At - a consent is valid until it expires or is revoked
+// ============================================================================================================================
+func (c *Consent) ValidAt(now int64) bool {
+	return c.RevokedAt == 0 && now < c.ExpiresAt
+}
+
+func IsConsentPurpose(purpose string) bool {
+	return contains(ConsentPurposes, purpose)
+}
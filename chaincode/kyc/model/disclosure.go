@@ -0,0 +1,97 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"encoding/json"
+)
+
+// A consent is given for a purpose and each purpose has a disclosure profile, the fields of
+// the record a bank relying on that consent gets to see. disclose returns that projection
+// and names every field it left out and why.
+
+// fields of a KYC record a profile can list, name, dob, address, pan and documents are the customer's details from private.go
+var DisclosableFields = []string{"name", "dob", "address", "institution", "level", "status", "statusReason", "verifiedAt", "nextReviewDue", "riskCategory", "pan", "documents", "evidence", "offline"}
+
+var DisclosureProfiles = map[string][]string{
+	PurposeAccountOpening: {"name", "dob", "address", "institution", "level", "status", "verifiedAt", "pan", "documents", "evidence", "offline"},
+	PurposeLoan:           {"name", "address", "institution", "level", "status", "verifiedAt", "riskCategory", "pan", "documents"},
+	PurposeInsurance:      {"name", "dob", "institution", "level", "status", "verifiedAt"},
+	PurposeInvestment:     {"name", "dob", "address", "institution", "level", "status", "verifiedAt", "riskCategory", "pan"},
+}
+
+// why a field was left out of a disclosure
+const (
+	OmittedByProfile  = "not in the disclosure profile for this purpose"
+	OmittedEncrypted  = "encrypted, pass kycKey in the caller metadata"
+	OmittedNotShared  = "not sealed for this bank yet, the verifying bank runs shareDetails"
+	OmittedNotPresent = "not held on this record"
+)
+
+type Disclosure struct {
+	AadharRef string                     `json:"aadharRef"`
+	Purpose   string                     `json:"purpose"`
+	Bank      string                     `json:"bank"`      //who it was disclosed to
+	ExpiresAt int64                      `json:"expiresAt"` //utc timestamp in ms the consent runs until, 0 for the verifying bank
+	Fields    map[string]json.RawMessage `json:"fields"`
+	Omitted   map[string]string          `json:"omitted"` //field name to why it was left out
+}
+
+// ============================================================================================================================
+// Marshal JSON - Go 1.7, which the peers build chaincode with, only calls RawMessage's MarshalJSON through a pointer and
+// would write map values out as base64, so the fields go out as pointers
+// ============================================================================================================================
+func (d Disclosure) MarshalJSON() ([]byte, error) {
+	type disclosure Disclosure //same fields, without this method
+	out := struct {
+		disclosure
+		Fields map[string]*json.RawMessage `json:"fields"`
+	}{disclosure: disclosure(d)}
+	if d.Fields != nil {
+		out.Fields = map[string]*json.RawMessage{}
+		for field, value := range d.Fields {
+			value := value
+			out.Fields[field] = &value
+		}
+	}
+	return json.Marshal(out)
+}
+
+// ============================================================================================================================
+// Project KYC Record - the record's disclosable fields as name to JSON value, the customer's details flattened in
+// ============================================================================================================================
+func ProjectKYCRecord(rec *KYCRecord) map[string]json.RawMessage {
+	var all map[string]json.RawMessage
+	jsonAsBytes, _ := json.Marshal(rec)
+	json.Unmarshal(jsonAsBytes, &all)
+	for field, value := range SubjectFields(rec.Subject) {
+		if len(value) > 0 {
+			all[field], _ = json.Marshal(value)
+		}
+	}
+
+	fields := map[string]json.RawMessage{}
+	for _, field := range DisclosableFields {
+		if value, ok := all[field]; ok {
+			fields[field] = value
+		}
+	}
+	return fields
+}
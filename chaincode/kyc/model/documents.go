@@ -0,0 +1,63 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+// The documents a bank verified stay off chain, the record only anchors their SHA-256
+// digest with enough metadata to find and judge them. Anyone holding a copy can hash it
+// and ask verifyDocument whether it is the one that was anchored.
+
+// document types that can be anchored
+const (
+	DocAadhaarXML   = "aadhaar_xml" //offline aadhaar e-KYC XML
+	DocPANCard      = "pan_card"
+	DocPassport     = "passport"
+	DocAddressProof = "address_proof"
+)
+
+var EvidenceTypes = []string{DocAadhaarXML, DocPANCard, DocPassport, DocAddressProof}
+
+type DocumentEvidence struct {
+	Type       string `json:"type"` //one of EvidenceTypes
+	SHA256     string `json:"sha256"`
+	Issuer     string `json:"issuer"`
+	ExpiresAt  int64  `json:"expiresAt,omitempty"` //utc timestamp in ms, 0 if the document does not expire
+	URI        string `json:"uri,omitempty"`       //where the document is kept off chain
+	AnchoredAt int64  `json:"anchoredAt"`          //utc timestamp in ms
+	AnchoredBy string `json:"anchoredBy"`
+}
+
+type DocumentMatch struct { //what verifyDocument tells about an anchored document, the storage URI is left out
+	Type       string `json:"type"`
+	Issuer     string `json:"issuer"`
+	ExpiresAt  int64  `json:"expiresAt,omitempty"`
+	Expired    bool   `json:"expired"`
+	AnchoredAt int64  `json:"anchoredAt"`
+	AnchoredBy string `json:"anchoredBy"`
+	Status     string `json:"status"` //status of the KYC record it is anchored to
+}
+
+type DocumentVerification struct {
+	Anchored bool            `json:"anchored"`
+	Matches  []DocumentMatch `json:"matches"`
+}
+
+func IsEvidenceType(docType string) bool {
+	return contains(EvidenceTypes, docType)
+}
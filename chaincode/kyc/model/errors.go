@@ -0,0 +1,126 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// Every error a chaincode function returns is an Error, so clients can act on the code
+// rather than parse the message. Errors that come from somewhere else, the stub or the
+// json package, are reported as INTERNAL.
+
+// error codes
+const (
+	CodeInvalidArgument    = "INVALID_ARGUMENT"    //an argument is missing, malformed or out of range, field names it
+	CodeNotFound           = "NOT_FOUND"           //no record, bank, consent or request with that id
+	CodeAlreadyExists      = "ALREADY_EXISTS"      //creating something that is already there
+	CodePermissionDenied   = "PERMISSION_DENIED"   //the caller's role or institution does not allow it
+	CodeConflict           = "CONFLICT"            //the record's current state does not allow it, e.g. an illegal status transition
+	CodeFailedPrecondition = "FAILED_PRECONDITION" //the network is not set up for it yet, e.g. no aadhaar secret or UIDAI certificate
+	CodeInternal           = "INTERNAL"            //the ledger could not be read or holds something malformed
+)
+
+type Error struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Field   string            `json:"field,omitempty"`   //argument that failed, for INVALID_ARGUMENT
+	Details map[string]string `json:"details,omitempty"` //anything else a client can act on, never PII
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ============================================================================================================================
+// With - add a detail to the error
+// ============================================================================================================================
+func (e *Error) With(key string, value string) *Error {
+	if e.Details == nil {
+		e.Details = map[string]string{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+// ============================================================================================================================
+// JSON - the error as clients receive it, {"code": "NOT_FOUND", "message": "..."}
+// ============================================================================================================================
+func (e *Error) JSON() string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false) //messages quote XML element names
+	encoder.Encode(e)
+	return string(bytes.TrimSpace(buf.Bytes()))
+}
+
+func NewError(code string, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func InvalidArgument(field string, message string) *Error {
+	return &Error{Code: CodeInvalidArgument, Message: message, Field: field}
+}
+
+func NotFound(message string) *Error {
+	return NewError(CodeNotFound, message)
+}
+
+func AlreadyExists(message string) *Error {
+	return NewError(CodeAlreadyExists, message)
+}
+
+func PermissionDenied(message string) *Error {
+	return NewError(CodePermissionDenied, "Permission denied: "+message)
+}
+
+func Conflict(message string) *Error {
+	return NewError(CodeConflict, message)
+}
+
+func FailedPrecondition(message string) *Error {
+	return NewError(CodeFailedPrecondition, message)
+}
+
+func Internal(message string) *Error {
+	return NewError(CodeInternal, message)
+}
+
+// ============================================================================================================================
+// As Error - err as an Error, errors that are not one already are INTERNAL
+// ============================================================================================================================
+func AsError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return Internal(err.Error())
+}
+
+// ============================================================================================================================
+// Response - err serialised as JSON for the shim to hand back to the client, nil stays nil
+// ============================================================================================================================
+func Response(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errors.New(AsError(err).JSON())
+}
@@ -0,0 +1,49 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+// A transaction carries at most one chaincode event, so each invoke function emits a single
+// event describing its outcome. Subscribers filter on the event name.
+
+const EventPayloadVersion = 1 //bump when KYCEvent changes in a way subscribers would notice
+
+// event names
+const (
+	EventKYCCreated       = "KYCCreated"
+	EventKYCUpdated       = "KYCUpdated"
+	EventKYCRevoked       = "KYCRevoked"
+	EventKYCStatusChanged = "KYCStatusChanged"
+	EventKYCClosed        = "KYCClosed"
+	EventKYCPurged        = "KYCPurged"
+	EventBankRegistered   = "BankRegistered"
+	EventBankUpdated      = "BankUpdated"
+	EventBankDeactivated  = "BankDeactivated"
+)
+
+type KYCEvent struct {
+	Type      string `json:"type"`
+	Version   int    `json:"version"`
+	TxID      string `json:"txId"`
+	Timestamp int64  `json:"timestamp"` //utc timestamp in ms
+	Actor     string `json:"actor"`
+	AadharRef string `json:"aadharRef,omitempty"` //set on KYC events
+	Bank      string `json:"bank,omitempty"`      //verifying institution on KYC events, the bank itself on bank events
+	Status    string `json:"status,omitempty"`    //status the record moved to, set on lifecycle events
+}
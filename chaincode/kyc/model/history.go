@@ -0,0 +1,73 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// history actions
+const (
+	HistoryCreated = "created"
+	HistoryUpdated = "updated"
+	HistoryClosed  = "closed"
+)
+
+type KYCVersion struct {
+	TxID      string     `json:"txId"`
+	Timestamp int64      `json:"timestamp"` //utc timestamp in ms
+	Actor     string     `json:"actor"`     //bank code, or the role of a non bank caller
+	Action    string     `json:"action"`
+	Changes   []string   `json:"changes"` //names of the fields that changed, values are left out
+	Record    *KYCRecord `json:"record"`  //state after this version, nil once closed
+}
+
+// ============================================================================================================================
+// Diff KYC Records - top level fields that differ between two versions of a record
+// ============================================================================================================================
+func DiffKYCRecords(prev *KYCRecord, next *KYCRecord) []string {
+	var before, after map[string]interface{}
+	if prev != nil {
+		prevAsBytes, _ := json.Marshal(prev)
+		json.Unmarshal(prevAsBytes, &before)
+	}
+	if next != nil {
+		nextAsBytes, _ := json.Marshal(next)
+		json.Unmarshal(nextAsBytes, &after)
+	}
+
+	changes := []string{}
+	seen := map[string]bool{}
+	for _, fields := range []map[string]interface{}{before, after} {
+		for field := range fields {
+			if seen[field] || field == "updatedAt" {
+				continue
+			}
+			seen[field] = true
+			if !reflect.DeepEqual(before[field], after[field]) {
+				changes = append(changes, field)
+			}
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
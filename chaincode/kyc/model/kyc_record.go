@@ -0,0 +1,392 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package model holds the KYC ledger's types and the rules that need nothing but the values
+// themselves: record validation and decoding, the lifecycle state machine, review dates and
+// disclosure profiles. It does not touch the stub, so services off the chain can use it too.
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SchemaVersion = 6 //bump when the stored shape of KYCRecord changes
+
+// verification levels a member bank can attest to
+const (
+	LevelMinimum = "minimum" //small account, self declaration only
+	LevelOTP     = "otp"     //aadhaar OTP based e-KYC
+	LevelFull    = "full"    //biometric or in-person verification
+	LevelOffline = "offline" //UIDAI signed offline paperless e-KYC XML
+)
+
+var VerificationLevels = []string{LevelMinimum, LevelOTP, LevelFull, LevelOffline}
+
+// record statuses, see lifecycle.go for the transitions between them
+const (
+	StatusPending   = "pending" //submitted by a bank, not verified yet
+	StatusVerified  = "verified"
+	StatusSuspended = "suspended" //on hold, can be reinstated
+	StatusExpired   = "expired"   //due for re-verification, can be submitted again
+	StatusRevoked   = "revoked"   //final
+)
+
+var KYCStatuses = []string{StatusPending, StatusVerified, StatusSuspended, StatusExpired, StatusRevoked}
+
+// customer risk categories, they set how often the customer is re-verified, see review.go
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
+
+var RiskCategories = []string{RiskLow, RiskMedium, RiskHigh}
+
+type SubjectIDs struct { //identifiers of the customer the record is about
+	AadharRef string `json:"aadharRef"`         //reference token, see storage.AadhaarRef
+	PAN       string `json:"pan,omitempty"`     //never stored on the record, see private.go
+	Name      string `json:"name,omitempty"`    //nor are the name,
+	DOB       string `json:"dob,omitempty"`     //date of birth, yyyy-mm-dd,
+	Address   string `json:"address,omitempty"` //and address
+}
+
+type DocumentRef struct { //a document the institution looked at while verifying
+	Type string `json:"type"`
+	Ref  string `json:"ref"`
+}
+
+type KYCRecord struct {
+	SchemaVersion int                `json:"schemaVersion"`
+	Subject       SubjectIDs         `json:"subject"`
+	Institution   string             `json:"institution"`            //bank that verified the customer
+	Level         string             `json:"level"`                  //one of VerificationLevels
+	Status        string             `json:"status"`                 //one of KYCStatuses
+	StatusReason  string             `json:"statusReason,omitempty"` //why the record was last suspended, reinstated or revoked
+	Documents     []DocumentRef      `json:"documents,omitempty"`    //never stored on the record either
+	PII           *PIIDigest         `json:"pii,omitempty"`          //hash of the details kept apart
+	Evidence      []DocumentEvidence `json:"evidence"`               //digests of the documents themselves, see documents.go
+	Offline       *OfflineKYC        `json:"offline,omitempty"`      //set when onboarded from an offline e-KYC file
+	CreatedAt     int64              `json:"createdAt"`              //utc timestamp in ms
+	UpdatedAt     int64              `json:"updatedAt"`              //utc timestamp in ms
+	VerifiedAt    int64              `json:"verifiedAt"`             //utc timestamp in ms of the last verification
+	RiskCategory  string             `json:"riskCategory"`           //one of RiskCategories
+	NextReviewDue int64              `json:"nextReviewDue"`          //utc timestamp in ms, worked out from verifiedAt and riskCategory, 0 until verified
+}
+
+// ============================================================================================================================
+// New KYC Record - build a record at the current schema version
+// ============================================================================================================================
+func NewKYCRecord(aadharRef string, institution string, level string, now int64) KYCRecord {
+	rec := KYCRecord{}
+	rec.SchemaVersion = SchemaVersion
+	rec.Subject.AadharRef = aadharRef
+	rec.Institution = institution
+	rec.Level = level
+	rec.Status = StatusVerified
+	rec.Documents = []DocumentRef{}
+	rec.Evidence = []DocumentEvidence{}
+	rec.CreatedAt = now
+	rec.UpdatedAt = now
+	rec.VerifiedAt = now
+	rec.RiskCategory = RiskHigh //shortest review cycle until the bank assesses the customer
+	return rec
+}
+
+// ============================================================================================================================
+// Validate - make sure a record is complete before it is stored or handed back to a caller
+// ============================================================================================================================
+func (rec *KYCRecord) Validate() error {
+	if rec.SchemaVersion != SchemaVersion {
+		return InvalidArgument("schemaVersion", "Unsupported KYC schema version "+strconv.Itoa(rec.SchemaVersion))
+	}
+	if len(rec.Subject.AadharRef) == 0 {
+		return InvalidArgument("subject.aadharRef", "KYC record is missing subject.aadharRef")
+	}
+	if len(rec.Institution) == 0 {
+		return InvalidArgument("institution", "KYC record is missing institution")
+	}
+	if !IsVerificationLevel(rec.Level) {
+		return InvalidArgument("level", "Unknown verification level \""+rec.Level+"\", expecting one of "+strings.Join(VerificationLevels, ", "))
+	}
+	if !IsRiskCategory(rec.RiskCategory) {
+		return InvalidArgument("riskCategory", "Unknown risk category \""+rec.RiskCategory+"\", expecting one of "+strings.Join(RiskCategories, ", "))
+	}
+	if !IsKYCStatus(rec.Status) {
+		return InvalidArgument("status", "Unknown KYC status \""+rec.Status+"\", expecting one of "+strings.Join(KYCStatuses, ", "))
+	}
+	for i, doc := range rec.Documents {
+		if len(doc.Type) == 0 || len(doc.Ref) == 0 {
+			return InvalidArgument("documents", "KYC record document "+strconv.Itoa(i)+" needs both a type and a ref")
+		}
+	}
+	return nil
+}
+
+// ============================================================================================================================
+// Has Inline PII - true while the record carries the customer's details itself, see private.go
+// ============================================================================================================================
+func (rec *KYCRecord) HasInlinePII() bool {
+	return len(rec.Subject.PAN) > 0 || len(rec.Subject.Name) > 0 || len(rec.Subject.DOB) > 0 || len(rec.Subject.Address) > 0 || len(rec.Documents) > 0
+}
+
+// ============================================================================================================================
+// Subject Fields - the customer's details on a record by their disclosable field name, empty ones included
+// ============================================================================================================================
+func SubjectFields(subject SubjectIDs) map[string]string {
+	return map[string]string{"name": subject.Name, "dob": subject.DOB, "address": subject.Address, "pan": subject.PAN}
+}
+
+// ============================================================================================================================
+// Drop Inline PII - take the customer's details off the record, only the reference token stays
+// ============================================================================================================================
+func (rec *KYCRecord) DropInlinePII() {
+	rec.Subject = SubjectIDs{AadharRef: rec.Subject.AadharRef}
+	rec.Documents = []DocumentRef{}
+}
+
+func IsVerificationLevel(level string) bool {
+	return contains(VerificationLevels, level)
+}
+
+func IsRiskCategory(risk string) bool {
+	return contains(RiskCategories, risk)
+}
+
+func IsKYCStatus(status string) bool {
+	return contains(KYCStatuses, status)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================================================================================================
+// Decode KYC Record - strict JSON decoding, unknown fields and trailing data are rejected
+// ============================================================================================================================
+func DecodeKYCRecord(data []byte) (KYCRecord, error) {
+	var rec KYCRecord
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&rec); err != nil {
+		return rec, Internal("Malformed KYC record: " + err.Error())
+	}
+	if dec.More() {
+		return rec, Internal("Malformed KYC record: unexpected data after JSON object")
+	}
+	if field := unknownField(data, reflect.TypeOf(rec)); len(field) > 0 {
+		return rec, Internal("Malformed KYC record: unknown field \"" + field + "\"")
+	}
+	if rec.SchemaVersion == 2 { //version 2 records had no status, they were all verified when last written
+		rec.SchemaVersion = 3
+		rec.Status = StatusVerified
+		rec.VerifiedAt = rec.UpdatedAt
+	}
+	if rec.SchemaVersion == 3 { //version 3 records had no risk category, treat them as high risk until assessed
+		rec.SchemaVersion = 4
+		rec.RiskCategory = RiskHigh
+		rec.NextReviewDue = ReviewDue(rec.VerifiedAt, rec.RiskCategory)
+	}
+	if rec.SchemaVersion == 4 { //version 4 records had no document evidence
+		rec.SchemaVersion = 5
+		rec.Evidence = []DocumentEvidence{}
+	}
+	if rec.SchemaVersion == 5 { //version 5 records carried the PAN and documents, storage.PutKYCRecord moves them out
+		rec.SchemaVersion = 6
+	}
+	if err := rec.Validate(); err != nil {
+		return rec, err
+	}
+	return rec, nil
+}
+
+// ============================================================================================================================
+// Unknown Field - the first field in the JSON that t has no place for, nested objects included, matched without regard to
+// case like encoding/json does. The peers build chaincode with Go 1.7, which has no Decoder.DisallowUnknownFields.
+// ============================================================================================================================
+func unknownField(data []byte, t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		var items []json.RawMessage
+		if json.Unmarshal(data, &items) != nil {
+			return ""
+		}
+		for _, item := range items {
+			if field := unknownField(item, t.Elem()); len(field) > 0 {
+				return field
+			}
+		}
+	case reflect.Map:
+		var values map[string]json.RawMessage
+		if json.Unmarshal(data, &values) != nil {
+			return ""
+		}
+		for _, name := range sortedNames(values) {
+			if field := unknownField(values[name], t.Elem()); len(field) > 0 {
+				return field
+			}
+		}
+	case reflect.Struct:
+		var values map[string]json.RawMessage
+		if json.Unmarshal(data, &values) != nil {
+			return ""
+		}
+		for _, name := range sortedNames(values) { //the same field on every peer
+			field, ok := jsonField(t, name)
+			if !ok {
+				return name
+			}
+			if nested := unknownField(values[name], field.Type); len(nested) > 0 {
+				return nested
+			}
+		}
+	}
+	return ""
+}
+
+func sortedNames(values map[string]json.RawMessage) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "-" || len(field.PkgPath) > 0 { //unexported
+			continue
+		}
+		if len(tag) == 0 {
+			tag = field.Name
+		}
+		if strings.EqualFold(tag, name) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+type legacyKYCRecord struct { //schema version 1, keyed and indexed by the plain aadhar number
+	SchemaVersion int `json:"schemaVersion"`
+	Subject       struct {
+		AadharNum string `json:"aadharNum"`
+		PAN       string `json:"pan,omitempty"`
+	} `json:"subject"`
+	Institution string        `json:"institution"`
+	Level       string        `json:"level"`
+	Documents   []DocumentRef `json:"documents"`
+	CreatedAt   int64         `json:"createdAt"`
+	UpdatedAt   int64         `json:"updatedAt"`
+}
+
+type legacyEkyc struct { //what init_marble built by hand and set_user marshalled back, timestamp is a string in the first
+	AadharNum string          `json:"aadharNum"`
+	Timestamp json.RawMessage `json:"timestamp"`
+	Size      int             `json:"size"`
+	User      string          `json:"user"`
+}
+
+// ============================================================================================================================
+// Decode Legacy KYC Record - decode a value stored under a plain aadhar number before records were versioned, returns
+// the record with no reference token yet and the aadhar number it was about. The shapes are the version 1 record, the
+// JSON init_marble built, the Ekyc set_user marshalled back and Write's "<bank>;<RFC850 date>" string. Only version 1
+// said how the customer was verified, the others become minimum level records verified when written, or at now if
+// that is unknown.
+// ============================================================================================================================
+func DecodeLegacyKYCRecord(key string, data []byte, now int64) (KYCRecord, string, error) {
+	var v1 legacyKYCRecord
+	if json.Unmarshal(data, &v1) == nil && v1.SchemaVersion == 1 {
+		rec := NewKYCRecord("", v1.Institution, v1.Level, v1.CreatedAt)
+		rec.Subject.PAN = v1.Subject.PAN
+		if v1.Documents != nil {
+			rec.Documents = v1.Documents
+		}
+		rec.UpdatedAt = v1.UpdatedAt
+		rec.VerifiedAt = v1.UpdatedAt
+		return rec, legacyAadharNum(v1.Subject.AadharNum, key), nil
+	}
+
+	var ekyc legacyEkyc
+	if err := json.Unmarshal(data, &ekyc); err == nil && len(ekyc.User) > 0 {
+		verifiedAt := legacyTimestamp(ekyc.Timestamp, now)
+		return NewKYCRecord("", BankCode(ekyc.User), LevelMinimum, verifiedAt), legacyAadharNum(ekyc.AadharNum, key), nil
+	}
+
+	value := string(data)
+	if sep := strings.LastIndex(value, ";"); sep > 0 && !strings.HasPrefix(strings.TrimSpace(value), "{") {
+		verifiedAt := now
+		if written, err := time.Parse(time.RFC850, value[sep+1:]); err == nil {
+			verifiedAt = written.UnixNano() / int64(time.Millisecond)
+		}
+		return NewKYCRecord("", BankCode(value[:sep]), LevelMinimum, verifiedAt), key, nil
+	}
+	return KYCRecord{}, "", Internal("Value stored under " + key + " is not a legacy KYC record")
+}
+
+func legacyAadharNum(aadharNum string, key string) string { //set_user on a Write value marshalled an empty number
+	if len(strings.TrimSpace(aadharNum)) == 0 {
+		return key
+	}
+	return strings.TrimSpace(aadharNum)
+}
+
+func legacyTimestamp(raw json.RawMessage, now int64) int64 { //a number, or whatever string init_marble was passed
+	var ms int64
+	if json.Unmarshal(raw, &ms) == nil && ms > 0 {
+		return ms
+	}
+	var str string
+	if json.Unmarshal(raw, &str) == nil {
+		if ms, err := strconv.ParseInt(strings.TrimSpace(str), 10, 64); err == nil && ms > 0 {
+			return ms
+		}
+	}
+	return now
+}
+
+// ============================================================================================================================
+// Parse Document Refs - turn "type:ref" arguments into document references
+// ============================================================================================================================
+func ParseDocumentRefs(args []string) ([]DocumentRef, error) {
+	docs := []DocumentRef{}
+	for _, arg := range args {
+		parts := strings.SplitN(arg, ":", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return nil, InvalidArgument("documents", "Document reference \""+arg+"\" must look like type:ref")
+		}
+		docs = append(docs, DocumentRef{Type: parts[0], Ref: parts[1]})
+	}
+	return docs, nil
+}
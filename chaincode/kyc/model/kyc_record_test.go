@@ -0,0 +1,108 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeKYCRecord(t *testing.T) {
+	cases := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"version 2 upgraded", `{"schemaVersion":2,"subject":{"aadharRef":"r"},"institution":"SBIN0000001","level":"full","createdAt":1,"updatedAt":5}`, ""},
+		{"unknown field", `{"schemaVersion":6,"subject":{"aadharRef":"r"},"institution":"SBIN0000001","level":"full","status":"verified","riskCategory":"high","owner":"x"}`, "unknown field"},
+		{"unknown nested field", `{"schemaVersion":6,"subject":{"aadharRef":"r","aadharNum":"234123412346"},"institution":"SBIN0000001","level":"full","status":"verified","riskCategory":"high"}`, "unknown field \"aadharNum\""},
+		{"trailing data", `{"schemaVersion":2,"subject":{"aadharRef":"r"},"institution":"SBIN0000001","level":"full"}{}`, "unexpected data after JSON object"},
+		{"future version", `{"schemaVersion":99,"subject":{"aadharRef":"r"},"institution":"SBIN0000001","level":"full"}`, "Unsupported KYC schema version 99"},
+		{"unknown level", `{"schemaVersion":2,"subject":{"aadharRef":"r"},"institution":"SBIN0000001","level":"gold"}`, "Unknown verification level"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rec, err := DecodeKYCRecord([]byte(c.json))
+			expectError(t, err, c.want)
+			if c.want != "" {
+				return
+			}
+			if rec.SchemaVersion != SchemaVersion || rec.Status != StatusVerified || rec.VerifiedAt != 5 || rec.RiskCategory != RiskHigh {
+				t.Fatalf("record was not upgraded: %+v", rec)
+			}
+		})
+	}
+}
+
+func TestDecodeLegacyKYCRecord(t *testing.T) {
+	const now = 1000
+	cases := []struct {
+		name       string
+		value      string
+		aadharNum  string
+		bank       string
+		level      string
+		verifiedAt int64
+	}{
+		{"version 1", `{"schemaVersion":1,"subject":{"aadharNum":"234567890124","pan":"ABCPE1234F"},"institution":"SBIN0000001","level":"full","documents":null,"createdAt":1,"updatedAt":5}`, "234567890124", "SBIN0000001", LevelFull, 5},
+		{"init_marble", `{"aadharNum": "234567890124", "timestamp": "1467000000000", "size": 35, "user": "sbin0000001"}`, "234567890124", "SBIN0000001", LevelMinimum, 1467000000000},
+		{"init_marble text timestamp", `{"aadharNum": "234567890124", "timestamp": "blue", "size": 35, "user": "sbin0000001"}`, "234567890124", "SBIN0000001", LevelMinimum, now},
+		{"set_user", `{"aadharNum":"","timestamp":0,"size":0,"user":"HDFC0000001"}`, "key", "HDFC0000001", LevelMinimum, now},
+		{"write", "sbin0000001;Monday, 27-Jun-16 04:00:00 UTC", "key", "SBIN0000001", LevelMinimum, 1467000000000},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rec, aadharNum, err := DecodeLegacyKYCRecord("key", []byte(c.value), now)
+			expectError(t, err, "")
+			if aadharNum != c.aadharNum || rec.Institution != c.bank || rec.Level != c.level || rec.VerifiedAt != c.verifiedAt {
+				t.Fatalf("unexpected record %+v for %s", rec, aadharNum)
+			}
+			rec.Subject.AadharRef = "r"
+			expectError(t, rec.Validate(), "")
+		})
+	}
+	_, _, err := DecodeLegacyKYCRecord("key", []byte(`{"owner":"x"}`), now)
+	expectError(t, err, "is not a legacy KYC record")
+}
+
+func TestParseDocumentRefs(t *testing.T) {
+	docs, err := ParseDocumentRefs([]string{"passport:K1234567", "ration_card:a:b"})
+	if err != nil || len(docs) != 2 || docs[1].Type != "ration_card" || docs[1].Ref != "a:b" {
+		t.Fatalf("unexpected documents %+v, %v", docs, err)
+	}
+	for _, arg := range []string{"passport", "passport:", ":K1234567"} {
+		if _, err := ParseDocumentRefs([]string{arg}); err == nil || !strings.Contains(err.Error(), "must look like type:ref") {
+			t.Errorf("%q: expected a type:ref error, got %v", arg, err)
+		}
+	}
+}
+
+func expectError(t *testing.T, err error, want string) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected an error containing %q, got %v", want, err)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A bank submits a record as pending and verifies it once it has checked the documents.
+// Verified records can be suspended and reinstated, they expire when due for
+// re-verification and an expired record can be submitted again. Revoked is final.
+// Which roles may call each function is in access.InvokePolicy, the state rules are here.
+//
+//   submit -> pending -verify-> verified -suspend-> suspended -reinstate-> verified
+//   verified, suspended -expire-> expired -submit-> pending
+//   verified, expired -renew-> verified, see review.go
+//   anything but revoked -revoke-> revoked
+
+type KYCTransition struct {
+	From           []string //statuses the transition starts from
+	To             string
+	ReasonRequired bool
+}
+
+var KYCTransitions = map[string]KYCTransition{
+	"verify":    {From: []string{StatusPending}, To: StatusVerified},
+	"suspend":   {From: []string{StatusVerified}, To: StatusSuspended, ReasonRequired: true},
+	"reinstate": {From: []string{StatusSuspended}, To: StatusVerified},
+	"revoke":    {From: []string{StatusPending, StatusVerified, StatusSuspended, StatusExpired}, To: StatusRevoked, ReasonRequired: true},
+	"expire":    {From: []string{StatusVerified, StatusSuspended}, To: StatusExpired},
+	"renew":     {From: []string{StatusVerified, StatusExpired}, To: StatusVerified},
+}
+
+// ============================================================================================================================
+// Check Transition - a descriptive error if a record cannot take this transition from its current status
+// ============================================================================================================================
+func CheckTransition(action string, rec *KYCRecord) error {
+	tr, ok := KYCTransitions[action]
+	if !ok {
+		return Internal("Unknown KYC transition " + action)
+	}
+	for _, from := range tr.From {
+		if rec.Status == from {
+			return nil
+		}
+	}
+	if rec.Status == tr.To {
+		return Conflict("KYC record is already "+rec.Status).With("status", rec.Status)
+	}
+	return Conflict(fmt.Sprintf("Illegal transition: cannot %s a KYC record that is %s, %s only applies to %s records", action, rec.Status, action, strings.Join(tr.From, " or "))).
+		With("status", rec.Status).With("expecting", strings.Join(tr.From, ","))
+}
+
+// ============================================================================================================================
+// Require Status - a descriptive error unless the record is in one of the given statuses
+// ============================================================================================================================
+func RequireStatus(rec *KYCRecord, action string, statuses ...string) error {
+	for _, status := range statuses {
+		if rec.Status == status {
+			return nil
+		}
+	}
+	return Conflict(fmt.Sprintf("Cannot %s a KYC record that is %s, expecting %s", action, rec.Status, strings.Join(statuses, " or "))).
+		With("status", rec.Status).With("expecting", strings.Join(statuses, ","))
+}
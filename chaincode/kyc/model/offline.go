@@ -0,0 +1,27 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+type OfflineKYC struct { //what a record keeps from UIDAI's offline paperless e-KYC file, the name, date of birth and address go with the customer's details and the photo is left out
+	ReferenceID string `json:"referenceId"`          //last 4 digits of the aadhar number followed by when the file was generated
+	GeneratedAt int64  `json:"generatedAt"`          //utc timestamp in ms, read from the reference id
+	EmailHash   string `json:"emailHash,omitempty"`  //UIDAI's hash of the email, salted with the share code
+	MobileHash  string `json:"mobileHash,omitempty"` //same for the mobile number
+}
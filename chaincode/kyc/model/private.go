@@ -0,0 +1,53 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+// The customer's details, name, date of birth, address, PAN and the numbers of the documents
+// the bank looked at, are kept
+// apart from the KYC record. The record only carries a salted SHA-256 of them with
+// the names of the fields they hold, see storage/private.go for where they go.
+
+const PIICollection = "kycPII"
+
+type CustomerPII struct {
+	Salt      string        `json:"salt"` //keeps the hash from being matched against guessed details
+	PAN       string        `json:"pan,omitempty"`
+	Name      string        `json:"name,omitempty"`
+	DOB       string        `json:"dob,omitempty"` //yyyy-mm-dd
+	Address   string        `json:"address,omitempty"`
+	Documents []DocumentRef `json:"documents"`
+}
+
+type PIIDigest struct { //what the shared ledger knows about a customer's details
+	Collection string   `json:"collection"`
+	SHA256     string   `json:"sha256"`              //of the stored details, salt included
+	Fields     []string `json:"fields"`              //which details are held
+	Encrypted  bool     `json:"encrypted,omitempty"` //under the writing bank's key, see EncryptedPII
+	UpdatedAt  int64    `json:"updatedAt"`
+}
+
+type EncryptedPII struct {
+	KeyID       string `json:"keyId"`       //see storage.KeyID
+	Institution string `json:"institution"` //bank whose key it is
+	StateKey    string `json:"stateKey"`    //key of the record it was sealed for, the additional data and part of the nonce
+	Nonce       []byte `json:"nonce"`
+	Ciphertext  []byte `json:"ciphertext"`          //of the CustomerPII JSON
+	Ephemeral   []byte `json:"ephemeral,omitempty"` //X25519 public key when sealed to the bank's share key, KeyID is then of the share key
+}
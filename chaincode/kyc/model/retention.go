@@ -0,0 +1,35 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+// Closing a record moves it out of the live records, so read, list and every invoke function
+// stop seeing it and the customer can be onboarded again. Auditors can read it until it is
+// purged, which is only allowed once the retention period in force when it was closed has passed.
+
+const DefaultRetentionDays = 5 * 365 //PMLA asks for records to be kept 5 years after the relationship ends
+
+type ClosedKYCRecord struct {
+	Record     KYCRecord `json:"record"` //the record as it was when closed
+	TxID       string    `json:"txId"`   //transaction that closed it
+	Reason     string    `json:"reason"`
+	ClosedBy   string    `json:"closedBy"`
+	ClosedAt   int64     `json:"closedAt"`   //utc timestamp in ms
+	PurgeAfter int64     `json:"purgeAfter"` //utc timestamp in ms, purge is refused before this
+}
@@ -0,0 +1,47 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"time"
+)
+
+// RBI asks for periodic re-KYC, every 2 years for high risk customers, 8 for medium and
+// 10 for low. storage.PutKYCRecord works out nextReviewDue from the last verification, so it
+// moves whenever a record is verified again or its risk category changes.
+
+var ReviewYears = map[string]int{
+	RiskHigh:   2,
+	RiskMedium: 8,
+	RiskLow:    10,
+}
+
+const ReviewDateLayout = "2006-01-02"
+
+// ============================================================================================================================
+// Review Due - when a customer verified at verifiedAt has to be verified again, 0 if never verified
+// ============================================================================================================================
+func ReviewDue(verifiedAt int64, risk string) int64 {
+	if verifiedAt == 0 {
+		return 0
+	}
+	verified := time.Unix(0, verifiedAt*int64(time.Millisecond)).UTC()
+	return verified.AddDate(ReviewYears[risk], 0, 0).UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,58 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+// A bank asks to reuse a KYC another bank already did. The customer or the verifying bank
+// approves or rejects, an approval is stored as a consent for the requesting bank.
+
+const ShareRequestDays = 7 //a pending request expires after this many days
+
+// request states
+const (
+	SharePending  = "pending"
+	ShareApproved = "approved"
+	ShareRejected = "rejected"
+	ShareExpired  = "expired"
+)
+
+type ShareRequest struct {
+	ID        string `json:"id"` //transaction id of the request
+	AadharRef string `json:"aadharRef"`
+	Requester string `json:"requester"` //bank code asking for the KYC
+	Owner     string `json:"owner"`     //bank code that verified the customer
+	Purpose   string `json:"purpose"`
+	Days      int    `json:"days"` //how long the consent runs if approved
+	Status    string `json:"status"`
+	CreatedAt int64  `json:"createdAt"` //utc timestamp in ms
+	ExpiresAt int64  `json:"expiresAt"` //utc timestamp in ms, pending requests expire after this
+	DecidedBy string `json:"decidedBy,omitempty"`
+	DecidedAt int64  `json:"decidedAt,omitempty"` //utc timestamp in ms
+	Reason    string `json:"reason,omitempty"`    //why it was rejected
+}
+
+// ============================================================================================================================
+// Status At - pending requests past their expiry report as expired
+// ============================================================================================================================
+func (r *ShareRequest) StatusAt(now int64) string {
+	if r.Status == SharePending && now >= r.ExpiresAt {
+		return ShareExpired
+	}
+	return r.Status
+}
@@ -0,0 +1,208 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+)
+
+const ConfigKey = "_config"     //name for the key/value that will store deploy time settings
+const VersionKey = "_version"   //name for the key/value that will store the installed chaincode version
+const ResetLogKey = "_resetlog" //name for the key/value that will store the audit trail of resets
+
+// ============================================================================================================================
+// Get Config - read deploy time settings, defaults apply when none were stored
+// ============================================================================================================================
+func GetConfig(stub shim.ChaincodeStubInterface) (model.ChaincodeConfig, error) {
+	var config model.ChaincodeConfig
+	configAsBytes, err := stub.GetState(ConfigKey)
+	if err != nil {
+		return config, model.Internal("Failed to get chaincode config")
+	}
+	if configAsBytes != nil {
+		err = json.Unmarshal(configAsBytes, &config)
+		if err != nil {
+			return config, model.Internal("Malformed chaincode config")
+		}
+	}
+	return config, nil
+}
+
+// ============================================================================================================================
+// Config Stored - true once Init has stored settings, false only on the first deploy
+// ============================================================================================================================
+func ConfigStored(stub shim.ChaincodeStubInterface) (bool, error) {
+	configAsBytes, err := stub.GetState(ConfigKey)
+	if err != nil {
+		return false, model.Internal("Failed to get chaincode config")
+	}
+	return configAsBytes != nil, nil
+}
+
+func PutConfig(stub shim.ChaincodeStubInterface, config model.ChaincodeConfig) error {
+	jsonAsBytes, _ := json.Marshal(config)
+	return stub.PutState(ConfigKey, jsonAsBytes)
+}
+
+// ============================================================================================================================
+// Get Version Info - which chaincode version and KYC schema the ledger was last initialised with, zero values before any
+// ============================================================================================================================
+func GetVersionInfo(stub shim.ChaincodeStubInterface) (model.VersionInfo, error) {
+	var info model.VersionInfo
+	infoAsBytes, err := stub.GetState(VersionKey)
+	if err != nil {
+		return info, model.Internal("Failed to get chaincode version")
+	}
+	if infoAsBytes != nil {
+		json.Unmarshal(infoAsBytes, &info)
+	}
+	return info, nil
+}
+
+// ============================================================================================================================
+// Record Version - note which chaincode version and KYC schema initialised the ledger, keeps the version it replaced
+// ============================================================================================================================
+func RecordVersion(stub shim.ChaincodeStubInterface, version string) error {
+	info, err := GetVersionInfo(stub)
+	if err != nil {
+		return err
+	}
+	if info.Version == version && info.SchemaVersion == model.SchemaVersion {
+		return nil //already recorded
+	}
+	if info.Version != version {
+		info.PreviousVersion = info.Version
+		info.Version = version
+	}
+	info.SchemaVersion = model.SchemaVersion
+	info.InstalledAt, err = TxTimestamp(stub)
+	if err != nil {
+		return err
+	}
+	fmt.Println("- chaincode version " + info.Version + ", was " + info.PreviousVersion)
+	jsonAsBytes, _ := json.Marshal(info)
+	return stub.PutState(VersionKey, jsonAsBytes)
+}
+
+// ============================================================================================================================
+// Clear KYC Records - delete every live record, returns how many, closed records, histories, indexes, details and
+// offline e-KYC anchors go too
+// ============================================================================================================================
+func ClearKYCRecords(stub shim.ChaincodeStubInterface) (int, error) {
+	kycKeys, err := RangeKeys(stub, KYCKeyPrefix)
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range kycKeys {
+		err = stub.DelState(key)
+		if err != nil {
+			return 0, model.Internal("Failed to delete KYC record " + strings.TrimPrefix(key, KYCKeyPrefix))
+		}
+	}
+	_, err = clearPrefixes(stub, KYCHistoryPrefix, KYCIndexPrefix, ClosedKYCPrefix, KYCPIIPrefix, SharedPIIPrefix, OfflineKYCPrefix)
+	if err != nil {
+		return 0, err
+	}
+	return len(kycKeys), nil
+}
+
+// ============================================================================================================================
+// Clear Consents - delete every consent and the per customer consent lists, returns how many consents
+// ============================================================================================================================
+func ClearConsents(stub shim.ChaincodeStubInterface) (int, error) {
+	removed, err := clearPrefixes(stub, consentKeyPrefix)
+	if err != nil {
+		return 0, err
+	}
+	_, err = clearPrefixes(stub, consentIndexPrefix)
+	return removed, err
+}
+
+// ============================================================================================================================
+// Clear Share Requests - delete every share request and the per customer request lists, returns how many requests
+// ============================================================================================================================
+func ClearShareRequests(stub shim.ChaincodeStubInterface) (int, error) {
+	removed, err := clearPrefixes(stub, shareRequestKeyPrefix)
+	if err != nil {
+		return 0, err
+	}
+	_, err = clearPrefixes(stub, shareRequestIndexPrefix)
+	return removed, err
+}
+
+// ============================================================================================================================
+// Clear Prefixes - delete every key under the prefixes, returns how many
+// ============================================================================================================================
+func clearPrefixes(stub shim.ChaincodeStubInterface, prefixes ...string) (int, error) {
+	removed := 0
+	for _, prefix := range prefixes {
+		keys, err := RangeKeys(stub, prefix)
+		if err != nil {
+			return 0, err
+		}
+		for _, key := range keys {
+			err = stub.DelState(key)
+			if err != nil {
+				return 0, model.Internal("Failed to clear " + prefix)
+			}
+		}
+		removed += len(keys)
+	}
+	return removed, nil
+}
+
+// ============================================================================================================================
+// Clear Banks - delete every bank and empty the bank index, returns how many
+// ============================================================================================================================
+func ClearBanks(stub shim.ChaincodeStubInterface) (int, error) {
+	bankIndex, err := GetBankIndex(stub)
+	if err != nil {
+		return 0, err
+	}
+	for _, code := range bankIndex {
+		err = stub.DelState(BankKeyPrefix + code)
+		if err != nil {
+			return 0, model.Internal("Failed to delete bank " + code)
+		}
+	}
+	var empty []string
+	return len(bankIndex), PutBankIndex(stub, empty)
+}
+
+// ============================================================================================================================
+// Append Reset Log - add an entry to the audit trail of resets
+// ============================================================================================================================
+func AppendResetLog(stub shim.ChaincodeStubInterface, entry model.ResetEntry) error {
+	var resetLog []model.ResetEntry
+	logAsBytes, err := stub.GetState(ResetLogKey)
+	if err != nil {
+		return model.Internal("Failed to get reset log")
+	}
+	json.Unmarshal(logAsBytes, &resetLog)
+	resetLog = append(resetLog, entry)
+	jsonAsBytes, _ := json.Marshal(resetLog)
+	return stub.PutState(ResetLogKey, jsonAsBytes)
+}
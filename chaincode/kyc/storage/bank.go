@@ -0,0 +1,154 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+const BankKeyPrefix = "bank_"   //banks are stored under bank_<code> so they never collide with aadhar numbers
+const BankIndexKey = "_allBank" //name for the key/value that will store a list of all added banks
+
+// ============================================================================================================================
+// Get Bank - read a bank from the registry, returns nil if the code is not registered
+// ============================================================================================================================
+func GetBank(stub shim.ChaincodeStubInterface, code string) (*model.Bank, error) {
+	bankAsBytes, err := stub.GetState(BankKeyPrefix + code)
+	if err != nil {
+		return nil, model.Internal("Failed to get bank " + code)
+	}
+	if bankAsBytes == nil {
+		return nil, nil
+	}
+	bank := model.Bank{}
+	err = json.Unmarshal(bankAsBytes, &bank)
+	if err != nil {
+		return nil, model.Internal("Malformed bank record for " + code)
+	}
+	return &bank, nil
+}
+
+func PutBank(stub shim.ChaincodeStubInterface, bank model.Bank) error {
+	jsonAsBytes, _ := json.Marshal(bank)
+	return stub.PutState(BankKeyPrefix+bank.Code, jsonAsBytes)
+}
+
+// ============================================================================================================================
+// Get Bank Index - list of registered bank codes
+// ============================================================================================================================
+func GetBankIndex(stub shim.ChaincodeStubInterface) ([]string, error) {
+	indexAsBytes, err := stub.GetState(BankIndexKey)
+	if err != nil {
+		return nil, model.Internal("Failed to get list of registered banks")
+	}
+	var bankIndex []string
+	if len(indexAsBytes) > 0 {
+		err = json.Unmarshal(indexAsBytes, &bankIndex) //un stringify it aka JSON.parse()
+		if err != nil {
+			return nil, model.Internal("Malformed list of registered banks")
+		}
+	}
+	return bankIndex, nil
+}
+
+func PutBankIndex(stub shim.ChaincodeStubInterface, bankIndex []string) error {
+	jsonAsBytes, _ := json.Marshal(bankIndex)
+	return stub.PutState(BankIndexKey, jsonAsBytes)
+}
+
+// ============================================================================================================================
+// Migrate Legacy Banks - rebuild a bank index the old WriteBank turned into "<JSON array>;<name>;<name>..." and move the
+// banks it stored under their bare names as "<legal name>;<licence id>;<contact>" to bank_<code>, run by Init. A bank
+// whose name is not a bank code is left where it is, the regulator registers it again with writeBank.
+// ============================================================================================================================
+func MigrateLegacyBanks(stub shim.ChaincodeStubInterface) error {
+	indexAsBytes, err := stub.GetState(BankIndexKey)
+	if err != nil {
+		return model.Internal("Failed to get list of registered banks")
+	}
+	var bankIndex []string
+	if len(indexAsBytes) == 0 || json.Unmarshal(indexAsBytes, &bankIndex) == nil {
+		return nil //not there yet, or well formed
+	}
+	names := strings.Split(string(indexAsBytes), ";")
+	json.Unmarshal([]byte(names[0]), &bankIndex) //what Init seeded, [] or null
+	now, err := TxTimestamp(stub)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names[1:] {
+		code := model.BankCode(name)
+		if validation.BankCode("code", code) != nil {
+			fmt.Println("- bank \"" + name + "\" has no bank code for a name, left for the regulator to register again")
+			continue
+		}
+		existing, err := GetBank(stub, code)
+		if err != nil {
+			return err
+		}
+		legacyAsBytes, err := stub.GetState(name)
+		if err != nil {
+			return model.Internal("Failed to get bank " + name)
+		}
+		if existing == nil && legacyAsBytes == nil {
+			continue //listed, but never stored
+		}
+		if existing == nil {
+			fields := append(strings.SplitN(string(legacyAsBytes), ";", 3), "", "")
+			bank := model.Bank{Code: code, Status: model.BankActive, OnboardedAt: now}
+			bank.LegalName = strings.TrimSpace(fields[0])
+			bank.LicenceID = strings.TrimSpace(fields[1])
+			bank.Contact = strings.TrimSpace(fields[2])
+			if err = PutBank(stub, bank); err != nil {
+				return err
+			}
+		}
+		if err = stub.DelState(name); err != nil {
+			return model.Internal("Failed to delete bank " + name)
+		}
+		bankIndex = append(bankIndex, code)
+	}
+
+	keys, err := RangeKeys(stub, BankKeyPrefix) //and every bank already under its code
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		bankIndex = append(bankIndex, strings.TrimPrefix(key, BankKeyPrefix))
+	}
+	listed := map[string]bool{}
+	rebuilt := []string{}
+	for _, code := range bankIndex {
+		if !listed[code] {
+			listed[code] = true
+			rebuilt = append(rebuilt, code)
+		}
+	}
+	fmt.Println("- rebuilt the list of registered banks")
+	return PutBankIndex(stub, rebuilt)
+}
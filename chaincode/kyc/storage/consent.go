@@ -0,0 +1,109 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+)
+
+const consentKeyPrefix = "consent_"        //consents are stored under consent_<reference token>_<bank code>_<purpose>
+const consentIndexPrefix = "consentindex_" //consentindex_<reference token> lists the consent keys of one customer
+
+func ConsentKey(ref string, bank string, purpose string) string {
+	return consentKeyPrefix + ref + "_" + bank + "_" + purpose
+}
+
+func GetConsent(stub shim.ChaincodeStubInterface, key string) (*model.Consent, error) {
+	consentAsBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, model.Internal("Failed to get consent " + key)
+	}
+	if consentAsBytes == nil {
+		return nil, nil
+	}
+	consent := model.Consent{}
+	err = json.Unmarshal(consentAsBytes, &consent)
+	if err != nil {
+		return nil, model.Internal("Malformed consent " + key)
+	}
+	return &consent, nil
+}
+
+// ============================================================================================================================
+// Put Consent - store a consent, replacing any earlier one for the same bank and purpose
+// ============================================================================================================================
+func PutConsent(stub shim.ChaincodeStubInterface, consent model.Consent) error {
+	key := ConsentKey(consent.AadharRef, consent.Bank, consent.Purpose)
+	existing, err := GetConsent(stub, key)
+	if err != nil {
+		return err
+	}
+	jsonAsBytes, _ := json.Marshal(consent)
+	err = stub.PutState(key, jsonAsBytes)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+
+	consentIndex, err := getConsentIndex(stub, consent.AadharRef) //first consent for this bank and purpose, add it to the customer's list
+	if err != nil {
+		return err
+	}
+	consentIndex = append(consentIndex, key)
+	jsonAsBytes, _ = json.Marshal(consentIndex)
+	return stub.PutState(consentIndexPrefix+consent.AadharRef, jsonAsBytes)
+}
+
+func getConsentIndex(stub shim.ChaincodeStubInterface, ref string) ([]string, error) {
+	var consentIndex []string
+	indexAsBytes, err := stub.GetState(consentIndexPrefix + ref)
+	if err != nil {
+		return nil, model.Internal("Failed to get consent index")
+	}
+	json.Unmarshal(indexAsBytes, &consentIndex)
+	return consentIndex, nil
+}
+
+// ============================================================================================================================
+// Get Consents - every consent a customer has given, revoked and expired ones included
+// ============================================================================================================================
+func GetConsents(stub shim.ChaincodeStubInterface, ref string) ([]model.Consent, error) {
+	consentIndex, err := getConsentIndex(stub, ref)
+	if err != nil {
+		return nil, err
+	}
+	consents := []model.Consent{}
+	for _, key := range consentIndex {
+		consent, err := GetConsent(stub, key)
+		if err != nil {
+			return nil, err
+		}
+		if consent != nil {
+			consents = append(consents, *consent)
+		}
+	}
+	return consents, nil
+}
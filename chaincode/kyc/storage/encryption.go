@@ -0,0 +1,342 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/x25519"
+)
+
+// The details in the private store would otherwise sit in the clear on every peer, so the
+// bank writing them has to pass its own key and they are encrypted with AES-256-GCM under it.
+// The key never goes into the arguments: the v0.6 shim has no transient map, so it comes in
+// the caller metadata as {"kycKey": "<base64 of 32 bytes>"}. Metadata is not part of the
+// arguments but it is kept with the transaction: anyone who can read the blocks of an invoke
+// that passed a key can read the key, and with it every record sealed under it. Run with
+// confidentiality on so transactions are encrypted, otherwise the encryption only keeps the
+// details out of world state. Queries are not recorded, reads may pass the key freely. The
+// stored value only names the key by its id, read decrypts when the caller passes the same
+// key and otherwise leaves the details out. rotateKey moves a bank's details to a new key, or with only the new key encrypts the
+// ones Init left in the clear.
+//
+// A bank cannot seal for another bank's AES key, so each key also stands for an X25519 key pair
+// whose public half the bank registers with setShareKey. Details are sealed to it for a bank
+// holding a consent, see shared.go, and for the bank a record is handed to, which open them
+// with their own kycKey as usual.
+
+// names in the caller metadata
+const (
+	TransientKey    = "kycKey"    //key the details are encrypted or decrypted with
+	TransientNewKey = "kycNewKey" //key rotateKey re-encrypts them with
+)
+
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ============================================================================================================================
+// Get Transient - the caller metadata as name to value, values are base64 encoded
+// ============================================================================================================================
+func getTransient(stub shim.ChaincodeStubInterface) (map[string][]byte, error) {
+	transient := map[string][]byte{}
+	metadata, err := stub.GetCallerMetadata()
+	if err != nil {
+		return nil, model.Internal("Failed to get caller metadata")
+	}
+	if len(metadata) == 0 {
+		return transient, nil
+	}
+	var encoded map[string]string
+	if err = json.Unmarshal(metadata, &encoded); err != nil {
+		return nil, validation.Invalid("caller metadata", "must be a JSON object of base64 values")
+	}
+	for name, value := range encoded {
+		transient[name], err = base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, validation.Invalid("caller metadata "+name, "must be base64 encoded")
+		}
+	}
+	return transient, nil
+}
+
+// ============================================================================================================================
+// Transient AES Key - a 256 bit key from the caller metadata, nil if the caller did not pass one
+// ============================================================================================================================
+func TransientAESKey(stub shim.ChaincodeStubInterface, name string) ([]byte, error) {
+	transient, err := getTransient(stub)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := transient[name]
+	if !ok {
+		return nil, nil
+	}
+	if len(key) != 32 {
+		return nil, validation.Invalid(name, "must be a 32 byte AES-256 key")
+	}
+	return key, nil
+}
+
+// ============================================================================================================================
+// Seal PII - encrypt a customer's details for the record under a state key. Every endorser has to come up with the same
+// nonce so it is derived from the transaction and the state key, a transaction seals each state key at most once. The
+// state key is the additional data, the details cannot be passed off as another record's.
+// ============================================================================================================================
+func sealPII(stub shim.ChaincodeStubInterface, key []byte, institution string, stateKey string, plaintext []byte) (model.EncryptedPII, error) {
+	sealed := model.EncryptedPII{KeyID: KeyID(key), Institution: institution, StateKey: stateKey}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return sealed, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return sealed, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("nonce|" + stub.GetTxID() + "|" + stateKey))
+	sealed.Nonce = mac.Sum(nil)[:gcm.NonceSize()]
+	sealed.Ciphertext = gcm.Seal(nil, sealed.Nonce, plaintext, []byte(stateKey))
+	return sealed, nil
+}
+
+// ============================================================================================================================
+// Share Key - the X25519 key pair a bank's AES key stands for, its public half is what setShareKey registers
+// ============================================================================================================================
+func ShareKey(key []byte) (*x25519.PrivateKey, error) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("share"))
+	return x25519.NewPrivateKey(mac.Sum(nil))
+}
+
+func shareAESKey(secret []byte) []byte { //AES key of the details sealed with an X25519 shared secret
+	sum := sha256.Sum256(append([]byte("kycshare|"), secret...))
+	return sum[:]
+}
+
+// ============================================================================================================================
+// Seal PII For - encrypt a customer's details to another bank's share key. The ephemeral key is derived from the sealing
+// bank's own key, the transaction and the state key, like the nonce, so every endorser comes up with the same one.
+// ============================================================================================================================
+func sealPIIFor(stub shim.ChaincodeStubInterface, sealerKey []byte, code string, stateKey string, plaintext []byte) (model.EncryptedPII, error) {
+	bank, err := GetBank(stub, code)
+	if err != nil {
+		return model.EncryptedPII{}, err
+	}
+	if bank == nil || len(bank.ShareKey) == 0 {
+		return model.EncryptedPII{}, model.FailedPrecondition(code + " has not registered a share key, it runs setShareKey first")
+	}
+	peer, err := x25519.NewPublicKey(bank.ShareKey)
+	if err != nil {
+		return model.EncryptedPII{}, model.Internal("Malformed share key of " + code)
+	}
+	mac := hmac.New(sha256.New, sealerKey)
+	mac.Write([]byte("ephemeral|" + stub.GetTxID() + "|" + stateKey + "|" + code))
+	ephemeral, err := x25519.NewPrivateKey(mac.Sum(nil))
+	if err != nil {
+		return model.EncryptedPII{}, err
+	}
+	secret, err := ephemeral.ECDH(peer)
+	if err != nil {
+		return model.EncryptedPII{}, model.Internal("Failed to seal customer details for " + code)
+	}
+	sealed, err := sealPII(stub, shareAESKey(secret), code, stateKey, plaintext)
+	if err != nil {
+		return sealed, err
+	}
+	sealed.KeyID = KeyID(bank.ShareKey)
+	sealed.Ephemeral = ephemeral.PublicKey().Bytes()
+	return sealed, nil
+}
+
+// ============================================================================================================================
+// Open PII - decrypt the customer's details of the record under a state key, the key has to be the one they were sealed
+// with. Details sealed for a live record stay valid once it is closed, closing moves them without the bank's key.
+// ============================================================================================================================
+func OpenPII(sealed model.EncryptedPII, key []byte, stateKey string) ([]byte, error) {
+	keyID := KeyID(key)
+	if len(sealed.Ephemeral) > 0 { //sealed to the share key, the AES key comes from the X25519 shared secret
+		private, err := ShareKey(key)
+		if err != nil {
+			return nil, err
+		}
+		keyID = KeyID(private.PublicKey().Bytes())
+		if keyID == sealed.KeyID {
+			peer, err := x25519.NewPublicKey(sealed.Ephemeral)
+			if err != nil {
+				return nil, model.Internal("Malformed customer details")
+			}
+			secret, err := private.ECDH(peer)
+			if err != nil {
+				return nil, model.Internal("Malformed customer details")
+			}
+			key = shareAESKey(secret)
+		}
+	}
+	if keyID != sealed.KeyID {
+		return nil, model.InvalidArgument(TransientKey, "Key "+keyID+" is not the key "+sealed.KeyID+" the customer details are encrypted with")
+	}
+	ref := piiRef(PIIKey(stateKey))
+	aad := sealed.StateKey
+	switch {
+	case sealed.StateKey == "":
+		aad = ref //sealed before the state key was bound, rotateKey binds it
+	case sealed.StateKey != stateKey && !(sealed.StateKey == KYCKey(ref) && strings.HasPrefix(stateKey, ClosedKYCPrefix)):
+		return nil, model.Internal("Customer details were sealed for another record")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, sealed.Nonce, sealed.Ciphertext, []byte(aad))
+	if err != nil {
+		return nil, model.Internal("Customer details failed to decrypt, they were changed or moved")
+	}
+	return plaintext, nil
+}
+
+// ============================================================================================================================
+// Rotate PII Key - re-encrypt every customer's details a bank encrypted with one key under a new one, returns how many.
+// Details handed over to the bank, sealed to the share key of the old key, are sealed under the new key like the rest.
+// ============================================================================================================================
+func RotatePIIKey(stub shim.ChaincodeStubInterface, institution string, oldKey []byte, newKey []byte) (int, error) {
+	keys, err := RangeKeys(stub, KYCPIIPrefix)
+	if err != nil {
+		return 0, err
+	}
+	share, err := ShareKey(oldKey)
+	if err != nil {
+		return 0, err
+	}
+	shareID := KeyID(share.PublicKey().Bytes())
+	rotated := 0
+	for _, key := range keys {
+		piiAsBytes, err := stub.GetState(key)
+		if err != nil {
+			return 0, model.Internal("Failed to get customer details")
+		}
+		var sealed model.EncryptedPII
+		if json.Unmarshal(piiAsBytes, &sealed) != nil || (sealed.KeyID != KeyID(oldKey) && sealed.KeyID != shareID) || sealed.Institution != institution {
+			continue //in the clear, or someone else's
+		}
+		stateKey := strings.TrimPrefix(key, KYCPIIPrefix)
+		plaintext, err := OpenPII(sealed, oldKey, stateKey)
+		if err != nil {
+			return 0, err
+		}
+		resealed, err := sealPII(stub, newKey, institution, stateKey, plaintext)
+		if err != nil {
+			return 0, err
+		}
+		jsonAsBytes, _ := json.Marshal(resealed)
+		if err = stub.PutState(key, jsonAsBytes); err != nil {
+			return 0, err
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+// ============================================================================================================================
+// Seal Clear PII - encrypt the details of a bank's customers that are still in the clear, returns how many
+// ============================================================================================================================
+func SealClearPII(stub shim.ChaincodeStubInterface, institution string, key []byte) (int, error) {
+	keys, err := RangeKeys(stub, KYCPIIPrefix)
+	if err != nil {
+		return 0, err
+	}
+	sealedCount := 0
+	for _, piiKey := range keys {
+		stateKey := strings.TrimPrefix(piiKey, KYCPIIPrefix)
+		rec, save, err := piiOwner(stub, stateKey)
+		if err != nil {
+			return 0, err
+		}
+		if rec == nil || rec.PII == nil || rec.PII.Encrypted || rec.Institution != institution {
+			continue //already encrypted, or someone else's
+		}
+		plaintext, err := stub.GetState(piiKey)
+		if err != nil {
+			return 0, model.Internal("Failed to get customer details")
+		}
+		sealed, err := sealPII(stub, key, institution, stateKey, plaintext)
+		if err != nil {
+			return 0, err
+		}
+		jsonAsBytes, _ := json.Marshal(sealed)
+		if err = stub.PutState(piiKey, jsonAsBytes); err != nil {
+			return 0, err
+		}
+		rec.PII.Encrypted = true
+		if err = save(); err != nil {
+			return 0, err
+		}
+		sealedCount++
+	}
+	return sealedCount, nil
+}
+
+// ============================================================================================================================
+// PII Owner - the live or closed record the details under a state key belong to, and how to store it back as it is
+// ============================================================================================================================
+func piiOwner(stub shim.ChaincodeStubInterface, stateKey string) (*model.KYCRecord, func() error, error) {
+	if !strings.HasPrefix(stateKey, ClosedKYCPrefix) {
+		rec, err := GetKYCRecord(stub, strings.TrimPrefix(stateKey, KYCKeyPrefix))
+		if err != nil || rec == nil {
+			return nil, nil, err
+		}
+		return rec, func() error {
+			jsonAsBytes, _ := json.Marshal(rec)
+			return stub.PutState(stateKey, jsonAsBytes)
+		}, nil
+	}
+	closedAsBytes, err := stub.GetState(stateKey)
+	if err != nil {
+		return nil, nil, model.Internal("Failed to get closed KYC record")
+	}
+	if closedAsBytes == nil {
+		return nil, nil, nil
+	}
+	var closed model.ClosedKYCRecord
+	if err = json.Unmarshal(closedAsBytes, &closed); err != nil {
+		return nil, nil, model.Internal("Malformed closed KYC record")
+	}
+	closed.Record.Institution = model.BankCode(closed.Record.Institution) //closed before bank codes were normalised
+	return &closed.Record, func() error {
+		jsonAsBytes, _ := json.Marshal(closed)
+		return stub.PutState(stateKey, jsonAsBytes)
+	}, nil
+}
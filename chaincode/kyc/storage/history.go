@@ -0,0 +1,88 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+)
+
+// The v0.6 shim cannot read a key's history, so every write of a KYC record also appends
+// a version to kychistory_<reference token>. Records are only ever written through
+// PutKYCRecord and DeleteKYCRecord, which keeps the two in step. Purging closed records
+// trims the versions that led up to them.
+
+const KYCHistoryPrefix = "kychistory_"
+
+// ============================================================================================================================
+// Append KYC History - record who changed a record, when and which fields
+// ============================================================================================================================
+func appendKYCHistory(stub shim.ChaincodeStubInterface, ref string, prev *model.KYCRecord, next *model.KYCRecord, actor string) error {
+	version := model.KYCVersion{TxID: stub.GetTxID(), Actor: actor, Record: next}
+	version.Action = model.HistoryUpdated
+	if prev == nil {
+		version.Action = model.HistoryCreated
+	} else if next == nil {
+		version.Action = model.HistoryClosed
+	}
+	version.Changes = model.DiffKYCRecords(prev, next)
+
+	var err error
+	version.Timestamp, err = TxTimestamp(stub)
+	if err != nil {
+		return err
+	}
+
+	history, err := GetKYCHistory(stub, ref)
+	if err != nil {
+		return err
+	}
+	history = append(history, version)
+	return PutKYCHistory(stub, ref, history)
+}
+
+func GetKYCHistory(stub shim.ChaincodeStubInterface, ref string) ([]model.KYCVersion, error) {
+	historyAsBytes, err := stub.GetState(KYCHistoryPrefix + ref)
+	if err != nil {
+		return nil, model.Internal("Failed to get KYC history")
+	}
+	history := []model.KYCVersion{}
+	if historyAsBytes != nil {
+		err = json.Unmarshal(historyAsBytes, &history)
+		if err != nil {
+			return nil, model.Internal("Malformed KYC history")
+		}
+	}
+	return history, nil
+}
+
+// ============================================================================================================================
+// Put KYC History - replace a customer's versions, an empty history is removed
+// ============================================================================================================================
+func PutKYCHistory(stub shim.ChaincodeStubInterface, ref string, history []model.KYCVersion) error {
+	if len(history) == 0 {
+		return stub.DelState(KYCHistoryPrefix + ref)
+	}
+	jsonAsBytes, _ := json.Marshal(history)
+	return stub.PutState(KYCHistoryPrefix+ref, jsonAsBytes)
+}
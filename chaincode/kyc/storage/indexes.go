@@ -0,0 +1,316 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+// Every KYC record has one small index key per listing it appears in, laid out like a
+// composite key: kycidx_<index> 0x00 <value> 0x00 <reference token>. Creating a record
+// only writes its own keys, so concurrent onboarding no longer fights over one shared
+// array, and a range scan over an index prefix walks the records in key order.
+
+const KYCIndexPrefix = "kycidx_"
+
+const indexSep = "\x00"
+const indexEnd = "\U0010FFFF" //sorts after anything that can follow a prefix
+
+// indexes kept for every KYC record
+const (
+	IndexInstitution = "institution"
+	IndexStatus      = "status"
+	IndexVerified    = "verified" //by verification date
+	IndexReview      = "review"   //by institution, then review due date
+	IndexDocument    = "document" //by SHA-256 of an attached document
+)
+
+type ListFilter struct { //which records list returns, zero values match anything
+	Institution  string
+	Status       string
+	Level        string
+	VerifiedFrom int64 //utc timestamp in ms, inclusive
+	VerifiedTo   int64 //utc timestamp in ms, inclusive, 0 for no upper bound
+}
+
+func IndexPrefix(index string, value string) string {
+	return KYCIndexPrefix + index + indexSep + value + indexSep
+}
+
+func TimeValue(ms int64) string {
+	return fmt.Sprintf("%013d", ms) //zero padded so the keys sort by time
+}
+
+// ============================================================================================================================
+// KYC Index Keys - the index keys a record should have
+// ============================================================================================================================
+func KYCIndexKeys(rec *model.KYCRecord) []string {
+	if rec == nil {
+		return nil
+	}
+	ref := rec.Subject.AadharRef
+	keys := []string{
+		IndexPrefix(IndexInstitution, rec.Institution) + ref,
+		IndexPrefix(IndexStatus, rec.Status) + ref,
+		IndexPrefix(IndexVerified, TimeValue(rec.VerifiedAt)) + ref,
+	}
+	if rec.NextReviewDue > 0 {
+		keys = append(keys, IndexPrefix(IndexReview, rec.Institution+indexSep+TimeValue(rec.NextReviewDue))+ref)
+	}
+	for _, doc := range rec.Evidence {
+		keys = append(keys, IndexPrefix(IndexDocument, doc.SHA256)+ref)
+	}
+	return keys
+}
+
+// ============================================================================================================================
+// Update KYC Indexes - move a record's index keys from its previous version to the next, nil for none
+// ============================================================================================================================
+func updateKYCIndexes(stub shim.ChaincodeStubInterface, prev *model.KYCRecord, next *model.KYCRecord) error {
+	keep := map[string]bool{}
+	for _, key := range KYCIndexKeys(next) {
+		keep[key] = true
+	}
+	for _, key := range KYCIndexKeys(prev) {
+		if keep[key] {
+			delete(keep, key) //already there
+			continue
+		}
+		if err := stub.DelState(key); err != nil {
+			return model.Internal("Failed to update KYC index")
+		}
+	}
+	for key := range keep {
+		if err := stub.PutState(key, []byte{0x00}); err != nil {
+			return model.Internal("Failed to update KYC index")
+		}
+	}
+	return nil
+}
+
+// ============================================================================================================================
+// Range Keys - every key starting with a prefix, collected before the caller changes any of them
+// ============================================================================================================================
+func RangeKeys(stub shim.ChaincodeStubInterface, prefix string) ([]string, error) {
+	iter, err := stub.RangeQueryState(prefix, prefix+indexEnd)
+	if err != nil {
+		return nil, model.Internal("Failed to scan " + prefix)
+	}
+	defer iter.Close()
+
+	keys := []string{}
+	for iter.HasNext() {
+		key, _, err := iter.Next()
+		if err != nil {
+			return nil, model.Internal("Failed to scan " + prefix)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// ============================================================================================================================
+// Reindex KYC Records - give every record the index keys of this version and an upper case bank code, and retire the
+// old _marbleindex array, run by Init. Walking every record is only done when the ledger was last initialised with an
+// older KYC schema, see RecordVersion, so upgrades that keep the schema do not touch the records.
+// ============================================================================================================================
+func ReindexKYCRecords(stub shim.ChaincodeStubInterface) error {
+	info, err := GetVersionInfo(stub)
+	if err != nil {
+		return err
+	}
+	if info.SchemaVersion < model.SchemaVersion {
+		if err = reindexAll(stub); err != nil {
+			return err
+		}
+	}
+
+	indexAsBytes, err := stub.GetState(LegacyIndexKey)
+	if err != nil {
+		return model.Internal("Failed to get KYC index")
+	}
+	if indexAsBytes == nil {
+		return nil //already retired
+	}
+	var kycIndex []string
+	json.Unmarshal(indexAsBytes, &kycIndex)
+
+	var pending []string //legacy records still waiting for the aadhaar secret
+	for _, entry := range kycIndex {
+		legacyAsBytes, err := stub.GetState(entry)
+		if err != nil {
+			return model.Internal("Failed to get legacy KYC record")
+		}
+		if legacyAsBytes != nil {
+			pending = append(pending, entry)
+		}
+	}
+	if len(pending) > 0 {
+		jsonAsBytes, _ := json.Marshal(pending)
+		return stub.PutState(LegacyIndexKey, jsonAsBytes)
+	}
+	fmt.Println("- KYC index migrated, removing " + LegacyIndexKey)
+	return stub.DelState(LegacyIndexKey)
+}
+
+func reindexAll(stub shim.ChaincodeStubInterface) error { //every record's index keys and bank code
+	kycKeys, err := RangeKeys(stub, KYCKeyPrefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range kycKeys {
+		rec, err := GetKYCRecord(stub, strings.TrimPrefix(key, KYCKeyPrefix))
+		if err != nil {
+			return err
+		}
+		if rec == nil {
+			continue //nothing stored under the key any more
+		}
+		if err = normaliseInstitution(stub, key, rec); err != nil {
+			return err
+		}
+		if err = updateKYCIndexes(stub, nil, rec); err != nil {
+			return err
+		}
+	}
+	fmt.Println("- reindexed " + strconv.Itoa(len(kycKeys)) + " KYC records")
+	return nil
+}
+
+// ============================================================================================================================
+// Normalise Institution - upper case the bank code of a record written before codes were normalised, otherwise
+// the bank's own calls would not match it, and move its index keys along
+// ============================================================================================================================
+func normaliseInstitution(stub shim.ChaincodeStubInterface, key string, rec *model.KYCRecord) error {
+	institution := model.BankCode(rec.Institution)
+	if institution == rec.Institution {
+		return nil
+	}
+	prev := *rec
+	rec.Institution = institution
+	if err := updateKYCIndexes(stub, &prev, rec); err != nil {
+		return err
+	}
+	fmt.Println("- normalised the institution of " + rec.Subject.AadharRef)
+	jsonAsBytes, _ := json.Marshal(rec)
+	return stub.PutState(key, jsonAsBytes)
+}
+
+// ============================================================================================================================
+// Matches - true if a record passes every part of the filter
+// ============================================================================================================================
+func (f ListFilter) Matches(rec *model.KYCRecord) bool {
+	if f.Institution != "" && rec.Institution != f.Institution {
+		return false
+	}
+	if f.Status != "" && rec.Status != f.Status {
+		return false
+	}
+	if f.Level != "" && rec.Level != f.Level {
+		return false
+	}
+	if rec.VerifiedAt < f.VerifiedFrom {
+		return false
+	}
+	return f.VerifiedTo == 0 || rec.VerifiedAt <= f.VerifiedTo
+}
+
+// ============================================================================================================================
+// Scan Range - the index range to walk for a filter, the most selective index that applies
+// ============================================================================================================================
+func (f ListFilter) ScanRange() (string, string) {
+	if f.Institution != "" {
+		prefix := IndexPrefix(IndexInstitution, f.Institution)
+		return prefix, prefix + indexEnd
+	}
+	if f.Status != "" {
+		prefix := IndexPrefix(IndexStatus, f.Status)
+		return prefix, prefix + indexEnd
+	}
+	base := KYCIndexPrefix + IndexVerified + indexSep
+	end := base + indexEnd
+	if f.VerifiedTo != 0 {
+		end = base + TimeValue(f.VerifiedTo) + indexSep + indexEnd
+	}
+	return base + TimeValue(f.VerifiedFrom), end
+}
+
+// ============================================================================================================================
+// Page KYC Index - walk an index range from a bookmark, collecting up to pageSize records that match
+// ============================================================================================================================
+func PageKYCIndex(stub shim.ChaincodeStubInterface, start string, end string, pageSize int, bookmark string, match func(*model.KYCRecord) bool) (model.KYCPage, error) {
+	page := model.KYCPage{Records: []model.KYCRecord{}}
+	if len(bookmark) > 0 {
+		last, err := base64.RawURLEncoding.DecodeString(bookmark)
+		if err != nil || string(last) < start || string(last) > end {
+			return page, validation.Invalid("bookmark", "does not belong to this listing")
+		}
+		start = string(last) + indexSep //first key after the bookmark
+	}
+
+	iter, err := stub.RangeQueryState(start, end)
+	if err != nil {
+		return page, model.Internal("Failed to scan KYC index")
+	}
+	defer iter.Close()
+
+	for len(page.Records) < pageSize && iter.HasNext() {
+		key, _, err := iter.Next()
+		if err != nil {
+			return page, model.Internal("Failed to scan KYC index")
+		}
+		page.Bookmark = base64.RawURLEncoding.EncodeToString([]byte(key))
+		rec, err := GetKYCRecord(stub, key[strings.LastIndex(key, indexSep)+1:])
+		if err != nil {
+			return page, err
+		}
+		if rec != nil && match(rec) {
+			page.Records = append(page.Records, *rec)
+		}
+	}
+	if !iter.HasNext() {
+		page.Bookmark = "" //nothing after this page
+	}
+	return page, nil
+}
+
+// ============================================================================================================================
+// Indexed Refs - reference tokens of the records listed under one value of an index
+// ============================================================================================================================
+func IndexedRefs(stub shim.ChaincodeStubInterface, index string, value string) ([]string, error) {
+	keys, err := RangeKeys(stub, IndexPrefix(index, value))
+	if err != nil {
+		return nil, err
+	}
+	refs := []string{}
+	for _, key := range keys {
+		refs = append(refs, key[strings.LastIndex(key, indexSep)+1:])
+	}
+	return refs, nil
+}
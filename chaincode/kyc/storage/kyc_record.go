@@ -0,0 +1,106 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+)
+
+// Writer - who is writing a record, the handlers fill it in from the caller, storage does not read certificates
+type Writer struct {
+	Actor       string //how they go down in the record's history, see access.GetActor
+	Institution string //the calling bank, details sealed with the key it passes are marked as its own
+}
+
+// ============================================================================================================================
+// Get KYC Record - read and decode a record, returns nil if there is none for this reference token
+// ============================================================================================================================
+func GetKYCRecord(stub shim.ChaincodeStubInterface, aadharRef string) (*model.KYCRecord, error) {
+	recAsBytes, err := stub.GetState(KYCKey(aadharRef))
+	if err != nil {
+		return nil, model.Internal("Failed to get KYC record " + aadharRef)
+	}
+	if recAsBytes == nil {
+		return nil, nil
+	}
+	rec, err := model.DecodeKYCRecord(recAsBytes)
+	if err != nil {
+		return nil, model.Internal(err.Error()) //whatever is wrong with it, it was stored that way
+	}
+	return &rec, nil
+}
+
+// ============================================================================================================================
+// Put KYC Record - validate and store a record under its reference token, the change goes into its history
+// ============================================================================================================================
+func PutKYCRecord(stub shim.ChaincodeStubInterface, rec model.KYCRecord, w Writer) error {
+	return putKYCRecord(stub, rec, w, false)
+}
+
+func putKYCRecord(stub shim.ChaincodeStubInterface, rec model.KYCRecord, w Writer, legacy bool) error { //legacy for the migrations Init runs, see splitCustomerPII
+	rec.NextReviewDue = model.ReviewDue(rec.VerifiedAt, rec.RiskCategory)
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+	prev, err := GetKYCRecord(stub, rec.Subject.AadharRef)
+	if err != nil {
+		return err
+	}
+	err = splitCustomerPII(stub, KYCKey(rec.Subject.AadharRef), &rec, w.Institution, legacy)
+	if err != nil {
+		return err
+	}
+	jsonAsBytes, _ := json.Marshal(rec)
+	err = stub.PutState(KYCKey(rec.Subject.AadharRef), jsonAsBytes)
+	if err != nil {
+		return err
+	}
+	err = updateKYCIndexes(stub, prev, &rec)
+	if err != nil {
+		return err
+	}
+	return appendKYCHistory(stub, rec.Subject.AadharRef, prev, &rec, w.Actor)
+}
+
+// ============================================================================================================================
+// Delete KYC Record - remove a live record and its index entries from state, its history is kept
+// ============================================================================================================================
+func DeleteKYCRecord(stub shim.ChaincodeStubInterface, ref string, actor string) error {
+	prev, err := GetKYCRecord(stub, ref)
+	if err != nil {
+		return err
+	}
+	if prev == nil {
+		return model.NotFound("No KYC record for " + ref)
+	}
+	err = stub.DelState(KYCKey(ref))
+	if err != nil {
+		return model.Internal("Failed to delete KYC record " + ref)
+	}
+	err = updateKYCIndexes(stub, prev, nil)
+	if err != nil {
+		return err
+	}
+	return appendKYCHistory(stub, ref, prev, nil, actor)
+}
@@ -0,0 +1,92 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package storage
+
+import (
+	"crypto/x509"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+const UIDAICertKey = "_uidaicert" //name for the key/value that will store UIDAI's signing certificate, PEM encoded
+
+const TransientOfflineKYC = "offlineKyc" //name of the offline e-KYC file in the caller metadata
+
+const OfflineKYCPrefix = "offlinekyc_" //offlinekyc_<sha256 of the file> marks a file as used, closing its record leaves it
+
+// ============================================================================================================================
+// Caller Offline KYC - the offline e-KYC file from the caller metadata, nil if the caller did not pass one. The file
+// carries the customer's name, date of birth and photo, so it is kept out of the arguments, which peers log and
+// return to anyone reading the block. Metadata is kept with the transaction too, see encryption.go.
+// ============================================================================================================================
+func CallerOfflineKYC(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	transient, err := getTransient(stub)
+	if err != nil {
+		return nil, err
+	}
+	return transient[TransientOfflineKYC], nil
+}
+
+// ============================================================================================================================
+// Get UIDAI Certificate - the certificate offline e-KYC files must be signed with, it has to be valid at now
+// ============================================================================================================================
+func GetUIDAICertificate(stub shim.ChaincodeStubInterface, now int64) (*x509.Certificate, error) {
+	pemBytes, err := stub.GetState(UIDAICertKey)
+	if err != nil {
+		return nil, model.Internal("Failed to get UIDAI certificate")
+	}
+	if pemBytes == nil {
+		return nil, model.FailedPrecondition("UIDAI certificate is not configured, a regulator has to set it first")
+	}
+	cert, err := validation.UIDAICertificate(pemBytes)
+	if err != nil {
+		return nil, err
+	}
+	at := time.Unix(0, now*int64(time.Millisecond))
+	if at.Before(cert.NotBefore) || at.After(cert.NotAfter) {
+		return nil, model.FailedPrecondition("UIDAI certificate is not valid at the transaction time")
+	}
+	return cert, nil
+}
+
+func PutUIDAICertificate(stub shim.ChaincodeStubInterface, pemBytes []byte) error {
+	return stub.PutState(UIDAICertKey, pemBytes)
+}
+
+// ============================================================================================================================
+// Offline KYC Anchored - whether a file with this digest ever onboarded a customer, the anchor outlives the record so
+// a file cannot be replayed once its record is closed and out of the document index
+// ============================================================================================================================
+func OfflineKYCAnchored(stub shim.ChaincodeStubInterface, sum string) (bool, error) {
+	anchorAsBytes, err := stub.GetState(OfflineKYCPrefix + sum)
+	if err != nil {
+		return false, model.Internal("Failed to get offline e-KYC anchor")
+	}
+	return anchorAsBytes != nil, nil
+}
+
+func AnchorOfflineKYC(stub shim.ChaincodeStubInterface, sum string, now int64) error {
+	return stub.PutState(OfflineKYCPrefix+sum, []byte(strconv.FormatInt(now, 10))) //only when, not whose, so purging the record leaves nothing about the customer
+}
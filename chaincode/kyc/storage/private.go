@@ -0,0 +1,294 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+)
+
+// The customer's details, name, date of birth, address, PAN and the numbers of the documents
+// the bank looked at, are kept apart from the KYC record. The record only carries a salted SHA-256 of them with the names
+// of the fields they hold. Read merges the details back in for the verifying bank, and
+// disclose hands a bank holding a valid consent the ones its purpose allows.
+//
+// The v0.6 shim has no private data collections, so the details are stored under
+// kycpii_<key of the record they belong to>, which is on every peer's ledger. They are only
+// ever written encrypted under the verifying bank's key, see encryption.go, so the peers hold
+// ciphertext and the salt is keyed too. Details Init moved off records written before they
+// were kept apart are the one exception, they stay in the clear until their bank runs
+// rotateKey. On a Fabric with collections these become GetPrivateData and PutPrivateData
+// calls on kycPII and nothing else changes.
+
+const KYCPIIPrefix = "kycpii_"
+
+func PIIKey(stateKey string) string {
+	return KYCPIIPrefix + stateKey
+}
+
+func piiRef(key string) string { //reference token of the customer whose details are under a kycpii_ key
+	stateKey := strings.TrimPrefix(key, KYCPIIPrefix)
+	if strings.HasPrefix(stateKey, ClosedKYCPrefix) {
+		ref := strings.TrimPrefix(stateKey, ClosedKYCPrefix)
+		return ref[:strings.LastIndex(ref, "_")]
+	}
+	return strings.TrimPrefix(stateKey, KYCKeyPrefix)
+}
+
+// ============================================================================================================================
+// Split Customer PII - move the details a record carries into the private store encrypted under the key the calling
+// institution passed, the record keeps their hash. Legacy details moved by Init have no key to go under and are stored
+// in the clear.
+// ============================================================================================================================
+func splitCustomerPII(stub shim.ChaincodeStubInterface, stateKey string, rec *model.KYCRecord, institution string, legacy bool) error {
+	if !rec.HasInlinePII() {
+		return nil //nothing new, the details already stored stay as they are
+	}
+	var key []byte
+	if !legacy {
+		var err error
+		key, err = TransientAESKey(stub, TransientKey)
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			return model.InvalidArgument(TransientKey, "Customer details are only stored encrypted, pass the bank's AES-256 key as "+TransientKey+" in the caller metadata")
+		}
+	}
+	mac := hmac.New(sha256.New, key) //keyed so only the key holder can check a guess against the hash, and no randomness in chaincode
+	mac.Write([]byte("salt|" + stub.GetTxID() + "|" + stateKey))
+
+	pii := model.CustomerPII{Salt: hex.EncodeToString(mac.Sum(nil)), PAN: rec.Subject.PAN, Documents: rec.Documents}
+	pii.Name, pii.DOB, pii.Address = rec.Subject.Name, rec.Subject.DOB, rec.Subject.Address
+	digest := model.PIIDigest{Collection: model.PIICollection, Fields: []string{}, UpdatedAt: rec.UpdatedAt}
+	for field, value := range model.SubjectFields(rec.Subject) {
+		if len(value) > 0 {
+			digest.Fields = append(digest.Fields, field)
+		}
+	}
+	sort.Strings(digest.Fields) //the same order on every endorser
+	if len(pii.Documents) > 0 {
+		digest.Fields = append(digest.Fields, "documents")
+	}
+	jsonAsBytes, _ := json.Marshal(pii)
+	sum := sha256.Sum256(jsonAsBytes)
+	digest.SHA256 = hex.EncodeToString(sum[:])
+
+	if key != nil {
+		sealed, err := sealPII(stub, key, institution, stateKey, jsonAsBytes)
+		if err != nil {
+			return err
+		}
+		jsonAsBytes, _ = json.Marshal(sealed)
+		digest.Encrypted = true
+	}
+	err := stub.PutState(PIIKey(stateKey), jsonAsBytes)
+	if err != nil {
+		return model.Internal("Failed to store customer details")
+	}
+
+	rec.PII = &digest
+	rec.DropInlinePII()
+	if key != nil && strings.HasPrefix(stateKey, KYCKeyPrefix) {
+		_, err = shareCustomerPII(stub, piiRef(PIIKey(stateKey)), key, pii) //banks holding a consent get the new details
+	}
+	return err
+}
+
+// ============================================================================================================================
+// Merge Customer PII - fill a record's details back in from the private store, they have to match the record's hash.
+// Encrypted details are left out unless the caller passed the key, another bank passing its own key just goes without.
+// ============================================================================================================================
+func MergeCustomerPII(stub shim.ChaincodeStubInterface, stateKey string, rec *model.KYCRecord, institution string) error {
+	if rec.PII == nil {
+		return nil
+	}
+	piiAsBytes, err := stub.GetState(PIIKey(stateKey))
+	if err != nil {
+		return model.Internal("Failed to get customer details")
+	}
+	if piiAsBytes == nil {
+		return model.Internal("Customer details are missing from the " + rec.PII.Collection + " collection")
+	}
+	if rec.PII.Encrypted {
+		key, err := TransientAESKey(stub, TransientKey)
+		if err != nil || key == nil {
+			return err
+		}
+		var sealed model.EncryptedPII
+		if err = json.Unmarshal(piiAsBytes, &sealed); err != nil {
+			return model.Internal("Malformed customer details")
+		}
+		if KeyID(key) != sealed.KeyID && institution != sealed.Institution {
+			return nil //not their details to open
+		}
+		piiAsBytes, err = OpenPII(sealed, key, stateKey)
+		if err != nil {
+			return err
+		}
+	}
+	sum := sha256.Sum256(piiAsBytes)
+	if hex.EncodeToString(sum[:]) != rec.PII.SHA256 {
+		return model.Internal("Customer details do not match the hash on the KYC record")
+	}
+	var pii model.CustomerPII
+	if err = json.Unmarshal(piiAsBytes, &pii); err != nil {
+		return model.Internal("Malformed customer details")
+	}
+	rec.Subject.PAN = pii.PAN
+	rec.Subject.Name, rec.Subject.DOB, rec.Subject.Address = pii.Name, pii.DOB, pii.Address
+	rec.Documents = pii.Documents
+	return nil
+}
+
+// ============================================================================================================================
+// Hand Over PII - seal a record's details to the share key of the bank it is handed to, opened with the handing bank's
+// key from the caller metadata. The new bank opens them with its own key and its next change seals them under it.
+// ============================================================================================================================
+func HandOverPII(stub shim.ChaincodeStubInterface, stateKey string, institution string) error {
+	key, err := TransientAESKey(stub, TransientKey)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return model.InvalidArgument(TransientKey, "Customer details are encrypted, pass the bank's key as "+TransientKey+" to seal them for "+institution)
+	}
+	sealedAsBytes, err := stub.GetState(PIIKey(stateKey))
+	if err != nil {
+		return model.Internal("Failed to get customer details")
+	}
+	var sealed model.EncryptedPII
+	if err = json.Unmarshal(sealedAsBytes, &sealed); err != nil {
+		return model.Internal("Malformed customer details")
+	}
+	plaintext, err := OpenPII(sealed, key, stateKey)
+	if err != nil {
+		return err
+	}
+	resealed, err := sealPIIFor(stub, key, institution, stateKey, plaintext) //same details, the hash on the record still holds
+	if err != nil {
+		return err
+	}
+	jsonAsBytes, _ := json.Marshal(resealed)
+	if err = stub.PutState(PIIKey(stateKey), jsonAsBytes); err != nil {
+		return model.Internal("Failed to store customer details")
+	}
+	return nil
+}
+
+// ============================================================================================================================
+// Move Customer PII - the details follow their record when it is closed
+// ============================================================================================================================
+func moveCustomerPII(stub shim.ChaincodeStubInterface, fromKey string, toKey string) error {
+	piiAsBytes, err := stub.GetState(PIIKey(fromKey))
+	if err != nil {
+		return model.Internal("Failed to get customer details")
+	}
+	if piiAsBytes == nil {
+		return nil
+	}
+	err = stub.PutState(PIIKey(toKey), piiAsBytes)
+	if err != nil {
+		return err
+	}
+	return stub.DelState(PIIKey(fromKey))
+}
+
+// ============================================================================================================================
+// Split Stored PII - move the details out of records written before they were kept apart, live, closed and in history,
+// there is no bank key at Init so they stay in the clear until their bank runs rotateKey
+// ============================================================================================================================
+func SplitStoredPII(stub shim.ChaincodeStubInterface, actor string) error {
+	kycKeys, err := RangeKeys(stub, KYCKeyPrefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range kycKeys {
+		rec, err := GetKYCRecord(stub, strings.TrimPrefix(key, KYCKeyPrefix))
+		if err != nil {
+			return err
+		}
+		if rec != nil && rec.HasInlinePII() {
+			if err = putKYCRecord(stub, *rec, Writer{Actor: actor}, true); err != nil { //putKYCRecord does the split
+				return err
+			}
+		}
+	}
+
+	closedKeys, err := RangeKeys(stub, ClosedKYCPrefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range closedKeys {
+		closedAsBytes, err := stub.GetState(key)
+		if err != nil {
+			return model.Internal("Failed to get closed KYC record")
+		}
+		var closed model.ClosedKYCRecord
+		if err = json.Unmarshal(closedAsBytes, &closed); err != nil {
+			return model.Internal("Malformed closed KYC record")
+		}
+		if !closed.Record.HasInlinePII() {
+			continue
+		}
+		if err = splitCustomerPII(stub, key, &closed.Record, "", true); err != nil {
+			return err
+		}
+		jsonAsBytes, _ := json.Marshal(closed)
+		if err = stub.PutState(key, jsonAsBytes); err != nil {
+			return err
+		}
+	}
+
+	historyKeys, err := RangeKeys(stub, KYCHistoryPrefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range historyKeys {
+		history, err := GetKYCHistory(stub, strings.TrimPrefix(key, KYCHistoryPrefix))
+		if err != nil {
+			return err
+		}
+		scrubbed := false
+		for _, version := range history {
+			if version.Record != nil && version.Record.HasInlinePII() {
+				version.Record.DropInlinePII() //older versions only lose the details, the current ones are stored above
+				scrubbed = true
+			}
+		}
+		if scrubbed {
+			jsonAsBytes, _ := json.Marshal(history)
+			if err = stub.PutState(key, jsonAsBytes); err != nil {
+				return err
+			}
+			fmt.Println("- removed customer details from " + key)
+		}
+	}
+	return nil
+}
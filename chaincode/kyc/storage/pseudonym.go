@@ -0,0 +1,263 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/validation"
+)
+
+// Aadhaar numbers never reach world state. Records are keyed by an HMAC-SHA256 of the number
+// under a secret only the banks' own systems hold, the hex digest is the customer's reference
+// token. The secret is not stored either, world state only keeps its fingerprint so a wrong
+// secret is refused instead of quietly giving another token. Transactions are kept with their
+// arguments and caller metadata, so invokes only take the token, worked out by the bank's own
+// systems, and refuse the secret. Queries are not kept and may pass the aadhar number with the
+// secret in the caller metadata as {"aadhaarSecret": "<base64>"}. The deploy's Init is the one
+// transaction that takes the secret, to set it up and move records keyed by plain numbers.
+
+const AadhaarSecretIDKey = "_aadhaarsecretid" //name for the key/value that will store the fingerprint of the pseudonymisation secret
+const legacySecretKey = "_aadhaarsecret"      //where earlier builds kept the secret itself, Init deletes it
+const KYCKeyPrefix = "kyc_"                   //KYC records are stored under kyc_<reference token>
+const LegacyIndexKey = "_marbleindex"         //old list of all KYC records, Init moves it to the per record indexes
+
+const TransientSecret = "aadhaarSecret" //name of the pseudonymisation secret in the caller metadata
+
+// ============================================================================================================================
+// Caller Aadhaar Secret - the pseudonymisation secret from the caller metadata, nil if the caller did not pass one
+// ============================================================================================================================
+func CallerAadhaarSecret(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	transient, err := getTransient(stub)
+	if err != nil {
+		return nil, err
+	}
+	return transient[TransientSecret], nil
+}
+
+// ============================================================================================================================
+// Refuse Aadhaar Secret - invokes take reference tokens only, the secret would be kept with the transaction
+// ============================================================================================================================
+func RefuseAadhaarSecret(stub shim.ChaincodeStubInterface) error {
+	secret, err := CallerAadhaarSecret(stub)
+	if err != nil {
+		return err
+	}
+	if secret != nil {
+		return model.InvalidArgument(TransientSecret, "Invokes are kept with their caller metadata, pass the customer's reference token and leave "+TransientSecret+" out, only queries and the deploy take it")
+	}
+	return nil
+}
+
+// ============================================================================================================================
+// Set Aadhaar Secret - note the fingerprint of the pseudonymisation secret, it can be set once but never changed
+// ============================================================================================================================
+func SetAadhaarSecret(stub shim.ChaincodeStubInterface, secret []byte) error {
+	if len(secret) < 32 {
+		return model.InvalidArgument(TransientSecret, "Aadhaar pseudonymisation secret must be at least 32 random bytes")
+	}
+	existing, err := stub.GetState(AadhaarSecretIDKey)
+	if err != nil {
+		return model.Internal("Failed to get aadhaar pseudonymisation secret")
+	}
+	if existing != nil {
+		if string(existing) != KeyID(secret) {
+			return model.Conflict("Aadhaar pseudonymisation secret is already set and cannot be changed")
+		}
+		return nil
+	}
+	return stub.PutState(AadhaarSecretIDKey, []byte(KeyID(secret)))
+}
+
+// ============================================================================================================================
+// Retire Stored Secret - replace a secret an earlier build kept in world state by its fingerprint, run by Init
+// ============================================================================================================================
+func RetireStoredSecret(stub shim.ChaincodeStubInterface) error {
+	secret, err := stub.GetState(legacySecretKey)
+	if err != nil {
+		return model.Internal("Failed to get aadhaar pseudonymisation secret")
+	}
+	if secret == nil {
+		return nil //never stored, or already retired
+	}
+	err = stub.PutState(AadhaarSecretIDKey, []byte(KeyID(secret)))
+	if err != nil {
+		return err
+	}
+	fmt.Println("- replaced the stored aadhaar secret by its fingerprint, blocks written before still hold it")
+	return stub.DelState(legacySecretKey)
+}
+
+// ============================================================================================================================
+// Aadhaar Secret - the caller's pseudonymisation secret, checked against the fingerprint the ledger was set up with
+// ============================================================================================================================
+func aadhaarSecret(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	id, err := stub.GetState(AadhaarSecretIDKey)
+	if err != nil {
+		return nil, model.Internal("Failed to get aadhaar pseudonymisation secret")
+	}
+	if len(id) == 0 {
+		return nil, model.FailedPrecondition("Aadhaar pseudonymisation secret is not configured, pass it to Init as " + TransientSecret + " in the caller metadata")
+	}
+	secret, err := CallerAadhaarSecret(stub)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, model.InvalidArgument(TransientSecret, "Pass the customer's reference token in place of the aadhar number, queries may pass the number with the aadhaar pseudonymisation secret as "+TransientSecret+" in the caller metadata")
+	}
+	if KeyID(secret) != string(id) {
+		return nil, model.InvalidArgument(TransientSecret, "Aadhaar pseudonymisation secret is not the one the ledger was set up with")
+	}
+	return secret, nil
+}
+
+// ============================================================================================================================
+// Aadhaar Ref - derive the reference token for an aadhar number
+// ============================================================================================================================
+func AadhaarRef(stub shim.ChaincodeStubInterface, aadharNum string) (string, error) {
+	secret, err := aadhaarSecret(stub)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.TrimSpace(aadharNum)))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func KYCKey(ref string) string {
+	return KYCKeyPrefix + ref
+}
+
+// ============================================================================================================================
+// Customer Ref - the reference token a caller passed, or on a query the token for the aadhar number they passed
+// ============================================================================================================================
+func CustomerRef(stub shim.ChaincodeStubInterface, field string, aadharNum string) (string, error) {
+	aadharNum = strings.TrimSpace(aadharNum)
+	if validation.IsReferenceToken(aadharNum) {
+		return aadharNum, nil
+	}
+	if err := validation.Aadhaar(field, aadharNum); err != nil {
+		return "", err
+	}
+	return AadhaarRef(stub, aadharNum)
+}
+
+// ============================================================================================================================
+// Pseudonymise Legacy Records - move every record stored under a plain aadhar number to its reference token and delete
+// the plain key, run by Init. Those are the version 1 records and what init_marble, set_user and Write stored before,
+// see model.DecodeLegacyKYCRecord. init_marble listed its keys in _marbleindex but Write did not, so every key that is an
+// aadhar number is looked at as well. Index entries that are not KYC records are dropped from the index and left alone.
+// ============================================================================================================================
+func PseudonymiseLegacyRecords(stub shim.ChaincodeStubInterface, actor string) error {
+	indexAsBytes, err := stub.GetState(LegacyIndexKey)
+	if err != nil {
+		return model.Internal("Failed to get KYC index")
+	}
+	var kycIndex []string
+	json.Unmarshal(indexAsBytes, &kycIndex)
+	aadhaarKeys, err := legacyAadhaarKeys(stub)
+	if err != nil {
+		return err
+	}
+	now, err := TxTimestamp(stub)
+	if err != nil {
+		return err
+	}
+
+	migrated := 0
+	seen := map[string]bool{}
+	for i, entry := range append(kycIndex, aadhaarKeys...) {
+		if seen[entry] {
+			continue //written by init_marble, and listed
+		}
+		seen[entry] = true
+		legacyAsBytes, err := stub.GetState(entry)
+		if err != nil {
+			return model.Internal("Failed to get legacy KYC record " + fmt.Sprint(i))
+		}
+		if legacyAsBytes == nil {
+			continue //already a reference token
+		}
+		rec, aadharNum, err := model.DecodeLegacyKYCRecord(entry, legacyAsBytes, now)
+		if err != nil || validation.Aadhaar("aadharNum", aadharNum) != nil {
+			fmt.Println("- legacy key " + fmt.Sprint(i) + " does not hold a KYC record of an aadhar number, left as it is")
+			continue
+		}
+		ref, err := AadhaarRef(stub, aadharNum)
+		if err != nil {
+			return err
+		}
+		existing, err := GetKYCRecord(stub, ref)
+		if err != nil {
+			return err
+		}
+		if existing == nil { //otherwise the customer was onboarded again since, that record stands
+			rec.Subject.AadharRef = ref
+			if err = putKYCRecord(stub, rec, Writer{Actor: actor}, true); err != nil {
+				return err
+			}
+		}
+		if err = stub.DelState(entry); err != nil {
+			return model.Internal("Failed to delete legacy KYC record " + fmt.Sprint(i))
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		fmt.Println("- pseudonymised " + fmt.Sprint(migrated) + " legacy KYC records")
+	}
+	if indexAsBytes == nil {
+		return nil
+	}
+	fmt.Println("- KYC index migrated, removing " + LegacyIndexKey)
+	return stub.DelState(LegacyIndexKey) //every entry moved, or was not a KYC record
+}
+
+// ============================================================================================================================
+// Legacy Aadhaar Keys - every key in world state that is a plain aadhar number, they start with 2 to 9
+// ============================================================================================================================
+func legacyAadhaarKeys(stub shim.ChaincodeStubInterface) ([]string, error) {
+	iter, err := stub.RangeQueryState("2", ":")
+	if err != nil {
+		return nil, model.Internal("Failed to scan for legacy KYC records")
+	}
+	defer iter.Close()
+
+	keys := []string{}
+	for iter.HasNext() {
+		key, _, err := iter.Next()
+		if err != nil {
+			return nil, model.Internal("Failed to scan for legacy KYC records")
+		}
+		if validation.Aadhaar("aadharNum", key) == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,96 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+)
+
+// Closing a record moves it out of kyc_<reference token>, so read, list and every invoke
+// function stop seeing it and the customer can be onboarded again, into
+// closedkyc_<reference token>_<closed at>. Auditors can read it there until it is purged.
+
+const ClosedKYCPrefix = "closedkyc_"
+
+func ClosedKYCKey(ref string, closedAt int64) string {
+	return ClosedKYCPrefix + ref + "_" + TimeValue(closedAt)
+}
+
+// ============================================================================================================================
+// Get Closed KYC Records - every closed record of a customer that has not been purged, oldest first
+// ============================================================================================================================
+func GetClosedKYCRecords(stub shim.ChaincodeStubInterface, ref string) ([]string, []model.ClosedKYCRecord, error) {
+	keys, err := RangeKeys(stub, ClosedKYCPrefix+ref+"_")
+	if err != nil {
+		return nil, nil, err
+	}
+	closed := []model.ClosedKYCRecord{}
+	for _, key := range keys {
+		closedAsBytes, err := stub.GetState(key)
+		if err != nil {
+			return nil, nil, model.Internal("Failed to get closed KYC record")
+		}
+		var c model.ClosedKYCRecord
+		if err = json.Unmarshal(closedAsBytes, &c); err != nil {
+			return nil, nil, model.Internal("Malformed closed KYC record")
+		}
+		c.Record.Institution = model.BankCode(c.Record.Institution) //closed before bank codes were normalised
+		closed = append(closed, c)
+	}
+	return keys, closed, nil
+}
+
+// ============================================================================================================================
+// Close KYC Record - move a live record and its customer's details under the closed key
+// ============================================================================================================================
+func CloseKYCRecord(stub shim.ChaincodeStubInterface, closed model.ClosedKYCRecord) error {
+	ref := closed.Record.Subject.AadharRef
+	jsonAsBytes, _ := json.Marshal(closed)
+	err := stub.PutState(ClosedKYCKey(ref, closed.ClosedAt), jsonAsBytes)
+	if err != nil {
+		return err
+	}
+	err = moveCustomerPII(stub, KYCKey(ref), ClosedKYCKey(ref, closed.ClosedAt))
+	if err != nil {
+		return err
+	}
+	err = dropCustomerSharedPII(stub, ref) //consented banks lose their copies with the record
+	if err != nil {
+		return err
+	}
+	return DeleteKYCRecord(stub, ref, closed.ClosedBy) //out of the live records and their indexes
+}
+
+// ============================================================================================================================
+// Purge Closed KYC Record - erase a closed record and its customer's details
+// ============================================================================================================================
+func PurgeClosedKYCRecord(stub shim.ChaincodeStubInterface, key string) error {
+	if err := stub.DelState(key); err != nil {
+		return model.Internal("Failed to purge closed KYC record")
+	}
+	if err := stub.DelState(PIIKey(key)); err != nil {
+		return model.Internal("Failed to purge customer details")
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+)
+
+// A bank holding a consent cannot open details sealed under the verifying bank's key. Each
+// consent gets its own copy of the details its purpose discloses, sealed to the consented
+// bank's share key and stored under kycshare_<reference token>_<bank code>_<purpose>. Only
+// the verifying bank's key opens the details, so copies are sealed when it passes kycKey:
+// on grantConsent, approveRequest and shareDetails, and again whenever it changes the
+// details. The copy goes when the consent is revoked, the record is closed or the consent
+// runs out, see DropExpiredSharedPII, so a bank only holds details while it may read them.
+
+const SharedPIIPrefix = "kycshare_"
+
+func SharedPIIKey(consentKey string) string {
+	return SharedPIIPrefix + strings.TrimPrefix(consentKey, consentKeyPrefix)
+}
+
+// ============================================================================================================================
+// Share Customer PII - seal the details of a record for every consent its customer has that stands and drop the copies
+// of the rest, the verifying bank's key comes from the caller metadata. Nothing is sealed without the key or when the
+// details are not encrypted, consented banks read those as they are. Returns how many copies are held now.
+// ============================================================================================================================
+func ShareCustomerPII(stub shim.ChaincodeStubInterface, rec *model.KYCRecord, institution string) (int, error) {
+	key, err := TransientAESKey(stub, TransientKey)
+	if err != nil || key == nil || rec.PII == nil || !rec.PII.Encrypted {
+		return 0, err
+	}
+	opened := *rec
+	if err = MergeCustomerPII(stub, KYCKey(rec.Subject.AadharRef), &opened, institution); err != nil {
+		return 0, err
+	}
+	if !opened.HasInlinePII() {
+		return 0, model.PermissionDenied("only " + rec.Institution + " can share the details of this KYC record")
+	}
+	pii := model.CustomerPII{PAN: opened.Subject.PAN, Documents: opened.Documents}
+	pii.Name, pii.DOB, pii.Address = opened.Subject.Name, opened.Subject.DOB, opened.Subject.Address
+	return shareCustomerPII(stub, rec.Subject.AadharRef, key, pii)
+}
+
+func shareCustomerPII(stub shim.ChaincodeStubInterface, ref string, key []byte, pii model.CustomerPII) (int, error) {
+	consents, err := GetConsents(stub, ref)
+	if err != nil {
+		return 0, err
+	}
+	now, err := TxTimestamp(stub)
+	if err != nil {
+		return 0, err
+	}
+	shared := 0
+	for _, consent := range consents {
+		sharedKey := SharedPIIKey(ConsentKey(ref, consent.Bank, consent.Purpose))
+		bank, err := GetBank(stub, consent.Bank)
+		if err != nil {
+			return 0, err
+		}
+		if !consent.ValidAt(now) || bank == nil || len(bank.ShareKey) == 0 {
+			if err = stub.DelState(sharedKey); err != nil { //nothing to seal to, or no longer entitled
+				return 0, model.Internal("Failed to drop shared customer details")
+			}
+			continue
+		}
+		jsonAsBytes, _ := json.Marshal(disclosedPII(pii, consent.Purpose))
+		sealed, err := sealPIIFor(stub, key, consent.Bank, sharedKey, jsonAsBytes)
+		if err != nil {
+			return 0, err
+		}
+		jsonAsBytes, _ = json.Marshal(sealed)
+		if err = stub.PutState(sharedKey, jsonAsBytes); err != nil {
+			return 0, model.Internal("Failed to store shared customer details")
+		}
+		shared++
+	}
+	return shared, nil
+}
+
+// ============================================================================================================================
+// Disclosed PII - only the details a purpose's disclosure profile lists
+// ============================================================================================================================
+func disclosedPII(pii model.CustomerPII, purpose string) model.CustomerPII {
+	disclosed := model.CustomerPII{Documents: []model.DocumentRef{}}
+	for _, field := range model.DisclosureProfiles[purpose] {
+		switch field {
+		case "name":
+			disclosed.Name = pii.Name
+		case "dob":
+			disclosed.DOB = pii.DOB
+		case "address":
+			disclosed.Address = pii.Address
+		case "pan":
+			disclosed.PAN = pii.PAN
+		case "documents":
+			disclosed.Documents = pii.Documents
+		}
+	}
+	return disclosed
+}
+
+// ============================================================================================================================
+// Open Shared PII - the details sealed for a consent, opened with the consented bank's key from the caller metadata. Nil
+// without the key, the bool says whether a copy was sealed at all.
+// ============================================================================================================================
+func OpenSharedPII(stub shim.ChaincodeStubInterface, consentKey string) (*model.CustomerPII, bool, error) {
+	sharedKey := SharedPIIKey(consentKey)
+	sealedAsBytes, err := stub.GetState(sharedKey)
+	if err != nil {
+		return nil, false, model.Internal("Failed to get shared customer details")
+	}
+	if sealedAsBytes == nil {
+		return nil, false, nil
+	}
+	key, err := TransientAESKey(stub, TransientKey)
+	if err != nil || key == nil {
+		return nil, true, err
+	}
+	var sealed model.EncryptedPII
+	if err = json.Unmarshal(sealedAsBytes, &sealed); err != nil {
+		return nil, true, model.Internal("Malformed shared customer details")
+	}
+	plaintext, err := OpenPII(sealed, key, sharedKey)
+	if err != nil {
+		return nil, true, err
+	}
+	var pii model.CustomerPII
+	if err = json.Unmarshal(plaintext, &pii); err != nil {
+		return nil, true, model.Internal("Malformed shared customer details")
+	}
+	return &pii, true, nil
+}
+
+// ============================================================================================================================
+// Drop Shared PII - remove the copy of the details sealed for a consent
+// ============================================================================================================================
+func DropSharedPII(stub shim.ChaincodeStubInterface, consentKey string) error {
+	if err := stub.DelState(SharedPIIKey(consentKey)); err != nil {
+		return model.Internal("Failed to drop shared customer details")
+	}
+	return nil
+}
+
+// ============================================================================================================================
+// Drop Expired Shared PII - remove the copies of every consent that ran out or is gone, returns how many
+// ============================================================================================================================
+func DropExpiredSharedPII(stub shim.ChaincodeStubInterface) (int, error) {
+	keys, err := RangeKeys(stub, SharedPIIPrefix)
+	if err != nil {
+		return 0, err
+	}
+	now, err := TxTimestamp(stub)
+	if err != nil {
+		return 0, err
+	}
+	dropped := 0
+	for _, key := range keys {
+		consentKey := consentKeyPrefix + strings.TrimPrefix(key, SharedPIIPrefix)
+		consent, err := GetConsent(stub, consentKey)
+		if err != nil {
+			return 0, err
+		}
+		if consent != nil && consent.ValidAt(now) {
+			continue
+		}
+		if err = DropSharedPII(stub, consentKey); err != nil {
+			return 0, err
+		}
+		dropped++
+	}
+	return dropped, nil
+}
+
+// ============================================================================================================================
+// Rotate Shared PII - seal the copies held for a bank under its old share key to the share key now registered for it,
+// opened with its old key, returns how many
+// ============================================================================================================================
+func RotateSharedPII(stub shim.ChaincodeStubInterface, institution string, oldKey []byte, newKey []byte) (int, error) {
+	keys, err := RangeKeys(stub, SharedPIIPrefix)
+	if err != nil {
+		return 0, err
+	}
+	oldShare, err := ShareKey(oldKey)
+	if err != nil {
+		return 0, err
+	}
+	oldID := KeyID(oldShare.PublicKey().Bytes())
+	rotated := 0
+	for _, key := range keys {
+		sealedAsBytes, err := stub.GetState(key)
+		if err != nil {
+			return 0, model.Internal("Failed to get shared customer details")
+		}
+		var sealed model.EncryptedPII
+		if json.Unmarshal(sealedAsBytes, &sealed) != nil || sealed.Institution != institution || sealed.KeyID != oldID {
+			continue //held for another bank
+		}
+		plaintext, err := OpenPII(sealed, oldKey, key)
+		if err != nil {
+			return 0, err
+		}
+		resealed, err := sealPIIFor(stub, newKey, institution, key, plaintext)
+		if err != nil {
+			return 0, err
+		}
+		jsonAsBytes, _ := json.Marshal(resealed)
+		if err = stub.PutState(key, jsonAsBytes); err != nil {
+			return 0, err
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+// ============================================================================================================================
+// Drop Customer Shared PII - remove every copy sealed for a customer's consents, their record is closed
+// ============================================================================================================================
+func dropCustomerSharedPII(stub shim.ChaincodeStubInterface, ref string) error {
+	consents, err := GetConsents(stub, ref)
+	if err != nil {
+		return err
+	}
+	for _, consent := range consents {
+		if err = DropSharedPII(stub, ConsentKey(ref, consent.Bank, consent.Purpose)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+)
+
+const shareRequestKeyPrefix = "sharereq_"        //requests are stored under sharereq_<transaction id>
+const shareRequestIndexPrefix = "sharereqindex_" //sharereqindex_<reference token> lists the request ids of one customer
+
+func GetShareRequest(stub shim.ChaincodeStubInterface, id string) (*model.ShareRequest, error) {
+	reqAsBytes, err := stub.GetState(shareRequestKeyPrefix + id)
+	if err != nil {
+		return nil, model.Internal("Failed to get share request " + id)
+	}
+	if reqAsBytes == nil {
+		return nil, nil
+	}
+	req := model.ShareRequest{}
+	err = json.Unmarshal(reqAsBytes, &req)
+	if err != nil {
+		return nil, model.Internal("Malformed share request " + id)
+	}
+	return &req, nil
+}
+
+func PutShareRequest(stub shim.ChaincodeStubInterface, req model.ShareRequest) error {
+	jsonAsBytes, _ := json.Marshal(req)
+	return stub.PutState(shareRequestKeyPrefix+req.ID, jsonAsBytes)
+}
+
+func GetShareRequestIndex(stub shim.ChaincodeStubInterface, ref string) ([]string, error) {
+	indexAsBytes, err := stub.GetState(shareRequestIndexPrefix + ref)
+	if err != nil {
+		return nil, model.Internal("Failed to get share request index")
+	}
+	var requestIndex []string
+	json.Unmarshal(indexAsBytes, &requestIndex)
+	return requestIndex, nil
+}
+
+func PutShareRequestIndex(stub shim.ChaincodeStubInterface, ref string, requestIndex []string) error {
+	jsonAsBytes, _ := json.Marshal(requestIndex)
+	return stub.PutState(shareRequestIndexPrefix+ref, jsonAsBytes)
+}
@@ -0,0 +1,68 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package storage is the KYC ledger's repository over the chaincode stub. It knows the key
+// layout in world state and keeps what belongs together in step: a record, its index keys,
+// its history and the customer's details kept apart from it. Who is calling is the handlers'
+// business, they pass the actor and institution down, see Writer.
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+)
+
+// ============================================================================================================================
+// Tx Timestamp - the transaction's timestamp in ms, every endorser of the transaction gets the same value
+// ============================================================================================================================
+func TxTimestamp(stub shim.ChaincodeStubInterface) (int64, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return 0, model.Internal("Failed to get transaction timestamp")
+	}
+	if ts == nil {
+		return 0, model.Internal("Transaction has no timestamp")
+	}
+	return ts.Seconds*1000 + int64(ts.Nanos)/int64(time.Millisecond), nil
+}
+
+// ============================================================================================================================
+// Seed State - write a value only if the key does not exist yet, or if a JSON value is expected and what is there does
+// not parse, then it is repaired with the seed
+// ============================================================================================================================
+func SeedState(stub shim.ChaincodeStubInterface, key string, value []byte) error {
+	existing, err := stub.GetState(key)
+	if err != nil {
+		return model.Internal("Failed to get " + key)
+	}
+	if existing != nil {
+		var parsed interface{}
+		if json.Unmarshal(value, &parsed) != nil || json.Unmarshal(existing, &parsed) == nil {
+			fmt.Println("- keeping existing " + key)
+			return nil
+		}
+		fmt.Println("- " + key + " is not valid JSON, repairing it")
+	}
+	return stub.PutState(key, value)
+}
@@ -0,0 +1,120 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package validation
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"time"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/xmldsig"
+)
+
+// ============================================================================================================================
+// UIDAI Certificate - parse the PEM encoded certificate offline e-KYC files are signed with
+// ============================================================================================================================
+func UIDAICertificate(pemBytes []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, Invalid("certificate", "must be a PEM encoded certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, Invalid("certificate", err.Error())
+	}
+	return cert, nil
+}
+
+// ============================================================================================================================
+// Offline KYC - pull the reference id and hashes out of a verified file and check it names the last 4 digits passed
+// ============================================================================================================================
+func OfflineKYC(root *xmldsig.Node, last4 string) (model.OfflineKYC, error) {
+	var off model.OfflineKYC
+	if root.Name.Local != "OfflinePaperlessKyc" {
+		return off, model.InvalidArgument("offlineKyc", "XML is not an offline paperless e-KYC file")
+	}
+	off.ReferenceID = root.Attr("referenceId")
+	if len(off.ReferenceID) < 4+17 {
+		return off, Invalid("referenceId", "must be 4 digits followed by a yyyyMMddHHmmssSSS timestamp")
+	}
+	if off.ReferenceID[:4] != last4 {
+		return off, Invalid("referenceId", "was issued for a different aadhar number")
+	}
+	generated, err := time.ParseInLocation("20060102150405", off.ReferenceID[4:18], time.FixedZone("IST", 330*60))
+	if err != nil {
+		return off, Invalid("referenceId", "must be 4 digits followed by a yyyyMMddHHmmssSSS timestamp")
+	}
+	off.GeneratedAt = generated.UnixNano() / int64(time.Millisecond)
+
+	uidData := root.Child("UidData")
+	if uidData == nil || uidData.Child("Poi") == nil {
+		return off, model.InvalidArgument("offlineKyc", "Offline e-KYC file has no UidData/Poi element")
+	}
+	poi := uidData.Child("Poi")
+	off.EmailHash = strings.ToLower(poi.Attr("e"))
+	off.MobileHash = strings.ToLower(poi.Attr("m"))
+	if len(off.EmailHash) > 0 {
+		if err := Digest("email hash", off.EmailHash); err != nil {
+			return off, err
+		}
+	}
+	if len(off.MobileHash) > 0 {
+		if err := Digest("mobile hash", off.MobileHash); err != nil {
+			return off, err
+		}
+	}
+	return off, nil
+}
+
+// Poa attributes in the order they make up an address, care of first and the pin code last
+var poaParts = []string{"careof", "house", "street", "landmark", "loc", "vtc", "po", "subdist", "dist", "state", "country", "pc"}
+
+// ============================================================================================================================
+// Offline Details - the customer's name, date of birth and address from a verified file, they are sealed with the other
+// details of the record, see storage/private.go
+// ============================================================================================================================
+func OfflineDetails(root *xmldsig.Node) (model.SubjectIDs, error) {
+	var subject model.SubjectIDs
+	uidData := root.Child("UidData")
+	if uidData == nil || uidData.Child("Poi") == nil {
+		return subject, model.InvalidArgument("offlineKyc", "Offline e-KYC file has no UidData/Poi element")
+	}
+	poi := uidData.Child("Poi")
+	subject.Name = strings.TrimSpace(poi.Attr("name"))
+	if dob := strings.TrimSpace(poi.Attr("dob")); len(dob) > 0 {
+		born, err := time.Parse("02-01-2006", dob) //UIDAI writes dd-mm-yyyy
+		if err != nil {
+			return subject, Invalid("dob", "must look like dd-mm-yyyy")
+		}
+		subject.DOB = born.Format(model.ReviewDateLayout)
+	}
+	if poa := uidData.Child("Poa"); poa != nil {
+		parts := []string{}
+		for _, name := range poaParts {
+			if part := strings.TrimSpace(poa.Attr(name)); len(part) > 0 {
+				parts = append(parts, part)
+			}
+		}
+		subject.Address = strings.Join(parts, ", ")
+	}
+	return subject, nil
+}
@@ -0,0 +1,199 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package validation checks the identifiers and values callers pass in: aadhaar, PAN,
+// passport and voter id numbers, digests, storage URIs and UIDAI's offline e-KYC files.
+// Errors name the field that failed and never echo the value.
+package validation
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+)
+
+// ============================================================================================================================
+// Invalid - the INVALID_ARGUMENT error for a field that failed validation, the value itself is left out since it is usually PII
+// ============================================================================================================================
+func Invalid(field string, reason string) error {
+	return model.InvalidArgument(field, "Invalid "+field+": "+reason)
+}
+
+var aadhaarPattern = regexp.MustCompile(`^[2-9][0-9]{11}$`)                     //UIDAI never issues numbers starting with 0 or 1
+var panPattern = regexp.MustCompile(`^[A-Z]{3}[ABCFGHJLPT][A-Z][0-9]{4}[A-Z]$`) //4th letter is the holder type
+var passportPattern = regexp.MustCompile(`^[A-PR-WY][1-9][0-9]{5}[1-9]$`)
+var voterIDPattern = regexp.MustCompile(`^[A-Z]{3}[0-9]{7}$`) //EPIC number
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+var ifscPattern = regexp.MustCompile(`^[A-Z]{4}0[A-Z0-9]{6}$`) //bank, a zero, then the branch
+
+// Verhoeff checksum tables, see https://en.wikipedia.org/wiki/Verhoeff_algorithm
+var verhoeffD = [10][10]int{
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+	{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+	{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+	{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+	{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+	{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+	{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+	{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+	{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+	{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+}
+
+var verhoeffP = [8][10]int{
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+	{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+	{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+	{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+	{9, 4, 5, 3, 1, 2, 6, 8, 7, 0},
+	{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+	{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+	{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+}
+
+// ============================================================================================================================
+// Verhoeff Valid - true if the last digit of a numeric string is a correct Verhoeff check digit
+// ============================================================================================================================
+func VerhoeffValid(num string) bool {
+	c := 0
+	for i := 0; i < len(num); i++ {
+		digit := num[len(num)-1-i]
+		if digit < '0' || digit > '9' {
+			return false
+		}
+		c = verhoeffD[c][verhoeffP[i%8][digit-'0']]
+	}
+	return c == 0
+}
+
+// ============================================================================================================================
+// Aadhaar - 12 digits, valid first digit and Verhoeff check digit
+// ============================================================================================================================
+func Aadhaar(field string, aadharNum string) error {
+	if len(aadharNum) == 0 {
+		return Invalid(field, "required")
+	}
+	if !aadhaarPattern.MatchString(aadharNum) {
+		return Invalid(field, "must be 12 digits and cannot start with 0 or 1")
+	}
+	if !VerhoeffValid(aadharNum) {
+		return Invalid(field, "checksum does not match")
+	}
+	return nil
+}
+
+// ============================================================================================================================
+// Is Reference Token - true for a customer's reference token, the hex HMAC-SHA256 of their aadhar number
+// ============================================================================================================================
+func IsReferenceToken(value string) bool {
+	return sha256Pattern.MatchString(value)
+}
+
+// ============================================================================================================================
+// PAN - AAAPA9999A, 4th letter is the holder type
+// ============================================================================================================================
+func PAN(field string, pan string) error {
+	if !panPattern.MatchString(pan) {
+		return Invalid(field, "must be 5 letters, 4 digits and a letter with a valid holder type")
+	}
+	return nil
+}
+
+// ============================================================================================================================
+// Passport - a series letter other than Q, X or Z, then 7 digits that neither start nor end with 0
+// ============================================================================================================================
+func Passport(field string, passport string) error {
+	if !passportPattern.MatchString(passport) {
+		return Invalid(field, "must be a letter other than Q, X or Z followed by 7 digits that do not start or end with 0")
+	}
+	return nil
+}
+
+// ============================================================================================================================
+// Voter ID - EPIC number, 3 letters and 7 digits
+// ============================================================================================================================
+func VoterID(field string, voterID string) error {
+	if !voterIDPattern.MatchString(voterID) {
+		return Invalid(field, "must be 3 letters followed by 7 digits")
+	}
+	return nil
+}
+
+// ============================================================================================================================
+// Bank Code - IFSC style, 4 letters, a zero and 6 letters or digits, in upper case. Codes go into index and consent
+// keys, so nothing else can get through.
+// ============================================================================================================================
+func BankCode(field string, code string) error {
+	if !ifscPattern.MatchString(code) {
+		return Invalid(field, "must be an IFSC style code, 4 letters, a 0 and 6 letters or digits")
+	}
+	return nil
+}
+
+// ============================================================================================================================
+// Digest - hex encoded SHA-256, 64 lower case characters
+// ============================================================================================================================
+func Digest(field string, digest string) error {
+	if !sha256Pattern.MatchString(digest) {
+		return Invalid(field, "must be a hex encoded SHA-256 digest")
+	}
+	return nil
+}
+
+// ============================================================================================================================
+// Storage URI - an absolute URI where the document is kept off chain
+// ============================================================================================================================
+func StorageURI(field string, uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || (len(u.Host) == 0 && len(u.Opaque) == 0) {
+		return Invalid(field, "must be an absolute URI such as https://host/path or s3://bucket/key")
+	}
+	return nil
+}
+
+// ============================================================================================================================
+// Document Refs - identifier documents must carry a well formed number, the type is matched in any case so the number
+// is trimmed and upper cased in place before it is checked
+// ============================================================================================================================
+func DocumentRefs(docs []model.DocumentRef) error {
+	for i, doc := range docs {
+		field := "document " + doc.Type
+		ref := strings.ToUpper(strings.TrimSpace(doc.Ref))
+		var err error
+		switch strings.ToLower(doc.Type) {
+		case "aadhaar":
+			err = Invalid(field, "aadhaar numbers cannot be stored as document references")
+		case "pan":
+			err = PAN(field, ref)
+			docs[i].Ref = ref
+		case "passport":
+			err = Passport(field, ref)
+			docs[i].Ref = ref
+		case "voterid":
+			err = VoterID(field, ref)
+			docs[i].Ref = ref
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+)
+
+// aadhar numbers with a valid Verhoeff check digit
+const (
+	aadhaarA = "234123412346"
+	aadhaarB = "987654321012"
+	aadhaarC = "500000000006"
+	aadhaarD = "500000000010"
+)
+
+func TestValidators(t *testing.T) {
+	cases := []struct {
+		name     string
+		validate func(field string, value string) error
+		value    string
+		want     string
+	}{
+		{"aadhaar", Aadhaar, aadhaarA, ""},
+		{"aadhaar empty", Aadhaar, "", "Invalid f: required"},
+		{"aadhaar short", Aadhaar, "23412341234", "must be 12 digits"},
+		{"aadhaar starts with 0", Aadhaar, "034123412346", "cannot start with 0 or 1"},
+		{"aadhaar letters", Aadhaar, "23412341234X", "must be 12 digits"},
+		{"aadhaar checksum", Aadhaar, "234123412345", "checksum does not match"},
+		{"pan", PAN, "ABCPE1234F", ""},
+		{"pan holder type", PAN, "ABCQE1234F", "valid holder type"},
+		{"pan lower case", PAN, "abcpe1234f", "Invalid f"},
+		{"passport", Passport, "K1234567", ""},
+		{"passport letter Q", Passport, "Q1234567", "a letter other than Q, X or Z"},
+		{"passport starts with 0", Passport, "K0234567", "do not start or end with 0"},
+		{"passport ends in 0", Passport, "K1234560", "do not start or end with 0"},
+		{"voter id", VoterID, "ABC1234567", ""},
+		{"voter id short", VoterID, "AB1234567", "3 letters followed by 7 digits"},
+		{"bank code", BankCode, "SBIN0000001", ""},
+		{"bank code alphanumeric branch", BankCode, "HDFC0A1B2C3", ""},
+		{"bank code no zero", BankCode, "SBIN1000001", "must be an IFSC style code"},
+		{"bank code separator", BankCode, "SBIN0_00001", "must be an IFSC style code"},
+		{"bank code lower case", BankCode, "sbin0000001", "must be an IFSC style code"},
+		{"digest", Digest, strings.Repeat("0f", 32), ""},
+		{"digest upper case", Digest, strings.Repeat("0F", 32), "hex encoded SHA-256"},
+		{"uri", StorageURI, "https://docs.example/a", ""},
+		{"uri s3", StorageURI, "s3://bucket/key", ""},
+		{"uri urn", StorageURI, "urn:uuid:6e8bc430", ""},
+		{"uri relative", StorageURI, "/docs/a", "must be an absolute URI"},
+		{"uri no host", StorageURI, "https:///docs", "must be an absolute URI"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expectError(t, c.validate("f", c.value), c.want)
+		})
+	}
+}
+
+func TestVerhoeff(t *testing.T) {
+	for _, num := range []string{"2363", aadhaarA, aadhaarB, aadhaarC, aadhaarD} {
+		if !VerhoeffValid(num) {
+			t.Errorf("%s should be valid", num)
+		}
+	}
+	for _, num := range []string{"2364", "234123412347", "98765432101a"} {
+		if VerhoeffValid(num) {
+			t.Errorf("%s should be invalid", num)
+		}
+	}
+}
+
+func TestValidateDocumentRefs(t *testing.T) {
+	cases := []struct {
+		arg  string
+		want string
+	}{
+		{"passport:K1234567", ""},
+		{"voterid:ABC1234567", ""},
+		{"pan:ABCPE1234F", ""},
+		{"ration_card:anything goes", ""},
+		{"aadhaar:" + aadhaarA, "aadhaar numbers cannot be stored"},
+		{"PAN:ABCQE1234F", "Invalid document PAN"},
+		{"pan:abcpe1234f", ""},
+		{"Passport: k1234567 ", ""},
+		{"passport:", "must look like type:ref"},
+	}
+	for _, c := range cases {
+		t.Run(c.arg, func(t *testing.T) {
+			docs, err := model.ParseDocumentRefs([]string{c.arg})
+			if err == nil {
+				err = DocumentRefs(docs)
+			}
+			expectError(t, err, c.want)
+		})
+	}
+
+	docs := []model.DocumentRef{{Type: "pan", Ref: " abcpe1234f"}, {Type: "ration_card", Ref: "rc 12"}}
+	if err := DocumentRefs(docs); err != nil {
+		t.Fatal(err)
+	}
+	if docs[0].Ref != "ABCPE1234F" || docs[1].Ref != "rc 12" {
+		t.Errorf("identifier numbers should be stored upper cased and others as given, got %+v", docs)
+	}
+}
+
+func expectError(t *testing.T, err error, want string) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected an error containing %q, got %v", want, err)
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package x25519 is the X25519 Diffie-Hellman of RFC 7748, the part of crypto/ecdh the share
+// keys use. crypto/ecdh came with Go 1.20 and the peers build chaincode with Go 1.7, so the
+// Montgomery ladder is done here with math/big. It is not constant time: the keys it handles
+// come in the caller metadata, which is kept with the transaction anyway, see storage/encryption.go.
+package x25519
+
+import (
+	"errors"
+	"math/big"
+)
+
+const Size = 32 //bytes in a scalar, a point and a shared secret
+
+var (
+	p   = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19)) //2^255 - 19
+	a24 = big.NewInt(121665)
+)
+
+type PrivateKey struct {
+	scalar []byte
+}
+
+type PublicKey struct {
+	point []byte
+}
+
+// ============================================================================================================================
+// New Private Key - a private key from 32 bytes, clamped when it is used as RFC 7748 says
+// ============================================================================================================================
+func NewPrivateKey(key []byte) (*PrivateKey, error) {
+	if len(key) != Size {
+		return nil, errors.New("x25519: private key must be 32 bytes")
+	}
+	return &PrivateKey{scalar: append([]byte{}, key...)}, nil
+}
+
+// ============================================================================================================================
+// New Public Key - a public key from its 32 byte u-coordinate
+// ============================================================================================================================
+func NewPublicKey(key []byte) (*PublicKey, error) {
+	if len(key) != Size {
+		return nil, errors.New("x25519: public key must be 32 bytes")
+	}
+	return &PublicKey{point: append([]byte{}, key...)}, nil
+}
+
+func (k *PrivateKey) PublicKey() *PublicKey {
+	base := make([]byte, Size)
+	base[0] = 9
+	return &PublicKey{point: scalarMult(k.scalar, base)}
+}
+
+func (k *PublicKey) Bytes() []byte {
+	return append([]byte{}, k.point...)
+}
+
+// ============================================================================================================================
+// ECDH - the shared secret with a peer's public key, refused when it comes out all zeros as crypto/ecdh does
+// ============================================================================================================================
+func (k *PrivateKey) ECDH(peer *PublicKey) ([]byte, error) {
+	secret := scalarMult(k.scalar, peer.point)
+	var acc byte
+	for _, b := range secret {
+		acc |= b
+	}
+	if acc == 0 {
+		return nil, errors.New("x25519: bad public key, the shared secret is all zeros")
+	}
+	return secret, nil
+}
+
+// scalarMult is the X25519 function of RFC 7748 section 5
+func scalarMult(scalar, point []byte) []byte {
+	k := append([]byte{}, scalar...)
+	k[0] &= 248
+	k[31] &= 127
+	k[31] |= 64
+	u := append([]byte{}, point...)
+	u[31] &= 127 //the top bit of the u-coordinate is ignored
+
+	x1 := new(big.Int).Mod(decode(u), p)
+	x2, z2 := big.NewInt(1), big.NewInt(0)
+	x3, z3 := new(big.Int).Set(x1), big.NewInt(1)
+	swap := uint(0)
+	for t := 254; t >= 0; t-- {
+		kt := uint(k[t/8]>>uint(t%8)) & 1
+		swap ^= kt
+		if swap == 1 {
+			x2, x3 = x3, x2
+			z2, z3 = z3, z2
+		}
+		swap = kt
+
+		a := mod(new(big.Int).Add(x2, z2))
+		aa := mod(new(big.Int).Mul(a, a))
+		b := mod(new(big.Int).Sub(x2, z2))
+		bb := mod(new(big.Int).Mul(b, b))
+		e := mod(new(big.Int).Sub(aa, bb))
+		c := mod(new(big.Int).Add(x3, z3))
+		d := mod(new(big.Int).Sub(x3, z3))
+		da := mod(new(big.Int).Mul(d, a))
+		cb := mod(new(big.Int).Mul(c, b))
+
+		x3 = mod(new(big.Int).Add(da, cb))
+		x3 = mod(x3.Mul(x3, x3))
+		z3 = mod(new(big.Int).Sub(da, cb))
+		z3 = mod(z3.Mul(z3, z3))
+		z3 = mod(z3.Mul(z3, x1))
+		x2 = mod(new(big.Int).Mul(aa, bb))
+		z2 = mod(new(big.Int).Mul(a24, e))
+		z2 = mod(z2.Add(z2, aa))
+		z2 = mod(z2.Mul(z2, e))
+	}
+	if swap == 1 {
+		x2, z2 = x3, z3
+	}
+
+	inverse := new(big.Int).Exp(z2, new(big.Int).Sub(p, big.NewInt(2)), p) //0 when z2 is, so the point at infinity comes out as 0
+	return encode(mod(new(big.Int).Mul(x2, inverse)))
+}
+
+func mod(x *big.Int) *big.Int {
+	return x.Mod(x, p) //never negative
+}
+
+func decode(b []byte) *big.Int { //little endian
+	reversed := make([]byte, len(b))
+	for i := range b {
+		reversed[len(b)-1-i] = b[i]
+	}
+	return new(big.Int).SetBytes(reversed)
+}
+
+func encode(x *big.Int) []byte {
+	out := make([]byte, Size)
+	be := x.Bytes()
+	for i := range be {
+		out[i] = be[len(be)-1-i]
+	}
+	return out
+}
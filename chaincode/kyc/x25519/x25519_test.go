@@ -0,0 +1,77 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package x25519
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestScalarMult(t *testing.T) { //the vectors of RFC 7748 section 5.2
+	cases := []struct {
+		scalar, point, want string
+	}{
+		{"a546e36bf0527c9d3b16154b82465edd62144c0ac1fc5a18506a2244ba449ac4", "e6db6867583030db3594c1a424b15f7c726624ec26b3353b10a903a6d0ab1c4c", "c3da55379de9c6908e94ea4df28d084f32eccf03491c71f754b4075577a28552"},
+		{"4b66e9d4d1b4673c5ad22691957d6af5c11b6421e0ea01d42ca4169e7918ba0d", "e5210f12786811d3f4b7959d0538ae2c31dbe7106fc03c3efc4cd549c715a493", "95cbde9476e8907d7aade45cb4b873f88b595a68799fa152e6f8f7647aac7957"},
+	}
+	for _, c := range cases {
+		if got := hex.EncodeToString(scalarMult(unhex(t, c.scalar), unhex(t, c.point))); got != c.want {
+			t.Errorf("X25519(%s, %s) = %s, expecting %s", c.scalar, c.point, got, c.want)
+		}
+	}
+}
+
+func TestECDH(t *testing.T) { //Alice and Bob of RFC 7748 section 6.1
+	alice, _ := NewPrivateKey(unhex(t, "77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a"))
+	bob, _ := NewPrivateKey(unhex(t, "5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb"))
+	if got := hex.EncodeToString(alice.PublicKey().Bytes()); got != "8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a" {
+		t.Fatalf("alice's public key %s", got)
+	}
+	if got := hex.EncodeToString(bob.PublicKey().Bytes()); got != "de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f" {
+		t.Fatalf("bob's public key %s", got)
+	}
+	shared, err := alice.ECDH(bob.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(shared); got != "4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742" {
+		t.Fatalf("shared secret %s", got)
+	}
+	back, _ := bob.ECDH(alice.PublicKey())
+	if hex.EncodeToString(back) != hex.EncodeToString(shared) {
+		t.Fatal("bob and alice do not agree")
+	}
+
+	if _, err = alice.ECDH(&PublicKey{point: make([]byte, Size)}); err == nil {
+		t.Fatal("a zero point gave a shared secret")
+	}
+	if _, err = NewPublicKey(make([]byte, 31)); err == nil {
+		t.Fatal("a short public key was taken")
+	}
+}
@@ -0,0 +1,371 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package xmldsig is just enough XML-DSig to check UIDAI's offline e-KYC files: one
+// enveloped signature over the whole document, RSA with SHA-1 or SHA-256, and canonical
+// XML 1.0 without comments. The canonicalisation covers what those files contain -
+// elements, attributes, namespace declarations and text. Comments are dropped, DTDs are
+// refused.
+package xmldsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	algC14N          = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+	algExcC14N       = "http://www.w3.org/2001/10/xml-exc-c14n#" //same output as algC14N for these files, the signature declares its namespace where it uses it
+	algEnveloped     = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	algRSASHA1       = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	algRSASHA256     = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	algSHA1          = "http://www.w3.org/2000/09/xmldsig#sha1"
+	algSHA256        = "http://www.w3.org/2001/04/xmlenc#sha256"
+	xmlNamespaceURI  = "http://www.w3.org/XML/1998/namespace"
+	xmlnsAttrPrefix  = "xmlns"
+	signatureElement = "Signature"
+)
+
+type Node struct { //an element or a run of text
+	Name     xml.Name //Space holds the prefix, not the namespace
+	Attrs    []xml.Attr
+	Children []*Node
+	Text     string //set on text nodes, which have no Name
+	IsText   bool
+	Parent   *Node
+}
+
+// ============================================================================================================================
+// Parse - read a document into nodes, keeping prefixes and attribute order as written
+// ============================================================================================================================
+func Parse(data []byte) (*Node, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var root, current *Node
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("Malformed XML: " + err.Error())
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			node := &Node{Name: tok.Name, Attrs: tok.Copy().Attr, Parent: current}
+			if current != nil {
+				current.Children = append(current.Children, node)
+			} else if root != nil {
+				return nil, errors.New("Malformed XML: more than one root element")
+			} else {
+				root = node
+			}
+			current = node
+		case xml.EndElement:
+			if current == nil || current.Name != tok.Name {
+				return nil, errors.New("Malformed XML: unexpected end element " + tok.Name.Local)
+			}
+			current = current.Parent
+		case xml.CharData:
+			if current != nil {
+				current.Children = append(current.Children, &Node{Text: string(tok), IsText: true, Parent: current})
+			}
+		case xml.Directive:
+			return nil, errors.New("Malformed XML: DTDs are not accepted")
+		}
+	}
+	if root == nil || current != nil {
+		return nil, errors.New("Malformed XML: document is incomplete")
+	}
+	return root, nil
+}
+
+func (n *Node) Child(local string) *Node {
+	for _, c := range n.Children {
+		if !c.IsText && c.Name.Local == local {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *Node) children(local string) []*Node {
+	var found []*Node
+	for _, c := range n.Children {
+		if !c.IsText && c.Name.Local == local {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+func (n *Node) Attr(local string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Space == "" && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n *Node) text() string {
+	var buf bytes.Buffer
+	for _, c := range n.Children {
+		if c.IsText {
+			buf.WriteString(c.Text)
+		}
+	}
+	return buf.String()
+}
+
+// namespace declarations made on this element, keyed by prefix, "" for the default namespace
+func (n *Node) nsDecls() map[string]string {
+	decls := map[string]string{}
+	for _, a := range n.Attrs {
+		if a.Name.Space == "" && a.Name.Local == xmlnsAttrPrefix {
+			decls[""] = a.Value
+		} else if a.Name.Space == xmlnsAttrPrefix {
+			decls[a.Name.Local] = a.Value
+		}
+	}
+	return decls
+}
+
+func (n *Node) lookupNamespace(prefix string) string {
+	if prefix == "xml" {
+		return xmlNamespaceURI
+	}
+	for e := n; e != nil; e = e.Parent {
+		if uri, ok := e.nsDecls()[prefix]; ok {
+			return uri
+		}
+	}
+	return ""
+}
+
+// ============================================================================================================================
+// Canonicalize - canonical XML 1.0 of an element and its descendants, skip is left out (the enveloped signature)
+// ============================================================================================================================
+func Canonicalize(n *Node, skip *Node) []byte {
+	scope := map[string]string{} //the apex also carries the namespaces it inherits
+	var chain []*Node
+	for e := n; e != nil; e = e.Parent {
+		chain = append(chain, e)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for prefix, uri := range chain[i].nsDecls() {
+			scope[prefix] = uri
+		}
+	}
+
+	var buf bytes.Buffer
+	writeCanonical(&buf, n, skip, scope, map[string]string{"": ""})
+	return buf.Bytes()
+}
+
+type byNamespace struct { //sort.SliceStable needs Go 1.8, the peers build chaincode with 1.7
+	n     *Node
+	attrs []xml.Attr
+}
+
+func (b byNamespace) Len() int      { return len(b.attrs) }
+func (b byNamespace) Swap(i, j int) { b.attrs[i], b.attrs[j] = b.attrs[j], b.attrs[i] }
+func (b byNamespace) Less(i, j int) bool {
+	ui, uj := "", ""
+	if b.attrs[i].Name.Space != "" {
+		ui = b.n.lookupNamespace(b.attrs[i].Name.Space)
+	}
+	if b.attrs[j].Name.Space != "" {
+		uj = b.n.lookupNamespace(b.attrs[j].Name.Space)
+	}
+	if ui != uj {
+		return ui < uj
+	}
+	return b.attrs[i].Name.Local < b.attrs[j].Name.Local
+}
+
+func writeCanonical(buf *bytes.Buffer, n *Node, skip *Node, decls map[string]string, rendered map[string]string) {
+	if n.IsText {
+		buf.WriteString(escapeC14NText(n.Text))
+		return
+	}
+	if n == skip {
+		return
+	}
+
+	var prefixes []string
+	inScope := map[string]string{}
+	for prefix, uri := range rendered {
+		inScope[prefix] = uri
+	}
+	for prefix, uri := range decls {
+		if rendered[prefix] != uri {
+			prefixes = append(prefixes, prefix)
+			inScope[prefix] = uri
+		}
+	}
+	sort.Strings(prefixes) //default namespace sorts first
+
+	var attrs []xml.Attr
+	for _, a := range n.Attrs {
+		if (a.Name.Space == "" && a.Name.Local == xmlnsAttrPrefix) || a.Name.Space == xmlnsAttrPrefix {
+			continue
+		}
+		attrs = append(attrs, a)
+	}
+	sort.Stable(byNamespace{n, attrs}) //by namespace uri, then local name
+
+	name := qualifiedName(n.Name)
+	buf.WriteString("<" + name)
+	for _, prefix := range prefixes {
+		if prefix == "" {
+			buf.WriteString(` xmlns="` + escapeC14NAttr(decls[prefix]) + `"`)
+		} else {
+			buf.WriteString(` xmlns:` + prefix + `="` + escapeC14NAttr(decls[prefix]) + `"`)
+		}
+	}
+	for _, a := range attrs {
+		buf.WriteString(" " + qualifiedName(a.Name) + `="` + escapeC14NAttr(a.Value) + `"`)
+	}
+	buf.WriteString(">")
+	for _, c := range n.Children {
+		writeCanonical(buf, c, skip, c.nsDecls(), inScope)
+	}
+	buf.WriteString("</" + name + ">")
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+var c14nTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+var c14nAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+
+func escapeC14NText(s string) string { return c14nTextEscaper.Replace(s) }
+func escapeC14NAttr(s string) string { return c14nAttrEscaper.Replace(s) }
+
+func isC14NAlgorithm(alg string) bool {
+	return alg == algC14N || alg == algExcC14N
+}
+
+func decodeBase64Text(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), "")) //signatures are often wrapped over several lines
+}
+
+// ============================================================================================================================
+// Verify - check the enveloped signature of a document against a certificate, returns the document root
+// ============================================================================================================================
+func Verify(data []byte, cert *x509.Certificate) (*Node, error) {
+	root, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	sig := root.Child(signatureElement)
+	if sig == nil {
+		return nil, errors.New("XML is not signed")
+	}
+	signedInfo := sig.Child("SignedInfo")
+	sigValue := sig.Child("SignatureValue")
+	if signedInfo == nil || sigValue == nil {
+		return nil, errors.New("XML signature is missing SignedInfo or SignatureValue")
+	}
+
+	c14n := signedInfo.Child("CanonicalizationMethod")
+	if c14n == nil || !isC14NAlgorithm(c14n.Attr("Algorithm")) {
+		return nil, errors.New("Unsupported XML canonicalization method")
+	}
+	var sigHash crypto.Hash
+	if method := signedInfo.Child("SignatureMethod"); method != nil {
+		switch method.Attr("Algorithm") {
+		case algRSASHA1:
+			sigHash = crypto.SHA1
+		case algRSASHA256:
+			sigHash = crypto.SHA256
+		}
+	}
+	if sigHash == 0 {
+		return nil, errors.New("Unsupported XML signature method, expecting RSA with SHA-1 or SHA-256")
+	}
+
+	refs := signedInfo.children("Reference")
+	if len(refs) != 1 || refs[0].Attr("URI") != "" {
+		return nil, errors.New("XML signature must have a single reference to the whole document")
+	}
+	enveloped := false
+	if transforms := refs[0].Child("Transforms"); transforms != nil {
+		for _, tr := range transforms.children("Transform") {
+			alg := tr.Attr("Algorithm")
+			if alg == algEnveloped {
+				enveloped = true
+			} else if !isC14NAlgorithm(alg) {
+				return nil, errors.New("Unsupported XML signature transform " + alg)
+			}
+		}
+	}
+	if !enveloped {
+		return nil, errors.New("XML signature must be an enveloped signature")
+	}
+
+	var digest []byte
+	canonicalDoc := Canonicalize(root, sig)
+	if method := refs[0].Child("DigestMethod"); method != nil && method.Attr("Algorithm") == algSHA1 {
+		sum := sha1.Sum(canonicalDoc)
+		digest = sum[:]
+	} else if method != nil && method.Attr("Algorithm") == algSHA256 {
+		sum := sha256.Sum256(canonicalDoc)
+		digest = sum[:]
+	} else {
+		return nil, errors.New("Unsupported XML digest method, expecting SHA-1 or SHA-256")
+	}
+	digestValue := refs[0].Child("DigestValue")
+	if digestValue == nil {
+		return nil, errors.New("XML signature reference has no DigestValue")
+	}
+	expected, err := decodeBase64Text(digestValue.text())
+	if err != nil || !bytes.Equal(expected, digest) {
+		return nil, errors.New("XML digest does not match, the document was changed after signing")
+	}
+
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("Signing certificate does not hold an RSA key")
+	}
+	signature, err := decodeBase64Text(sigValue.text())
+	if err != nil {
+		return nil, errors.New("XML SignatureValue is not base64")
+	}
+	h := sigHash.New()
+	h.Write(Canonicalize(signedInfo, nil))
+	if err = rsa.VerifyPKCS1v15(pub, sigHash, h.Sum(nil), signature); err != nil {
+		return nil, errors.New("XML signature does not verify against the configured certificate")
+	}
+	return root, nil
+}
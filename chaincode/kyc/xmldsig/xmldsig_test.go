@@ -0,0 +1,87 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package xmldsig
+
+import (
+	"strings"
+	"testing"
+)
+
+const c14nInput = `<?xml version="1.0"?>
+<a:r xmlns:a="urn:a" xmlns="urn:d" z="1" a:y="2&#9;x" b='q"'><!-- c --><b  xmlns:c="urn:c" c:k="v">t&gt;x&#13;</b><e/>
+<Signature xmlns="urn:s"><SignedInfo x="1"/></Signature></a:r>`
+
+func TestCanonicalize(t *testing.T) {
+	root, err := Parse([]byte(c14nInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name    string
+		node    *Node
+		exclude *Node
+		want    string
+	}{
+		{"enveloped document", root, root.Child("Signature"),
+			"<a:r xmlns=\"urn:d\" xmlns:a=\"urn:a\" b=\"q&quot;\" z=\"1\" a:y=\"2&#x9;x\"><b xmlns:c=\"urn:c\" c:k=\"v\">t&gt;x&#xD;</b><e></e>\n</a:r>"},
+		{"subset inherits namespaces", root.Child("Signature").Child("SignedInfo"), nil,
+			"<SignedInfo xmlns=\"urn:s\" xmlns:a=\"urn:a\" x=\"1\"></SignedInfo>"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := string(Canonicalize(c.node, c.exclude)); got != c.want {
+				t.Fatalf("got %q\nexpecting %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name string
+		xml  string
+		want string
+	}{
+		{"well formed", "<a><b/></a>", ""},
+		{"doctype", `<!DOCTYPE a [<!ENTITY x "y">]><a>&x;</a>`, "DTDs are not accepted"},
+		{"two roots", "<a/><b/>", "more than one root element"},
+		{"unclosed", "<a><b></a>", "Malformed XML"},
+		{"incomplete", "<a>", "Malformed XML"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Parse([]byte(c.xml))
+			expectError(t, err, c.want)
+		})
+	}
+}
+
+func expectError(t *testing.T, err error, want string) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected an error containing %q, got %v", want, err)
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+)
+
+func TestTransitions(t *testing.T) {
+	cases := []struct {
+		from   string
+		action string
+		args   []string //after the aadhar number
+		to     string   //empty when the transition is refused
+		want   string
+	}{
+		{model.StatusPending, "verify", nil, model.StatusVerified, ""},
+		{model.StatusPending, "suspend", []string{"x"}, "", "cannot suspend a KYC record that is pending"},
+		{model.StatusPending, "revoke", []string{"x"}, model.StatusRevoked, ""},
+		{model.StatusVerified, "verify", nil, "", "KYC record is already verified"},
+		{model.StatusVerified, "suspend", []string{"under review"}, model.StatusSuspended, ""},
+		{model.StatusVerified, "suspend", nil, "", "Invalid reason: required to suspend"},
+		{model.StatusVerified, "reinstate", nil, "", "KYC record is already verified"},
+		{model.StatusVerified, "expire", nil, model.StatusExpired, ""},
+		{model.StatusVerified, "renew", nil, model.StatusVerified, ""},
+		{model.StatusVerified, "revoke", nil, "", "Invalid reason: required to revoke"},
+		{model.StatusSuspended, "reinstate", nil, model.StatusVerified, ""},
+		{model.StatusSuspended, "expire", nil, model.StatusExpired, ""},
+		{model.StatusSuspended, "renew", nil, "", "renew only applies to verified or expired records"},
+		{model.StatusExpired, "renew", []string{model.RiskLow}, model.StatusVerified, ""},
+		{model.StatusExpired, "reinstate", nil, "", "cannot reinstate a KYC record that is expired"},
+		{model.StatusExpired, "revoke", []string{"x"}, model.StatusRevoked, ""},
+		{model.StatusRevoked, "verify", nil, "", "cannot verify a KYC record that is revoked"},
+		{model.StatusRevoked, "renew", nil, "", "cannot renew a KYC record that is revoked"},
+		{model.StatusRevoked, "revoke", []string{"x"}, "", "KYC record is already revoked"},
+	}
+	for _, c := range cases {
+		t.Run(c.from+" "+c.action, func(t *testing.T) {
+			s := newFixture(t)
+			s.createKYC(t, kycSpec{Aadhaar: aadhaarB, Status: c.from})
+			if access.InvokePolicy[c.action][0] == access.RoleBank {
+				s.asBank(bankSBI)
+			} else {
+				s.asRegulator()
+			}
+			s.advance(days(1))
+			_, err := s.invoke(c.action, append([]string{aadhaarB}, c.args...)...)
+			expectError(t, err, c.want)
+			rec := s.record(t, aadhaarB)
+			if c.want != "" {
+				if rec.Status != c.from {
+					t.Fatalf("refused %s moved the record to %s", c.action, rec.Status)
+				}
+				return
+			}
+			if rec.Status != c.to || rec.UpdatedAt != s.nowMs() {
+				t.Fatalf("record is %s updated at %d, expecting %s at %d", rec.Status, rec.UpdatedAt, c.to, s.nowMs())
+			}
+			if (c.action == "verify" || c.action == "renew") && rec.VerifiedAt != s.nowMs() {
+				t.Fatalf("%s did not set verifiedAt", c.action)
+			}
+			wantEvent := model.EventKYCStatusChanged
+			switch {
+			case c.to == model.StatusRevoked:
+				wantEvent = model.EventKYCRevoked
+			case c.action == "renew":
+				wantEvent = model.EventKYCUpdated
+			}
+			if event := s.lastEvent(t); event.Name != wantEvent || event.Payload.Status != c.to {
+				t.Fatalf("unexpected event %+v, expecting %s", event, wantEvent)
+			}
+		})
+	}
+}
+
+func TestTransitionsOnlyByTheVerifyingBank(t *testing.T) {
+	s := newFixture(t)
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarB, Status: model.StatusPending})
+	s.asBank(bankHDFC)
+	for _, action := range []string{"verify", "suspend", "renew", "setRisk"} {
+		_, err := s.invoke(action, aadhaarB, model.RiskLow)
+		expectError(t, err, "Permission denied: only "+bankSBI)
+	}
+	_, err := s.invoke("verify", aadhaarD)
+	expectError(t, err, "No KYC record")
+	_, err = s.invoke("verify")
+	expectError(t, err, "Expecting 1 or 2")
+}
+
+func TestSubmit(t *testing.T) {
+	cases := []struct {
+		name   string
+		from   string //status of the existing record, empty for none
+		caller string
+		want   string
+	}{
+		{"new customer", "", bankSBI, ""},
+		{"expired customer", model.StatusExpired, bankSBI, ""},
+		{"expired at another bank", model.StatusExpired, bankHDFC, "only " + bankSBI + " can resubmit"},
+		{"verified customer", model.StatusVerified, bankSBI, "cannot submit a KYC record that is verified"},
+		{"pending customer", model.StatusPending, bankSBI, "cannot submit a KYC record that is pending"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newFixture(t)
+			if c.from != "" {
+				s.createKYC(t, kycSpec{Aadhaar: aadhaarB, Status: c.from})
+			}
+			s.asBank(c.caller)
+			_, err := s.invoke("submit", aadhaarB, "", model.LevelOTP, "passport:K7654321")
+			expectError(t, err, c.want)
+			if c.want != "" {
+				return
+			}
+			rec := s.record(t, aadhaarB)
+			if rec.Status != model.StatusPending || rec.Level != model.LevelOTP || rec.Institution != bankSBI {
+				t.Fatalf("unexpected record %+v", rec)
+			}
+		})
+	}
+
+	s := newFixture(t)
+	s.asBank(bankSBI)
+	_, err := s.invoke("submit", aadhaarB, "")
+	expectError(t, err, "Expecting at least 3")
+	_, err = s.invoke("submit", aadhaarB, "NOTAPAN", model.LevelFull)
+	expectError(t, err, "Invalid pan")
+	_, err = s.invoke("submit", aadhaarB, "", model.LevelOffline)
+	expectError(t, err, "only be created from a signed file")
+}
@@ -0,0 +1,799 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/handlers"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+)
+
+func TestInit(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"asset holding only", []string{"1"}, ""},
+		{"reset flag", []string{"1", "false"}, ""},
+		{"retention", []string{"1", "true", "30"}, ""},
+		{"no arguments", []string{}, "Expecting 1 to 3"},
+		{"too many arguments", []string{"1", "true", "30", "x"}, "Expecting 1 to 3"},
+		{"secret as an argument", []string{"1", "true", testSecret}, "Invalid retentionDays"},
+		{"asset holding not a number", []string{"one"}, "Invalid assetHolding: must be a whole number"},
+		{"reset flag not a bool", []string{"1", "maybe"}, "Invalid allowReset: must be true or false"},
+		{"retention not a number", []string{"1", "true", "forever"}, "Invalid retentionDays"},
+		{"retention zero", []string{"1", "true", "0"}, "Invalid retentionDays"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newBareStub()
+			_, err := s.init(c.args...)
+			expectError(t, err, c.want)
+		})
+	}
+}
+
+func TestInitKeepsState(t *testing.T) {
+	s := newFixture(t)
+	_, err := s.init("2", "false")
+	expectError(t, err, "")
+	if rec := s.record(t, aadhaarA); rec == nil || rec.Institution != bankSBI {
+		t.Fatalf("re-running Init lost the KYC record: %+v", rec)
+	}
+	var banks []model.Bank
+	decodeJSON(t, s.mustQuery(t, "readAll"), &banks)
+	if len(banks) != 2 {
+		t.Fatalf("re-running Init lost the banks, got %d", len(banks))
+	}
+	config, _ := storage.GetConfig(s)
+	if config.AllowReset {
+		t.Fatal("Init did not turn reset off")
+	}
+
+	s.secret = []byte("fedcba9876543210fedcba9876543210")
+	_, err = s.init("1", "false")
+	expectError(t, err, "cannot be changed")
+}
+
+func TestAadhaarSecret(t *testing.T) {
+	s := newBareStub()
+	s.secret = []byte("too short")
+	_, err := s.init("1")
+	expectError(t, err, "at least 32 random bytes")
+
+	s = newFixture(t)
+	for key, value := range s.MockStub.State {
+		if strings.Contains(string(value), testSecret) {
+			t.Fatalf("the secret is in world state under %q", key)
+		}
+	}
+
+	s.asBank(bankSBI)
+	s.secret = nil
+	_, err = s.query("read", aadhaarA)
+	expectCode(t, err, model.CodeInvalidArgument)
+	expectError(t, err, "queries may pass the number with the aadhaar pseudonymisation secret as aadhaarSecret")
+	s.secret = []byte("fedcba9876543210fedcba9876543210")
+	_, err = s.query("read", aadhaarA)
+	expectError(t, err, "not the one the ledger was set up with")
+
+	s.secret = []byte(testSecret)
+	ref := s.ref(t, aadhaarA)
+	s.secret = nil //the reference token needs no secret, and keeps the number out of the transaction
+	var rec model.KYCRecord
+	decodeJSON(t, s.mustQuery(t, "read", ref), &rec)
+	if rec.Subject.AadharRef != ref {
+		t.Fatalf("read %+v, expecting the record of %s", rec, ref)
+	}
+	s.mustInvoke(t, "setRisk", ref, model.RiskHigh)
+	_, err = s.query("read", strings.ToUpper(ref))
+	expectError(t, err, "Invalid aadharNum: must be 12 digits")
+
+	_, err = s.invokeAsIs("setRisk", aadhaarA, model.RiskHigh) //invokes are kept, the number is refused
+	expectError(t, err, "Pass the customer's reference token in place of the aadhar number")
+	s.secret = []byte(testSecret) //and so is the secret, even with a token
+	_, err = s.invokeAsIs("setRisk", ref, model.RiskHigh)
+	if e := expectCode(t, err, model.CodeInvalidArgument); e.Field != storage.TransientSecret {
+		t.Fatalf("error names %q, expecting %q", e.Field, storage.TransientSecret)
+	}
+	s.asRegulator()
+	_, err = s.invokeAsIs("init", "1") //only the deploy's Init takes it
+	expectError(t, err, "only queries and the deploy take it")
+	if _, err = s.init("1"); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	s = newBareStub() //the ledger is not set up until a secret is passed
+	s.secret = nil
+	s.init("1")
+	s.createBank(t, bankSpec{Code: bankSBI})
+	s.asBank(bankSBI)
+	s.secret = []byte(testSecret)
+	_, err = s.invoke("init_marble", aadhaarA, "", model.LevelOTP, bankSBI)
+	expectCode(t, err, model.CodeFailedPrecondition)
+}
+
+func TestStoredSecretRetired(t *testing.T) {
+	s := newBareStub()
+	s.secret = nil
+	s.init("1")
+	s.MockTransactionStart("earlier build")
+	s.PutState("_aadhaarsecret", []byte(testSecret))
+	s.MockTransactionEnd("earlier build")
+
+	s.mustInvoke(t, "init", "1")
+	if _, ok := s.MockStub.State["_aadhaarsecret"]; ok {
+		t.Fatal("Init left the secret in world state")
+	}
+	s.secret = []byte(testSecret) //the ledger carries on with the same secret
+	s.createBank(t, bankSpec{Code: bankSBI})
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarA})
+	s.secret = []byte("fedcba9876543210fedcba9876543210")
+	_, err := s.query("read", aadhaarA)
+	expectError(t, err, "not the one the ledger was set up with")
+}
+
+func TestInitMigratesBaselineRecords(t *testing.T) {
+	s := newBaselineStub()
+	if _, err := s.init("1"); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	for _, c := range []struct {
+		aadharNum  string
+		bank       string
+		verifiedAt int64
+	}{
+		{aadhaarA, bankSBI, 1467000000000}, //init_marble
+		{aadhaarB, bankHDFC, s.nowMs()},    //set_user left no timestamp, verified as of the migration
+		{aadhaarC, bankSBI, 1467000000000}, //Write
+	} {
+		if _, ok := s.MockStub.State[c.aadharNum]; ok {
+			t.Fatalf("Init left %s in world state", c.aadharNum)
+		}
+		rec := s.record(t, c.aadharNum)
+		if rec == nil || rec.Institution != c.bank || rec.Level != model.LevelMinimum || rec.Status != model.StatusVerified || rec.VerifiedAt != c.verifiedAt {
+			t.Fatalf("%s migrated to %+v", c.aadharNum, rec)
+		}
+	}
+	if _, ok := s.MockStub.State[storage.LegacyIndexKey]; ok {
+		t.Fatal("Init kept the legacy index")
+	}
+	if _, ok := s.MockStub.State["asdf"]; !ok {
+		t.Fatal("Init removed a marble that is not a KYC record")
+	}
+
+	s.asRegulator()
+	s.mustInvoke(t, "revoke", aadhaarA, "forged documents") //the migrated records take part like any other
+	if _, err := s.init("1"); err != nil {                  //nothing left to migrate
+		t.Fatalf("Init: %v", err)
+	}
+}
+
+// every invoke function with arguments that succeed from the fixture, setup may prepare state and return the arguments
+var invokeRoutes = []struct {
+	function string
+	caller   func(s *testStub)
+	args     func(t *testing.T, s *testStub) []string
+}{
+	{"init", (*testStub).asRegulator, fixed("1")},
+	{"reset", (*testStub).asRegulator, fixed("clean slate")},
+	{"delete", (*testStub).asRegulator, fixed(aadhaarA, "customer left")},
+	{"write", bank(bankSBI), fixed(aadhaarA, bankSBI)},
+	{"writeBank", (*testStub).asRegulator, fixed("ICIC0000001", "ICICI Bank", "RBI/3", "kyc@icici.example")},
+	{"updateBank", (*testStub).asRegulator, fixed(bankHDFC, "HDFC Bank Ltd", "RBI/2", "kyc@hdfc.example")},
+	{"deactivateBank", (*testStub).asRegulator, fixed(bankHDFC)},
+	{"init_marble", bank(bankSBI), fixed(aadhaarB, "", model.LevelOTP, bankSBI)},
+	{"set_user", bank(bankSBI), fixed(aadhaarA, bankHDFC)},
+	{"requestKYC", bank(bankHDFC), fixed(aadhaarA, model.PurposeLoan, "30")},
+	{"approveRequest", customer(aadhaarA), requestFromHDFC},
+	{"rejectRequest", customer(aadhaarA), requestFromHDFC},
+	{"grantConsent", bank(bankSBI), fixed(aadhaarA, bankHDFC, model.PurposeLoan, "30")},
+	{"revokeConsent", bank(bankSBI), func(t *testing.T, s *testStub) []string {
+		s.as(func() { s.asBank(bankSBI) }, func() { s.mustInvoke(t, "grantConsent", aadhaarA, bankHDFC, model.PurposeLoan, "30") })
+		return []string{aadhaarA, bankHDFC, model.PurposeLoan}
+	}},
+	{"submit", bank(bankSBI), fixed(aadhaarB, "", model.LevelFull)},
+	{"verify", bank(bankSBI), func(t *testing.T, s *testStub) []string {
+		s.createKYC(t, kycSpec{Aadhaar: aadhaarB, Status: model.StatusPending})
+		return []string{aadhaarB}
+	}},
+	{"suspend", bank(bankSBI), fixed(aadhaarA, "documents under review")},
+	{"reinstate", (*testStub).asRegulator, func(t *testing.T, s *testStub) []string {
+		s.as(func() { s.asBank(bankSBI) }, func() { s.mustInvoke(t, "suspend", aadhaarA, "documents under review") })
+		return []string{aadhaarA}
+	}},
+	{"revoke", (*testStub).asRegulator, fixed(aadhaarA, "forged documents")},
+	{"expire", (*testStub).asRegulator, fixed(aadhaarA)},
+	{"renew", bank(bankSBI), fixed(aadhaarA, model.RiskLow)},
+	{"setRisk", bank(bankSBI), fixed(aadhaarA, model.RiskMedium)},
+	{"setDetails", bank(bankSBI), fixed(aadhaarA, "Asha Rao", "1990-01-01")},
+	{"purge", (*testStub).asRegulator, func(t *testing.T, s *testStub) []string {
+		s.as(s.asRegulator, func() { s.mustInvoke(t, "delete", aadhaarA, "customer left") })
+		s.advance(days(model.DefaultRetentionDays + 1))
+		return []string{aadhaarA}
+	}},
+	{"attachDocument", bank(bankSBI), fixed(aadhaarA, model.DocPANCard, strings.Repeat("ab", 32), "Income Tax Department", "", "https://docs.example/pan")},
+	{"setUIDAICertificate", (*testStub).asRegulator, func(t *testing.T, s *testStub) []string {
+		return []string{newUIDAISigner(t).certPEM}
+	}},
+	{"onboardOfflineKYC", bank(bankSBI), func(t *testing.T, s *testStub) []string {
+		signer := newUIDAISigner(t)
+		signer.install(t, s)
+		signer.passOfflineKYC(t, s, offlineKYCSpec{ReferenceID: "0006" + "20251231235959000"})
+		return []string{aadhaarC, "0006"}
+	}},
+	{"rotateKey", bank(bankSBI), func(t *testing.T, s *testStub) []string {
+		oldKey, newKey := make([]byte, 32), make([]byte, 32)
+		rand.Read(oldKey)
+		rand.Read(newKey)
+		s.withKeys(map[string][]byte{storage.TransientKey: oldKey, storage.TransientNewKey: newKey})
+		return []string{}
+	}},
+	{"setShareKey", bank(bankSBI), fixed()},
+	{"shareDetails", bank(bankSBI), func(t *testing.T, s *testStub) []string {
+		s.as(func() { s.asCustomer(aadhaarA) }, func() { s.mustInvoke(t, "grantConsent", aadhaarA, bankHDFC, model.PurposeLoan, "30") })
+		return []string{aadhaarA}
+	}},
+	{"dropExpiredShares", (*testStub).asRegulator, fixed()},
+}
+
+// every query function with arguments that succeed from the fixture
+var queryRoutes = []struct {
+	function string
+	caller   func(s *testStub)
+	args     func(t *testing.T, s *testStub) []string
+}{
+	{"read", (*testStub).asRegulator, fixed(aadhaarA)},
+	{"readBank", customer(aadhaarA), fixed(bankSBI)},
+	{"readAll", (*testStub).asAuditor, fixed()},
+	{"readConsents", customer(aadhaarA), fixed(aadhaarA)},
+	{"readShareRequests", (*testStub).asRegulator, requestFromHDFC},
+	{"history", (*testStub).asAuditor, fixed(aadhaarA)},
+	{"list", bank(bankSBI), fixed("10", "")},
+	{"dueForReview", (*testStub).asRegulator, fixed(bankSBI, "2030-01-01")},
+	{"readClosed", (*testStub).asAuditor, func(t *testing.T, s *testStub) []string {
+		s.as(s.asRegulator, func() { s.mustInvoke(t, "delete", aadhaarA, "customer left") })
+		return []string{aadhaarA}
+	}},
+	{"verifyDocument", customer(aadhaarA), fixed(strings.Repeat("ab", 32))},
+	{"disclose", bank(bankSBI), fixed(aadhaarA, model.PurposeAccountOpening)},
+	{"readProfiles", bank(bankHDFC), fixed()},
+	{"describe", customer(aadhaarA), fixed()},
+}
+
+func fixed(args ...string) func(*testing.T, *testStub) []string {
+	return func(*testing.T, *testStub) []string { return args }
+}
+
+func bank(code string) func(s *testStub) {
+	return func(s *testStub) { s.asBank(code) }
+}
+
+func customer(aadharNum string) func(s *testStub) {
+	return func(s *testStub) { s.asCustomer(aadharNum) }
+}
+
+// requestFromHDFC has HDFC ask for aadhaarA's KYC, the argument is the request id for approve and reject and the
+// aadhar number for readShareRequests
+func requestFromHDFC(t *testing.T, s *testStub) []string {
+	var id []byte
+	s.as(func() { s.asBank(bankHDFC) }, func() { id = s.mustInvoke(t, "requestKYC", aadhaarA, model.PurposeLoan, "30") })
+	if strings.HasPrefix(t.Name(), "TestQueryRoutes") {
+		return []string{aadhaarA}
+	}
+	return []string{string(id)}
+}
+
+func TestInvokeRoutes(t *testing.T) {
+	for _, route := range invokeRoutes {
+		t.Run(route.function, func(t *testing.T) {
+			s := newFixture(t)
+			args := route.args(t, s)
+			route.caller(s)
+			_, err := s.invoke(route.function, args...)
+			expectError(t, err, "")
+		})
+	}
+}
+
+func TestQueryRoutes(t *testing.T) {
+	for _, route := range queryRoutes {
+		t.Run(route.function, func(t *testing.T) {
+			s := newFixture(t)
+			args := route.args(t, s)
+			route.caller(s)
+			out, err := s.query(route.function, args...)
+			expectError(t, err, "")
+			if !json.Valid(out) {
+				t.Fatalf("%s returned invalid JSON: %s", route.function, out)
+			}
+		})
+	}
+}
+
+func TestRoutesCoverPolicies(t *testing.T) {
+	covered := map[string]bool{}
+	for _, route := range invokeRoutes {
+		covered["invoke "+route.function] = true
+	}
+	for _, route := range queryRoutes {
+		covered["query "+route.function] = true
+	}
+	for function := range access.InvokePolicy {
+		if !covered["invoke "+function] {
+			t.Errorf("invoke %s has no route test", function)
+		}
+	}
+	for function := range access.QueryPolicy {
+		if !covered["query "+function] {
+			t.Errorf("query %s has no route test", function)
+		}
+	}
+}
+
+func TestUnknownFunction(t *testing.T) {
+	s := newFixture(t)
+	access.InvokePolicy["bogus"] = []string{access.RoleRegulator} //get past authorization to reach the dispatcher
+	access.QueryPolicy["bogus"] = []string{access.RoleRegulator}
+	defer delete(access.InvokePolicy, "bogus")
+	defer delete(access.QueryPolicy, "bogus")
+
+	_, err := s.invoke("bogus")
+	expectError(t, err, "Received unknown function invocation")
+	_, err = s.query("bogus")
+	expectError(t, err, "Received unknown function query")
+	_, err = s.invoke("notAFunction")
+	expectError(t, err, "no access policy")
+}
+
+func TestDescribe(t *testing.T) {
+	s := newFixture(t)
+	s.asCustomer(aadhaarA)
+	var description handlers.Description
+	decodeJSON(t, s.mustQuery(t, "describe"), &description)
+	if description.Version != handlers.ChaincodeVersion {
+		t.Fatalf("described version %q, expecting %q", description.Version, handlers.ChaincodeVersion)
+	}
+	described := map[string]handlers.Function{}
+	for _, fn := range description.Invoke {
+		described["invoke "+fn.Name] = fn
+	}
+	for _, fn := range description.Query {
+		described["query "+fn.Name] = fn
+	}
+	for kind, policy := range map[string]map[string][]string{"invoke": access.InvokePolicy, "query": access.QueryPolicy} {
+		for function := range policy {
+			fn, ok := described[kind+" "+function]
+			if !ok || fn.Signature == "" || len(fn.Roles) == 0 {
+				t.Errorf("%s %s is not described: %+v", kind, function, fn)
+			}
+		}
+	}
+	if sig := described["invoke grantConsent"].Signature; sig != "grantConsent(aadharNum, bank, purpose, days)" {
+		t.Fatalf("grantConsent described as %q", sig)
+	}
+	if sig := described["query list"].Signature; sig != "list([pageSize], [bookmark], filters...)" {
+		t.Fatalf("list described as %q", sig)
+	}
+
+	_, err := s.invoke("grantConsent", aadhaarA, bankHDFC)
+	expectError(t, err, "Expecting 4: grantConsent(aadharNum, bank, purpose, days)")
+	_, err = s.invoke("grantConsent", aadhaarA, bankHDFC, model.PurposeLoan, "a month")
+	expectError(t, err, "Invalid days: must be a whole number")
+}
+
+func TestErrorCodes(t *testing.T) {
+	cases := []struct {
+		name    string
+		call    func(t *testing.T, s *testStub) error
+		code    string
+		field   string
+		details map[string]string
+	}{
+		{"bad argument", func(t *testing.T, s *testStub) error {
+			s.asCustomer(aadhaarA)
+			_, err := s.invoke("grantConsent", aadhaarA, bankHDFC, model.PurposeLoan, "a month")
+			return err
+		}, model.CodeInvalidArgument, "days", nil},
+		{"argument count", func(t *testing.T, s *testStub) error {
+			_, err := s.query("read")
+			return err
+		}, model.CodeInvalidArgument, "args", map[string]string{"expecting": "1 or 2", "signature": "read(aadharNum, [purpose])"}},
+		{"no record", func(t *testing.T, s *testStub) error {
+			_, err := s.query("read", aadhaarB)
+			return err
+		}, model.CodeNotFound, "", nil},
+		{"record exists", func(t *testing.T, s *testStub) error {
+			s.asBank(bankSBI)
+			_, err := s.invoke("init_marble", aadhaarA, "", model.LevelOTP, bankSBI)
+			return err
+		}, model.CodeAlreadyExists, "", nil},
+		{"wrong role", func(t *testing.T, s *testStub) error {
+			s.asCustomer(aadhaarA)
+			_, err := s.invoke("writeBank", "ICIC0000001", "ICICI Bank", "LIC-3", "ops@icici.example")
+			return err
+		}, model.CodePermissionDenied, "", nil},
+		{"illegal transition", func(t *testing.T, s *testStub) error {
+			s.asBank(bankSBI)
+			_, err := s.invoke("verify", aadhaarA)
+			return err
+		}, model.CodeConflict, "", map[string]string{"status": model.StatusVerified}},
+		{"still retained", func(t *testing.T, s *testStub) error {
+			s.mustInvoke(t, "delete", aadhaarA, "customer left")
+			_, err := s.invoke("purge", aadhaarA)
+			return err
+		}, model.CodeFailedPrecondition, "", map[string]string{"retainedUntil": testEpoch.Add(days(model.DefaultRetentionDays)).UTC().Format(model.ReviewDateLayout)}},
+		{"malformed ledger", func(t *testing.T, s *testStub) error {
+			s.MockStub.State[storage.KYCKey(s.ref(t, aadhaarA))] = []byte("{")
+			_, err := s.query("read", aadhaarA)
+			return err
+		}, model.CodeInternal, "", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newFixture(t)
+			e := expectCode(t, c.call(t, s), c.code)
+			if e.Message == "" || e.Field != c.field {
+				t.Fatalf("unexpected error %+v", e)
+			}
+			for key, value := range c.details {
+				if e.Details[key] != value {
+					t.Fatalf("detail %s is %q, expecting %q in %+v", key, e.Details[key], value, e)
+				}
+			}
+		})
+	}
+}
+
+func TestNamedArguments(t *testing.T) {
+	cases := []struct {
+		name     string
+		caller   func(s *testStub)
+		function string
+		object   string
+		want     string
+	}{
+		{"numbers as numbers", customer(aadhaarA), "grantConsent", `{"aadharNum": "` + aadhaarA + `", "bank": "` + bankHDFC + `", "purpose": "loan", "days": 30}`, ""},
+		{"numbers as strings", customer(aadhaarA), "grantConsent", `{"days": "30", "purpose": "loan", "bank": "` + bankHDFC + `", "aadharNum": "` + aadhaarA + `"}`, ""},
+		{"repeated as a list", bank(bankSBI), "init_marble", `{"aadharNum": "` + aadhaarB + `", "level": "otp", "institution": "` + bankSBI + `", "documents": ["passport:K1234567", "voter:ABC1234567"]}`, ""},
+		{"optional left out", bank(bankSBI), "init_marble", `{"aadharNum": "` + aadhaarB + `", "pan": null, "level": "otp", "institution": "` + bankSBI + `"}`, ""},
+		{"required left out", customer(aadhaarA), "grantConsent", `{"aadharNum": "` + aadhaarA + `", "bank": "` + bankHDFC + `", "purpose": "loan"}`, "Invalid days: required"},
+		{"required empty", (*testStub).asRegulator, "delete", `{"aadharNum": "` + aadhaarA + `", "reason": ""}`, "Invalid reason: required"},
+		{"unknown name", customer(aadhaarA), "grantConsent", `{"aadharNum": "` + aadhaarA + `", "bank": "` + bankHDFC + `", "purpose": "loan", "days": 30, "until": "2027-01-01"}`, "Invalid until: not an argument of grantConsent("},
+		{"wrong type", customer(aadhaarA), "grantConsent", `{"aadharNum": "` + aadhaarA + `", "bank": "` + bankHDFC + `", "purpose": "loan", "days": true}`, "Invalid days: must be a whole number"},
+		{"object value", customer(aadhaarA), "grantConsent", `{"aadharNum": {"value": "` + aadhaarA + `"}, "bank": "` + bankHDFC + `", "purpose": "loan", "days": 30}`, "Invalid aadharNum: must be a string"},
+		{"not json", customer(aadhaarA), "grantConsent", `{"aadharNum": `, "are not a JSON object"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newFixture(t)
+			c.caller(s)
+			_, err := s.invoke(c.function, c.object) //the object alone
+			expectError(t, err, c.want)
+		})
+		t.Run(c.name+" after the marker", func(t *testing.T) {
+			s := newFixture(t)
+			c.caller(s)
+			_, err := s.invoke(c.function, handlers.NamedArgs, c.object)
+			expectError(t, err, c.want)
+		})
+	}
+
+	s := newFixture(t) //JSON in one of several positional arguments stays as it was passed
+	s.asRegulator()
+	s.mustInvoke(t, "delete", aadhaarA, `{"ticket": 42}`)
+	var closed []model.ClosedKYCRecord
+	decodeJSON(t, s.mustQuery(t, "readClosed", aadhaarA), &closed)
+	if len(closed) != 1 || closed[0].Reason != `{"ticket": 42}` {
+		t.Fatalf("closed %+v, expecting the JSON reason kept as it was passed", closed)
+	}
+
+	s = newFixture(t) //so does the only argument of a function taking one string, unless the marker is passed
+	s.mustInvoke(t, "reset", `{"ticket": 42}`)
+	var resetLog []model.ResetEntry
+	decodeJSON(t, s.MockStub.State[storage.ResetLogKey], &resetLog)
+	if len(resetLog) != 1 || resetLog[0].Reason != `{"ticket": 42}` {
+		t.Fatalf("reset log %+v, expecting the JSON reason kept as it was passed", resetLog)
+	}
+	s.mustInvoke(t, "reset", handlers.NamedArgs, `{"reason": "ticket 43"}`)
+	decodeJSON(t, s.MockStub.State[storage.ResetLogKey], &resetLog)
+	if len(resetLog) != 2 || resetLog[1].Reason != "ticket 43" {
+		t.Fatalf("reset log %+v, expecting the named reason", resetLog)
+	}
+
+	s = newFixture(t) //queries take them too, and give the same answer as positional arguments
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarB, Institution: bankHDFC})
+	s.asAuditor()
+	named := s.mustQuery(t, "list", `{"pageSize": 10, "filters": ["institution=`+bankHDFC+`", "status=verified"]}`)
+	positional := s.mustQuery(t, "list", "10", "", "institution="+bankHDFC, "status=verified")
+	if string(named) != string(positional) {
+		t.Fatalf("named arguments listed %s, positional ones %s", named, positional)
+	}
+	var page model.KYCPage
+	decodeJSON(t, named, &page)
+	if len(page.Records) != 1 {
+		t.Fatalf("listed %d records, expecting 1", len(page.Records))
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	cases := []struct {
+		name     string
+		caller   func(s *testStub)
+		query    bool
+		function string
+		args     []string
+		want     string
+	}{
+		{"bank registers a bank", bank(bankSBI), false, "writeBank", []string{"X1", "X", "L", "c"}, "requires role regulator"},
+		{"auditor creates a record", (*testStub).asAuditor, false, "init_marble", []string{aadhaarB, "", "full", bankSBI}, "requires role bank"},
+		{"customer reads history", customer(aadhaarA), true, "history", []string{aadhaarA}, "requires role regulator or auditor"},
+		{"no role", func(s *testStub) { s.attrs = map[string]string{} }, true, "read", []string{aadhaarA}, "Failed to read caller attribute"},
+		{"unknown role", func(s *testStub) { s.attrs = map[string]string{access.RoleAttr: "admin"} }, true, "read", []string{aadhaarA}, "Permission denied"},
+		{"bank without institution", func(s *testStub) { s.attrs = map[string]string{access.RoleAttr: access.RoleBank} }, false, "write", []string{aadhaarA, bankSBI}, "no \"institution\" attribute"},
+		{"customer without reference", func(s *testStub) { s.attrs = map[string]string{access.RoleAttr: access.RoleCustomer} }, true, "read", []string{aadhaarA}, "no \"customerRef\" attribute"},
+		{"other customer reads", customer(aadhaarB), true, "read", []string{aadhaarA}, "Permission denied"},
+		{"other bank reads", bank(bankHDFC), true, "read", []string{aadhaarA}, "did not verify this KYC record"},
+		{"other bank suspends", bank(bankHDFC), false, "suspend", []string{aadhaarA, "x"}, "only " + bankSBI},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newFixture(t)
+			c.caller(s)
+			var err error
+			if c.query {
+				_, err = s.query(c.function, c.args...)
+			} else {
+				_, err = s.invoke(c.function, c.args...)
+			}
+			expectError(t, err, c.want)
+		})
+	}
+}
+
+func TestCrossPartyRefusals(t *testing.T) {
+	grantLoan := func(t *testing.T, s *testStub) {
+		s.as(func() { s.asCustomer(aadhaarA) }, func() { s.mustInvoke(t, "grantConsent", aadhaarA, bankHDFC, model.PurposeLoan, "30") })
+	}
+	turnResetOff := func(t *testing.T, s *testStub) { s.mustInvoke(t, "init", "1", "false") }
+	cases := []struct {
+		name     string
+		setup    func(t *testing.T, s *testStub) //runs as the regulator on the fixture, nil for none
+		caller   func(s *testStub)
+		query    bool
+		function string
+		args     []string
+		want     string
+	}{
+		{"other bank reassigns to itself", nil, bank(bankHDFC), false, "set_user", []string{aadhaarA, bankHDFC}, "only " + bankSBI + " can reassign this KYC record"},
+		{"other bank reassigns to the owner", nil, bank(bankHDFC), false, "set_user", []string{aadhaarA, bankSBI}, "only " + bankSBI + " can reassign this KYC record"},
+		{"other bank re-verifies", nil, bank(bankHDFC), false, "write", []string{aadhaarA, bankHDFC}, "only " + bankSBI + " can re-verify this KYC record"},
+		{"other bank re-verifies in the owner's name", nil, bank(bankHDFC), false, "write", []string{aadhaarA, bankSBI}, "banks can only write records as themselves"},
+		{"bank writes as another bank", nil, bank(bankHDFC), false, "write", []string{aadhaarB, bankSBI}, "banks can only write records as themselves"},
+		{"bank onboards as another bank", nil, bank(bankHDFC), false, "init_marble", []string{aadhaarB, "", model.LevelOTP, bankSBI}, "banks can only onboard customers as themselves"},
+		{"consent of another purpose", grantLoan, bank(bankHDFC), true, "disclose", []string{aadhaarA, model.PurposeInsurance}, "has no valid consent for insurance"},
+		{"consent reads only its purpose", grantLoan, bank(bankHDFC), true, "read", []string{aadhaarA, model.PurposeInsurance}, "has no valid consent for insurance"},
+		{"regulator re-enables reset", turnResetOff, (*testStub).asRegulator, false, "init", []string{"1", "true"}, "Reset can only be enabled when the chaincode is first deployed"},
+		{"reset once turned off", turnResetOff, (*testStub).asRegulator, false, "reset", []string{"clean slate"}, "Reset is disabled on this network"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newFixture(t)
+			if c.setup != nil {
+				c.setup(t, s)
+			}
+			c.caller(s)
+			var err error
+			if c.query {
+				_, err = s.query(c.function, c.args...)
+			} else {
+				_, err = s.invoke(c.function, c.args...)
+			}
+			expectError(t, err, c.want)
+			if rec := s.record(t, aadhaarA); rec == nil || rec.Institution != bankSBI {
+				t.Fatalf("record of %s changed hands: %+v", aadhaarA, rec)
+			}
+			if rec := s.record(t, aadhaarB); rec != nil {
+				t.Fatalf("record of %s was created: %+v", aadhaarB, rec)
+			}
+		})
+	}
+}
+
+func TestInitMarble(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"minimal", []string{aadhaarB, "", "minimum", bankSBI}, ""},
+		{"with pan and documents", []string{aadhaarB, "abcpe1234f", "full", bankSBI, "passport:K1234567", "voterid:ABC1234567"}, ""},
+		{"too few arguments", []string{aadhaarB, "", "full"}, "Expecting at least 4"},
+		{"empty aadhaar", []string{"", "", "full", bankSBI}, "Invalid aadharNum: required"},
+		{"empty level", []string{aadhaarB, "", "", bankSBI}, "Invalid level: required"},
+		{"empty institution", []string{aadhaarB, "", "full", ""}, "Invalid institution: required"},
+		{"bad checksum", []string{"987654321013", "", "full", bankSBI}, "checksum does not match"},
+		{"starts with 1", []string{"123412341234", "", "full", bankSBI}, "cannot start with 0 or 1"},
+		{"bad pan", []string{aadhaarB, "ABCQE1234F", "full", bankSBI}, "Invalid pan"},
+		{"bad document", []string{aadhaarB, "", "full", bankSBI, "passport"}, "must look like type:ref"},
+		{"bad passport", []string{aadhaarB, "", "full", bankSBI, "passport:123"}, "Invalid"},
+		{"unknown level", []string{aadhaarB, "", "platinum", bankSBI}, "Unknown verification level"},
+		{"offline without a file", []string{aadhaarB, "", "OFFLINE", bankSBI}, "only be created from a signed file"},
+		{"already exists", []string{aadhaarA, "", "full", bankSBI}, "Aadhar number already exists"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newFixture(t)
+			s.asBank(bankSBI)
+			_, err := s.invoke("init_marble", c.args...)
+			expectError(t, err, c.want)
+			if c.want != "" {
+				return
+			}
+			rec := s.record(t, aadhaarB)
+			if rec == nil || rec.Status != model.StatusVerified || rec.Institution != bankSBI {
+				t.Fatalf("unexpected record %+v", rec)
+			}
+			if event := s.lastEvent(t); event.Name != model.EventKYCCreated || event.Payload.AadharRef != rec.Subject.AadharRef {
+				t.Fatalf("unexpected event %+v", event)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	s := newFixture(t)
+	s.asBank(bankSBI)
+	var rec model.KYCRecord
+	decodeJSON(t, s.mustQuery(t, "read", aadhaarA), &rec)
+	if rec.Subject.PAN != "ABCPE1234F" || len(rec.Documents) != 1 {
+		t.Fatalf("verifying bank did not get the customer's details: %+v", rec)
+	}
+	if strings.Contains(string(s.MockStub.State[storage.KYCKey(rec.Subject.AadharRef)]), "ABCPE1234F") {
+		t.Fatal("PAN is stored on the shared record")
+	}
+
+	_, err := s.query("read", "987654321013")
+	expectError(t, err, "checksum does not match")
+	_, err = s.query("read", aadhaarB)
+	expectError(t, err, "No KYC record")
+	_, err = s.query("read")
+	expectError(t, err, "Expecting 1 or 2: read(aadharNum, [purpose])")
+}
+
+func TestWrite(t *testing.T) {
+	s := newFixture(t)
+	s.asBank(bankSBI)
+
+	s.advance(days(1))
+	s.mustInvoke(t, "write", aadhaarA, bankSBI)
+	if rec := s.record(t, aadhaarA); rec.VerifiedAt != s.nowMs() {
+		t.Fatalf("write did not re-verify the record, verifiedAt %d", rec.VerifiedAt)
+	}
+
+	s.mustInvoke(t, "write", aadhaarB, bankSBI)
+	if rec := s.record(t, aadhaarB); rec == nil || rec.Level != model.LevelMinimum {
+		t.Fatalf("write did not create a minimum level record: %+v", rec)
+	}
+
+	s.mustInvoke(t, "suspend", aadhaarA, "under review")
+	_, err := s.invoke("write", aadhaarA, bankSBI)
+	expectError(t, err, "Cannot re-verify a KYC record that is suspended")
+	_, err = s.invoke("write", aadhaarA)
+	expectError(t, err, "Expecting 2")
+	_, err = s.invoke("write", "", bankSBI)
+	expectError(t, err, "Invalid aadharNum: required")
+}
+
+func TestWriteOtherBanksRecord(t *testing.T) {
+	s := newFixture(t)
+	s.asBank(bankHDFC)
+	_, err := s.invoke("write", aadhaarA, bankHDFC) //SBI verified aadhaarA
+	expectError(t, err, "only "+bankSBI+" can re-verify this KYC record")
+	_, err = s.invoke("write", aadhaarB, bankSBI) //creating in another bank's name
+	expectError(t, err, "banks can only write records as themselves")
+	_, err = s.invoke("init_marble", aadhaarB, "", model.LevelOTP, bankSBI)
+	expectError(t, err, "banks can only onboard customers as themselves")
+
+	if rec := s.record(t, aadhaarA); rec.Institution != bankSBI {
+		t.Fatalf("record moved to %s", rec.Institution)
+	}
+	if rec := s.record(t, aadhaarB); rec != nil {
+		t.Fatalf("record created in SBI's name: %+v", rec)
+	}
+	_, err = s.query("read", aadhaarA)
+	expectError(t, err, "did not verify this KYC record")
+}
+
+func TestSetUser(t *testing.T) {
+	s := newFixture(t)
+	s.asBank(bankSBI)
+	s.mustInvoke(t, "set_user", aadhaarA, strings.ToLower(bankHDFC))
+	if rec := s.record(t, aadhaarA); rec.Institution != bankHDFC {
+		t.Fatalf("institution is %s, expecting %s", rec.Institution, bankHDFC)
+	}
+	if event := s.lastEvent(t); event.Name != model.EventKYCUpdated || event.Payload.Bank != bankHDFC {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	s.asBank(bankHDFC) //the details were sealed for HDFC's own key
+	var rec model.KYCRecord
+	decodeJSON(t, s.mustQuery(t, "read", aadhaarA), &rec)
+	if rec.Subject.PAN != "ABCPE1234F" || len(rec.Documents) != 1 {
+		t.Fatalf("HDFC cannot read the details it was handed: %+v", rec)
+	}
+	s.withKeys(map[string][]byte{storage.TransientKey: bankKey(bankHDFC), storage.TransientNewKey: keyTwo})
+	s.mustInvoke(t, "rotateKey") //and moves them to its new key like its own
+	s.withKeys(map[string][]byte{storage.TransientKey: keyTwo})
+	rec = model.KYCRecord{}
+	decodeJSON(t, s.mustQuery(t, "read", aadhaarA), &rec)
+	if rec.Subject.PAN != "ABCPE1234F" {
+		t.Fatalf("handed over details lost in the rotation: %+v", rec)
+	}
+	s.withKeys(nil)
+
+	s.asRegulator()
+	s.mustInvoke(t, "revoke", aadhaarA, "fraud")
+	_, err := s.invoke("set_user", aadhaarA, bankSBI)
+	expectError(t, err, "Cannot reassign a KYC record that is revoked")
+	_, err = s.invoke("set_user", aadhaarA)
+	expectError(t, err, "Expecting 2")
+}
+
+func TestSetUserTakeover(t *testing.T) {
+	s := newFixture(t)
+	s.asBank(bankHDFC)
+	_, err := s.invoke("set_user", aadhaarA, bankHDFC)
+	expectError(t, err, "only "+bankSBI+" can reassign this KYC record")
+	if rec := s.record(t, aadhaarA); rec.Institution != bankSBI {
+		t.Fatalf("HDFC took the record over, institution is %s", rec.Institution)
+	}
+	_, err = s.query("read", aadhaarA)
+	expectError(t, err, "did not verify this KYC record")
+
+	s.asRegulator() //regulators can hand any record without encrypted details over, but only to an active member
+	_, err = s.invoke("set_user", aadhaarA, "ICIC0000001")
+	expectError(t, err, "is not an active member bank")
+	_, err = s.invoke("set_user", aadhaarA, bankHDFC)
+	expectError(t, err, "only "+bankSBI+" can hand this KYC record over")
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarB})
+	s.asRegulator()
+	s.mustInvoke(t, "set_user", aadhaarB, bankHDFC)
+	if rec := s.record(t, aadhaarB); rec.Institution != bankHDFC {
+		t.Fatalf("institution is %s, expecting %s", rec.Institution, bankHDFC)
+	}
+
+	s.mustInvoke(t, "writeBank", "ICIC0000001", "ICICI Bank", "RBI/3", "kyc@icici.example")
+	s.asBank(bankSBI)
+	_, err = s.invoke("set_user", aadhaarA, "ICIC0000001")
+	expectError(t, err, "ICIC0000001 has not registered a share key")
+	s.withKeys(map[string][]byte{})
+	_, err = s.invoke("set_user", aadhaarA, bankHDFC)
+	expectError(t, err, "pass the bank's key as kycKey")
+	if rec := s.record(t, aadhaarA); rec.Institution != bankSBI {
+		t.Fatalf("refused hand over moved the record to %s", rec.Institution)
+	}
+}
@@ -0,0 +1,286 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/xmldsig"
+)
+
+// uidaiSigner stands in for UIDAI, a self-signed certificate valid for a year either side of testEpoch
+type uidaiSigner struct {
+	key     *rsa.PrivateKey
+	certPEM string
+}
+
+func newUIDAISigner(t *testing.T) *uidaiSigner {
+	return newUIDAISignerValid(t, testEpoch.AddDate(-1, 0, 0), testEpoch.AddDate(1, 0, 0))
+}
+
+func newUIDAISignerValid(t *testing.T, notBefore, notAfter time.Time) *uidaiSigner {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "UIDAI test"}, NotBefore: notBefore, NotAfter: notAfter}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &uidaiSigner{key, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))}
+}
+
+// install has the regulator store the signer's certificate
+func (u *uidaiSigner) install(t *testing.T, s *testStub) {
+	t.Helper()
+	s.as(s.asRegulator, func() { s.mustInvoke(t, "setUIDAICertificate", u.certPEM) })
+}
+
+type offlineKYCSpec struct {
+	ReferenceID string
+	Name        string //defaults to a name with a character that needs escaping
+	EmailHash   string
+	Root        string //defaults to OfflinePaperlessKyc
+}
+
+const offlineKYCTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<ROOT referenceId="REFID"><UidData><Poi dob="01-01-1990" e="EMAIL" gender="F" m="" name="NAME"/>` +
+	`<Poa careof="" country="India" dist="Bengaluru" house="12" landmark="" loc="" pc="560001" po="" state="Karnataka" street="MG Road" subdist="" vtc="Bengaluru"/><Pht>cGhvdG8=</Pht></UidData>` +
+	`<Signature xmlns="http://www.w3.org/2000/09/xmldsig#"><SignedInfo>` +
+	`<CanonicalizationMethod Algorithm="http://www.w3.org/TR/2001/REC-xml-c14n-20010315"/>` +
+	`<SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"/>` +
+	`<Reference URI=""><Transforms><Transform Algorithm="http://www.w3.org/2000/09/xmldsig#enveloped-signature"/></Transforms>` +
+	`<DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"/><DigestValue>DIGEST</DigestValue></Reference>` +
+	`</SignedInfo><SignatureValue>SIGNATURE</SignatureValue></Signature></ROOT>`
+
+// offlineXML is a signed offline e-KYC file
+func (u *uidaiSigner) offlineXML(t *testing.T, spec offlineKYCSpec) string {
+	t.Helper()
+	if spec.Name == "" {
+		spec.Name = "Asha &amp; Rao"
+	}
+	if spec.Root == "" {
+		spec.Root = "OfflinePaperlessKyc"
+	}
+	doc := strings.NewReplacer("ROOT", spec.Root, "REFID", spec.ReferenceID, "EMAIL", spec.EmailHash, "NAME", spec.Name).Replace(offlineKYCTemplate)
+
+	root, err := xmldsig.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(xmldsig.Canonicalize(root, root.Child("Signature")))
+	doc = strings.Replace(doc, "DIGEST", base64.StdEncoding.EncodeToString(digest[:]), 1)
+
+	root, err = xmldsig.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := sha256.Sum256(xmldsig.Canonicalize(root.Child("Signature").Child("SignedInfo"), nil))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, u.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Replace(doc, "SIGNATURE", base64.StdEncoding.EncodeToString(signature), 1)
+}
+
+// passOfflineKYC puts a signed file in the caller metadata, where onboardOfflineKYC takes it from
+func (u *uidaiSigner) passOfflineKYC(t *testing.T, s *testStub, spec offlineKYCSpec) {
+	t.Helper()
+	s.withMetadata(storage.TransientOfflineKYC, []byte(u.offlineXML(t, spec)))
+}
+
+func TestSetUIDAICertificate(t *testing.T) {
+	s := newTestStub(t)
+	signer := newUIDAISigner(t)
+	s.mustInvoke(t, "setUIDAICertificate", signer.certPEM)
+	if string(s.MockStub.State[storage.UIDAICertKey]) != signer.certPEM {
+		t.Fatal("certificate was not stored")
+	}
+
+	_, err := s.invoke("setUIDAICertificate", "not a certificate")
+	expectError(t, err, "must be a PEM encoded certificate")
+	_, err = s.invoke("setUIDAICertificate")
+	expectError(t, err, "Expecting 1")
+}
+
+func TestOnboardOfflineKYC(t *testing.T) {
+	const refID = "0006" + "20251231153000123" //aadhaarC ends in 0006
+	signer := newUIDAISigner(t)
+	other := newUIDAISigner(t)
+
+	cases := []struct {
+		name    string
+		setup   func(t *testing.T, s *testStub)
+		aadhaar string
+		xml     func(t *testing.T) string
+		want    string
+	}{
+		{"signed file", nil, aadhaarC, func(t *testing.T) string {
+			return signer.offlineXML(t, offlineKYCSpec{ReferenceID: refID, EmailHash: strings.Repeat("AB", 32)})
+		}, ""},
+		{"no certificate", func(t *testing.T, s *testStub) { delete(s.MockStub.State, storage.UIDAICertKey) }, aadhaarC, func(t *testing.T) string {
+			return signer.offlineXML(t, offlineKYCSpec{ReferenceID: refID})
+		}, "UIDAI certificate is not configured"},
+		{"certificate expired", func(t *testing.T, s *testStub) { s.advance(days(400)) }, aadhaarC, func(t *testing.T) string {
+			return signer.offlineXML(t, offlineKYCSpec{ReferenceID: refID})
+		}, "not valid at the transaction time"},
+		{"signed by someone else", nil, aadhaarC, func(t *testing.T) string {
+			return other.offlineXML(t, offlineKYCSpec{ReferenceID: refID})
+		}, "does not verify against the configured certificate"},
+		{"changed after signing", nil, aadhaarC, func(t *testing.T) string {
+			return strings.Replace(signer.offlineXML(t, offlineKYCSpec{ReferenceID: refID}), "Asha &amp; Rao", "Someone Else", 1)
+		}, "digest does not match"},
+		{"another customer's file", nil, aadhaarD, func(t *testing.T) string {
+			return signer.offlineXML(t, offlineKYCSpec{ReferenceID: refID})
+		}, "was issued for a different aadhar number"},
+		{"bad reference id", nil, aadhaarC, func(t *testing.T) string {
+			return signer.offlineXML(t, offlineKYCSpec{ReferenceID: "0006"})
+		}, "Invalid referenceId"},
+		{"bad email hash", nil, aadhaarC, func(t *testing.T) string {
+			return signer.offlineXML(t, offlineKYCSpec{ReferenceID: refID, EmailHash: "abc"})
+		}, "Invalid email hash"},
+		{"not an offline file", nil, aadhaarC, func(t *testing.T) string {
+			return signer.offlineXML(t, offlineKYCSpec{ReferenceID: refID, Root: "Aadhaar"})
+		}, "not an offline paperless e-KYC file"},
+		{"not XML", nil, aadhaarC, func(t *testing.T) string { return "not a file" }, "Malformed XML"},
+		{"already exists", nil, aadhaarA, func(t *testing.T) string {
+			return signer.offlineXML(t, offlineKYCSpec{ReferenceID: "2346" + "20251231153000123"})
+		}, "Aadhar number already exists"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newFixture(t)
+			signer.install(t, s)
+			if c.setup != nil {
+				c.setup(t, s)
+			}
+			s.asBank(bankSBI)
+			s.withMetadata(storage.TransientOfflineKYC, []byte(c.xml(t)))
+			_, err := s.invoke("onboardOfflineKYC", c.aadhaar, c.aadhaar[8:], "https://docs.example/offline.xml")
+			expectError(t, err, c.want)
+			if c.want != "" {
+				return
+			}
+			rec := s.record(t, c.aadhaar)
+			if rec == nil || rec.Level != model.LevelOffline || rec.Offline == nil || rec.Offline.ReferenceID != refID {
+				t.Fatalf("unexpected record %+v", rec)
+			}
+			generated := time.Date(2025, time.December, 31, 10, 0, 0, 0, time.UTC) //15:30 IST
+			if rec.Offline.GeneratedAt != generated.UnixNano()/int64(time.Millisecond) {
+				t.Fatalf("generatedAt %d, expecting %s", rec.Offline.GeneratedAt, generated)
+			}
+			if rec.Offline.EmailHash != strings.Repeat("ab", 32) {
+				t.Fatalf("email hash %q was not kept lower case", rec.Offline.EmailHash)
+			}
+			if len(rec.Evidence) != 1 || rec.Evidence[0].Type != model.DocAadhaarXML || rec.Evidence[0].Issuer != "UIDAI" {
+				t.Fatalf("unexpected evidence %+v", rec.Evidence)
+			}
+			for key, value := range s.MockStub.State {
+				if strings.Contains(string(value), "Asha") || strings.Contains(string(value), "cGhvdG8=") {
+					t.Fatalf("the name or photo from the file was stored in the clear under %q", key)
+				}
+			}
+			var read model.KYCRecord //sealed with the details, the verifying bank reads them back
+			decodeJSON(t, s.mustQuery(t, "read", c.aadhaar), &read)
+			if read.Subject.Name != "Asha & Rao" || read.Subject.DOB != "1990-01-01" || read.Subject.Address != "12, MG Road, Bengaluru, Bengaluru, Karnataka, India, 560001" {
+				t.Fatalf("unexpected details from the file %+v", read.Subject)
+			}
+		})
+	}
+}
+
+func TestOfflineKYCFileOnlyOnce(t *testing.T) {
+	const sameDigits = "600000070006" //ends in 0006 like aadhaarC
+	s := newFixture(t)
+	signer := newUIDAISigner(t)
+	signer.install(t, s)
+	s.asBank(bankSBI)
+	xml := signer.offlineXML(t, offlineKYCSpec{ReferenceID: "0006" + "20251231153000123"})
+	s.withMetadata(storage.TransientOfflineKYC, []byte(xml))
+	s.mustInvoke(t, "onboardOfflineKYC", aadhaarC, "0006")
+
+	s.asBank(bankHDFC) //the same file again, for another customer whose number ends the same way
+	_, err := s.invoke("onboardOfflineKYC", sameDigits, "0006")
+	expectCode(t, err, model.CodeAlreadyExists)
+	if rec := s.record(t, sameDigits); rec != nil {
+		t.Fatalf("a replayed file onboarded %+v", rec)
+	}
+
+	s.asRegulator() //closing the record takes it out of the document index, the anchor stays
+	s.mustInvoke(t, "delete", aadhaarC, "customer left")
+	digest := sha256.Sum256([]byte(xml))
+	if _, ok := s.MockStub.State[storage.OfflineKYCPrefix+hex.EncodeToString(digest[:])]; !ok {
+		t.Fatal("closing the record removed the offline e-KYC anchor")
+	}
+	s.asBank(bankHDFC)
+	_, err = s.invoke("onboardOfflineKYC", sameDigits, "0006")
+	expectCode(t, err, model.CodeAlreadyExists)
+	if rec := s.record(t, sameDigits); rec != nil {
+		t.Fatalf("a file replayed after its record was closed onboarded %+v", rec)
+	}
+}
+
+func TestOfflineKYCFileInMetadata(t *testing.T) {
+	s := newFixture(t)
+	signer := newUIDAISigner(t)
+	signer.install(t, s)
+	s.asBank(bankSBI)
+	_, err := s.invoke("onboardOfflineKYC", aadhaarC, "0006")
+	e := expectCode(t, err, model.CodeInvalidArgument)
+	if e.Field != storage.TransientOfflineKYC {
+		t.Fatalf("error names %q, expecting %q", e.Field, storage.TransientOfflineKYC)
+	}
+
+	xml := signer.offlineXML(t, offlineKYCSpec{ReferenceID: "0006" + "20251231153000123"})
+	_, err = s.invoke("onboardOfflineKYC", aadhaarC, "0006", base64.StdEncoding.EncodeToString([]byte(xml))) //the old way, as an argument
+	expectError(t, err, "Pass the signed offline e-KYC file as offlineKyc in the caller metadata")
+
+	s.withMetadata(storage.TransientOfflineKYC, []byte(xml))
+	secret := s.secret
+	s.secret = nil
+	_, err = s.invokeAsIs("onboardOfflineKYC", aadhaarC, "0006") //the bank passes its token, never the number
+	expectError(t, err, "Pass the customer's reference token in place of the aadhar number")
+	s.secret = secret
+	_, err = s.invoke("onboardOfflineKYC", aadhaarC, "06")
+	e = expectCode(t, err, model.CodeInvalidArgument)
+	if e.Field != "last4" {
+		t.Fatalf("error names %q, expecting last4", e.Field)
+	}
+
+	s.metadata = []byte(`{"offlineKyc": "<xml/>"}`)
+	_, err = s.invoke("onboardOfflineKYC", aadhaarC, "0006")
+	expectError(t, err, "Invalid caller metadata offlineKyc: must be base64 encoded")
+}
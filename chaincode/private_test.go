@@ -0,0 +1,148 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+)
+
+func TestCustomerPIIKeptApart(t *testing.T) {
+	s := newFixture(t)
+	ref := s.ref(t, aadhaarA)
+	rec := s.record(t, aadhaarA)
+	if rec.HasInlinePII() {
+		t.Fatalf("record carries the customer's details: %+v", rec)
+	}
+	if rec.PII == nil || rec.PII.Collection != model.PIICollection || !reflect.DeepEqual(rec.PII.Fields, []string{"pan", "documents"}) {
+		t.Fatalf("unexpected digest %+v", rec.PII)
+	}
+	var sealed model.EncryptedPII
+	decodeJSON(t, s.MockStub.State[storage.PIIKey(storage.KYCKey(ref))], &sealed)
+	plaintext, err := storage.OpenPII(sealed, bankKey(bankSBI), storage.KYCKey(ref))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pii model.CustomerPII
+	decodeJSON(t, plaintext, &pii)
+	if pii.PAN != "ABCPE1234F" || len(pii.Documents) != 1 || len(pii.Salt) != 64 {
+		t.Fatalf("unexpected stored details %+v", pii)
+	}
+	for key, value := range s.MockStub.State {
+		if strings.Contains(string(value), "ABCPE1234F") || strings.Contains(string(value), "K1234567") {
+			t.Fatalf("customer details in the clear under %q", key)
+		}
+	}
+}
+
+func TestReadMergesPII(t *testing.T) {
+	s := newFixture(t)
+	cases := []struct {
+		name    string
+		caller  func(s *testStub)
+		details bool
+	}{
+		{"verifying bank", bank(bankSBI), true},
+		{"regulator", (*testStub).asRegulator, false},
+		{"auditor", (*testStub).asAuditor, false},
+		{"customer", customer(aadhaarA), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.caller(s)
+			var rec model.KYCRecord
+			decodeJSON(t, s.mustQuery(t, "read", aadhaarA), &rec)
+			if rec.HasInlinePII() != c.details {
+				t.Fatalf("details returned: %v, expecting %v", rec.HasInlinePII(), c.details)
+			}
+			if rec.PII == nil {
+				t.Fatal("digest is missing from the record")
+			}
+		})
+	}
+}
+
+func TestTamperedPII(t *testing.T) {
+	s := newFixture(t)
+	key := storage.PIIKey(storage.KYCKey(s.ref(t, aadhaarA)))
+	var sealed model.EncryptedPII
+	decodeJSON(t, s.MockStub.State[key], &sealed)
+	sealed.Ciphertext[0] ^= 1
+	s.MockStub.State[key], _ = json.Marshal(sealed)
+
+	s.asBank(bankSBI)
+	_, err := s.query("read", aadhaarA)
+	expectError(t, err, "failed to decrypt")
+
+	delete(s.MockStub.State, key)
+	_, err = s.query("read", aadhaarA)
+	expectError(t, err, "missing from the kycPII collection")
+}
+
+func TestUpdateKeepsPII(t *testing.T) {
+	s := newFixture(t)
+	before := s.record(t, aadhaarA).PII
+	s.asBank(bankSBI)
+	s.advance(days(1))
+	s.mustInvoke(t, "setRisk", aadhaarA, model.RiskLow) //a write without new details
+
+	if after := s.record(t, aadhaarA).PII; !reflect.DeepEqual(after, before) {
+		t.Fatalf("digest changed from %+v to %+v", before, after)
+	}
+	var rec model.KYCRecord
+	decodeJSON(t, s.mustQuery(t, "read", aadhaarA), &rec)
+	if rec.Subject.PAN != "ABCPE1234F" {
+		t.Fatal("details were lost on update")
+	}
+}
+
+func TestSplitStoredPII(t *testing.T) {
+	s := newFixture(t)
+	ref := s.ref(t, aadhaarA)
+	legacy := *s.record(t, aadhaarA) //as written before the details were kept apart
+	legacy.PII = nil
+	legacy.Subject.PAN = "ABCPE1234F"
+	legacy.Documents = []model.DocumentRef{{Type: "passport", Ref: "K1234567"}}
+	s.MockStub.State[storage.KYCKey(ref)], _ = json.Marshal(legacy)
+	delete(s.MockStub.State, storage.PIIKey(storage.KYCKey(ref)))
+	history, _ := storage.GetKYCHistory(s, ref)
+	history[0].Record = &legacy
+	s.MockStub.State[storage.KYCHistoryPrefix+ref], _ = json.Marshal(history)
+
+	s.mustInvoke(t, "init", "1")
+
+	if rec := s.record(t, aadhaarA); rec.HasInlinePII() || rec.PII == nil {
+		t.Fatalf("Init left the details on the record: %+v", rec)
+	}
+	if strings.Contains(string(s.MockStub.State[storage.KYCHistoryPrefix+ref]), "ABCPE1234F") {
+		t.Fatal("Init left the details in the history")
+	}
+	s.asBank(bankSBI)
+	var rec model.KYCRecord
+	decodeJSON(t, s.mustQuery(t, "read", aadhaarA), &rec)
+	if rec.Subject.PAN != "ABCPE1234F" {
+		t.Fatal("details were lost in the migration")
+	}
+}
@@ -0,0 +1,172 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/access"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/storage"
+)
+
+func TestDelete(t *testing.T) {
+	s := newFixture(t)
+	ref := s.ref(t, aadhaarA)
+	s.mustInvoke(t, "delete", aadhaarA, "customer left")
+
+	if rec := s.record(t, aadhaarA); rec != nil {
+		t.Fatalf("record is still live: %+v", rec)
+	}
+	if event := s.lastEvent(t); event.Name != model.EventKYCClosed || event.Payload.AadharRef != ref {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	for key := range s.MockStub.State {
+		if strings.HasPrefix(key, storage.KYCIndexPrefix) {
+			t.Fatalf("index key %q outlived the record", key)
+		}
+	}
+	if s.MockStub.State[storage.PIIKey(storage.KYCKey(ref))] != nil || s.MockStub.State[storage.PIIKey(storage.ClosedKYCKey(ref, s.nowMs()))] == nil {
+		t.Fatal("customer details did not move with the record")
+	}
+
+	s.asAuditor()
+	var closed []model.ClosedKYCRecord
+	decodeJSON(t, s.mustQuery(t, "readClosed", aadhaarA), &closed)
+	if len(closed) != 1 || closed[0].Reason != "customer left" || closed[0].ClosedBy != access.RoleRegulator {
+		t.Fatalf("unexpected closed records %+v", closed)
+	}
+	if closed[0].PurgeAfter != s.nowMs()+int64(model.DefaultRetentionDays)*model.MsPerDay {
+		t.Fatalf("purgeAfter %d is not %d days out", closed[0].PurgeAfter, model.DefaultRetentionDays)
+	}
+
+	s.asBank(bankSBI) //the customer can be onboarded again
+	s.mustInvoke(t, "init_marble", aadhaarA, "", model.LevelOTP, bankSBI)
+
+	s.asRegulator()
+	_, err := s.invoke("delete", aadhaarB, "never onboarded")
+	expectError(t, err, "No KYC record")
+	_, err = s.invoke("delete", aadhaarA, " ")
+	expectError(t, err, "Invalid reason: required")
+}
+
+func TestPurge(t *testing.T) {
+	cases := []struct {
+		name string
+		wait int //days after closing
+		want string
+	}{
+		{"within retention", model.DefaultRetentionDays - 1, "is retained until"},
+		{"after retention", model.DefaultRetentionDays, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newFixture(t)
+			ref := s.ref(t, aadhaarA)
+			s.mustInvoke(t, "delete", aadhaarA, "customer left")
+			s.advance(days(c.wait))
+			_, err := s.invoke("purge", aadhaarA)
+			expectError(t, err, c.want)
+
+			_, closed, _ := storage.GetClosedKYCRecords(s, ref)
+			history, _ := storage.GetKYCHistory(s, ref)
+			if c.want != "" {
+				if len(closed) != 1 || len(history) == 0 {
+					t.Fatal("refused purge removed data")
+				}
+				return
+			}
+			if len(closed) != 0 || len(history) != 0 {
+				t.Fatalf("purge left %d closed records and %d versions", len(closed), len(history))
+			}
+			for key := range s.MockStub.State {
+				if strings.HasPrefix(key, storage.KYCPIIPrefix) {
+					t.Fatalf("purge left customer details under %q", key)
+				}
+			}
+			if event := s.lastEvent(t); event.Name != model.EventKYCPurged {
+				t.Fatalf("unexpected event %+v", event)
+			}
+		})
+	}
+}
+
+func TestPurgeKeepsLaterRecords(t *testing.T) {
+	s := newFixture(t)
+	ref := s.ref(t, aadhaarA)
+	s.mustInvoke(t, "delete", aadhaarA, "customer left")
+	s.advance(days(model.DefaultRetentionDays))
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarA}) //came back after the first record was closed
+
+	s.mustInvoke(t, "purge", aadhaarA)
+	history, _ := storage.GetKYCHistory(s, ref)
+	if len(history) != 1 || history[0].Action != model.HistoryCreated {
+		t.Fatalf("purge did not keep the new record's history: %+v", history)
+	}
+	if rec := s.record(t, aadhaarA); rec == nil {
+		t.Fatal("purge removed the live record")
+	}
+
+	_, err := s.invoke("purge", aadhaarA)
+	expectError(t, err, "No closed KYC record")
+}
+
+func TestPurgeSkipsRetainedRecords(t *testing.T) {
+	s := newFixture(t)
+	ref := s.ref(t, aadhaarA)
+	s.mustInvoke(t, "delete", aadhaarA, "customer left") //retained for the default period
+	s.advance(days(1))
+	s.mustInvoke(t, "init", "1", "", "30")
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarA})
+	s.asRegulator()
+	s.mustInvoke(t, "delete", aadhaarA, "customer left again") //retained for 30 days, due first
+	s.advance(days(30))
+
+	s.mustInvoke(t, "purge", aadhaarA)
+	_, closed, _ := storage.GetClosedKYCRecords(s, ref)
+	if len(closed) != 1 || closed[0].Reason != "customer left" {
+		t.Fatalf("purge did not keep only the retained record: %+v", closed)
+	}
+	history, _ := storage.GetKYCHistory(s, ref)
+	if len(history) == 0 || history[len(history)-1].TxID != closed[0].TxID {
+		t.Fatalf("purge did not keep the retained record's history: %+v", history)
+	}
+	for _, version := range history {
+		if version.Action == model.HistoryClosed && version.TxID != closed[0].TxID {
+			t.Fatalf("purge kept the purged record's history: %+v", history)
+		}
+	}
+
+	_, err := s.invoke("purge", aadhaarA) //only the retained record is left
+	expectCode(t, err, model.CodeFailedPrecondition)
+}
+
+func TestRetentionFromInit(t *testing.T) {
+	s := newBareStub()
+	if _, err := s.init("1", "true", "30"); err != nil {
+		t.Fatal(err)
+	}
+	s.createBank(t, bankSpec{Code: bankSBI})
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarA})
+	s.mustInvoke(t, "delete", aadhaarA, "customer left")
+	s.advance(days(30))
+	s.mustInvoke(t, "purge", aadhaarA)
+}
@@ -0,0 +1,124 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/ibm-blockchain/marbles.v2/chaincode/kyc/model"
+)
+
+func TestSetRisk(t *testing.T) {
+	cases := []struct {
+		risk  string
+		years int
+		want  string
+	}{
+		{model.RiskLow, 10, ""},
+		{" Medium ", 8, ""},
+		{model.RiskHigh, 2, ""},
+		{"extreme", 0, "Invalid riskCategory"},
+	}
+	for _, c := range cases {
+		t.Run(c.risk, func(t *testing.T) {
+			s := newFixture(t)
+			s.asBank(bankSBI)
+			s.advance(days(1))
+			_, err := s.invoke("setRisk", aadhaarA, c.risk)
+			expectError(t, err, c.want)
+			if c.want != "" {
+				return
+			}
+			rec := s.record(t, aadhaarA)
+			due := testEpoch.AddDate(c.years, 0, 0).UnixNano() / int64(time.Millisecond) //from verification, not from now
+			if rec.NextReviewDue != due {
+				t.Fatalf("nextReviewDue %d, expecting %d", rec.NextReviewDue, due)
+			}
+		})
+	}
+
+	s := newFixture(t)
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarB, Status: model.StatusRevoked})
+	s.asBank(bankSBI)
+	_, err := s.invoke("setRisk", aadhaarB, model.RiskLow)
+	expectError(t, err, "Cannot assess a KYC record that is revoked")
+	_, err = s.invoke("setRisk", aadhaarA)
+	expectError(t, err, "Expecting 2")
+}
+
+func TestRenewMovesReview(t *testing.T) {
+	s := newFixture(t)
+	s.asBank(bankSBI)
+	s.advance(days(700))
+	s.mustInvoke(t, "renew", aadhaarA, model.RiskMedium)
+	rec := s.record(t, aadhaarA)
+	if rec.VerifiedAt != s.nowMs() || rec.RiskCategory != model.RiskMedium {
+		t.Fatalf("renew did not re-verify: %+v", rec)
+	}
+	if rec.NextReviewDue != s.now.AddDate(8, 0, 0).UnixNano()/int64(time.Millisecond) {
+		t.Fatalf("nextReviewDue %d is not 8 years from renewal", rec.NextReviewDue)
+	}
+	_, err := s.invoke("renew", aadhaarA, "extreme")
+	expectError(t, err, "Invalid riskCategory")
+}
+
+func TestDueForReview(t *testing.T) {
+	s := newFixture(t) //aadhaarA is high risk, due 2028-01-01
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarB, Risk: model.RiskLow})
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarC, Status: model.StatusPending})
+	s.createKYC(t, kycSpec{Aadhaar: aadhaarD, Status: model.StatusRevoked})
+
+	cases := []struct {
+		name   string
+		caller func(s *testStub)
+		args   []string
+		count  int
+		want   string
+	}{
+		{"none due yet", (*testStub).asRegulator, []string{bankSBI, "2027-12-31"}, 0, ""},
+		{"high risk due", (*testStub).asRegulator, []string{bankSBI, "2028-01-02"}, 1, ""},
+		{"everyone due", (*testStub).asAuditor, []string{bankSBI, "2040-01-01"}, 2, ""},
+		{"own bank", bank(bankSBI), []string{" sbin0000001", "2040-01-01"}, 2, ""},
+		{"other bank", bank(bankHDFC), []string{bankSBI, "2040-01-01"}, 0, "banks can only see reviews of the records they verified"},
+		{"bad date", (*testStub).asRegulator, []string{bankSBI, "01-01-2040"}, 0, "Invalid date"},
+		{"too few arguments", (*testStub).asRegulator, []string{bankSBI}, 0, "Expecting 2 to 4"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.caller(s)
+			out, err := s.query("dueForReview", c.args...)
+			expectError(t, err, c.want)
+			if c.want != "" {
+				return
+			}
+			var page model.KYCPage
+			decodeJSON(t, out, &page)
+			if len(page.Records) != c.count {
+				t.Fatalf("expecting %d records, got %+v", c.count, page.Records)
+			}
+			for _, rec := range page.Records {
+				if rec.Status == model.StatusPending || rec.Status == model.StatusRevoked {
+					t.Fatalf("%s record listed for review", rec.Status)
+				}
+			}
+		})
+	}
+}
@@ -14,94 +14,51 @@ module.exports.process_msg = function(ws, data){
 	if(data.v === 2){																						//only look at messages for part 2
 		if(data.type == 'create'){
 			console.log('its a create!');
-			if(data.name && data.color && data.size && data.user){
-				chaincode.invoke.init_marble([data.name, data.color, data.size, data.user], cb_invoked);	//create a new marble
+			if(data.aadharRef && data.level && data.institution){											//the bank's own systems work the reference token out
+				chaincode.invoke.init_marble([data.aadharRef, '', data.level, data.institution], cb_invoked);	//create a new kyc record
 			}
 		}
 		else if(data.type == 'get'){
-			console.log('get marbles msg');
-			chaincode.query.read(['_marbleindex'], cb_got_index);
+			console.log('get kyc records msg');
+			chaincode.query.list([String(data.pageSize || ''), data.bookmark || ''], cb_got_page);		//a page at a time, changes come in as events
 		}
 		else if(data.type == 'transfer'){
 			console.log('transfering msg');
-			if(data.name && data.user){
-				chaincode.invoke.set_user([data.name, data.user]);
+			if(data.aadharRef && data.institution){
+				chaincode.invoke.set_user([data.aadharRef, data.institution], cb_invoked);
 			}
 		}
 		else if(data.type == 'remove'){
 			console.log('removing msg');
-			if(data.name){
-				chaincode.invoke.delete([data.name]);
+			if(data.aadharRef && data.reason){
+				chaincode.invoke.delete([data.aadharRef, data.reason], cb_invoked);
 			}
 		}
 		else if(data.type == 'chainstats'){
 			console.log('chainstats msg');
 			ibc.chain_stats(cb_chainstats);
 		}
-		else if(data.type == 'open_trade'){
-			console.log('open_trade msg');
-			if(!data.willing || data.willing.length < 0){
-				console.log('error, "willing" is empty');
-			}
-			else if(!data.want){
-				console.log('error, "want" is empty');
-			}
-			else{
-				var args = [data.user, data.want.color, data.want.size];
-				for(var i in data.willing){
-					args.push(data.willing[i].color);
-					args.push(data.willing[i].size);
-				}
-				chaincode.invoke.open_trade(args);
-			}
-		}
-		else if(data.type == 'get_open_trades'){
-			console.log('get open trades msg');
-			chaincode.query.read(['_opentrades'], cb_got_trades);
-		}
-		else if(data.type == 'perform_trade'){
-			console.log('perform trade msg');
-			chaincode.invoke.perform_trade([data.id, data.closer.user, data.closer.name, data.opener.user, data.opener.color, data.opener.size]);
-		}
-		else if(data.type == 'remove_trade'){
-			console.log('remove trade msg');
-			chaincode.invoke.remove_trade([data.id]);
-		}
 	}
-	
-	
-	//got the marble index, lets get each marble
-	function cb_got_index(e, index){
-		if(e != null) console.log('[ws error] did not get marble index:', e);
+
+
+	//got a page of kyc records, send it along with the bookmark for the next one
+	function cb_got_page(e, page){
+		if(e != null) console.log('[ws error] did not get kyc records:', e);
 		else{
 			try{
-				var json = JSON.parse(index);
-				for(var i in json){
-					console.log('!', i, json[i]);
-					chaincode.query.read([json[i]], cb_got_marble);												//iter over each, read their values
-				}
+				page = JSON.parse(page);
+				sendMsg({msg: 'kyc_records', records: page.records, bookmark: page.bookmark});
 			}
 			catch(e){
 				console.log('[ws error] could not parse response', e);
 			}
 		}
 	}
-	
-	//call back for getting a marble, lets send a message
-	function cb_got_marble(e, marble){
-		if(e != null) console.log('[ws error] did not get marble:', e);
-		else {
-			try{
-				sendMsg({msg: 'marbles', marble: JSON.parse(marble)});
-			}
-			catch(e){}
-		}
-	}
-	
+
 	function cb_invoked(e, a){
 		console.log('response: ', e, a);
 	}
-	
+
 	//call back for getting the blockchain stats, lets get the block stats now
 	function cb_chainstats(e, chain_stats){
 		if(chain_stats && chain_stats.height){
@@ -124,20 +81,6 @@ module.exports.process_msg = function(ws, data){
 			});
 		}
 	}
-	
-	//call back for getting open trades, lets send the trades
-	function cb_got_trades(e, trades){
-		if(e != null) console.log('[ws error] did not get open trades:', e);
-		else {
-			try{
-				trades = JSON.parse(trades);
-				if(trades && trades.open_trades){
-					sendMsg({msg: 'open_trades', open_trades: trades.open_trades});
-				}
-			}
-			catch(e){}
-		}
-	}
 
 	//send a message, socket might be closed...
 	function sendMsg(json){
@@ -151,3 +94,21 @@ module.exports.process_msg = function(ws, data){
 		}
 	}
 };
+
+// ==================================
+// Block Events - the kyc events a block carries, one per transaction at most, the payload is base64 encoded JSON
+// ==================================
+module.exports.block_events = function(stats){
+	var events = [];
+	var cc_events = (stats && stats.nonHashData && stats.nonHashData.chaincodeEvents) || [];
+	for(var i in cc_events){
+		if(!cc_events[i].eventName) continue;														//transactions without an event leave an empty entry
+		try{
+			events.push(JSON.parse(new Buffer(cc_events[i].payload, 'base64').toString()));
+		}
+		catch(e){
+			console.log('[ws error] could not parse event', cc_events[i].eventName, e);
+		}
+	}
+	return events;
+};